@@ -0,0 +1,372 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"math/rand/v2"
+	"slices"
+)
+
+// The lookahead bot runs a depth-limited minimax search. Only the best few moves of each
+// player, by how many cells they gain right away, are searched.
+const botLookaheadCandidates = 8 // moves of the bot searched
+const botSearchDepth = 3         // plies searched, counting the bot's move
+const botSearchWidth = 4         // moves of the other plies searched
+const botSearchPieces = 2        // most likely pieces searched when the next piece isn't known
+
+// how many cells a bite has to gain, per bite spent, to be worth it
+const botBiteCostWeight = 2
+
+// botMove is a move chosen by a bot. action is one of the gameEvent types
+// gameEventPlacePiece, gameEventPlaceBite, gameEventReroll or gameEventSkipTurn.
+type botMove struct {
+	action string
+	index  int
+	mask   PieceMask
+}
+
+// botCandidate is a move with the value the bot gave it
+type botCandidate struct {
+	move  botMove
+	value int
+}
+
+// cloneForSimulation returns a copy of the game that moves can be applied to without
+// changing game, and that can be read without holding game.mu. Connections, history and
+// undo state are not copied.
+func (game *Game) cloneForSimulation() *Game {
+	return &Game{
+		playerCount:               game.playerCount,
+		turn:                      game.turn,
+		players:                   game.players,
+		scores:                    game.scores,
+		bites:                     game.bites,
+		rerolls:                   game.rerolls,
+		newCellsForBites:          game.newCellsForBites,
+		newCellsForBitesThreshold: game.newCellsForBitesThreshold,
+		bonusBiteAward:            game.bonusBiteAward,
+		bonusRerollAward:          game.bonusRerollAward,
+		board:                     game.board.clone(),
+		rowCount:                  game.rowCount,
+		colCount:                  game.colCount,
+		pieces:                    game.pieces,
+		biteShapes:                game.biteShapes,
+		nextPiece:                 game.nextPiece,
+		pieceQueue:                slices.Clone(game.pieceQueue),
+		draftPieces:               slices.Clone(game.draftPieces),
+		captureMode:               game.captureMode,
+		captureDiagonals:          game.captureDiagonals,
+		captureMinLength:          game.captureMinLength,
+		captureMaxLength:          game.captureMaxLength,
+		captureChains:             game.captureChains,
+		captureHomeCells:          game.captureHomeCells,
+		captureThroughHomeCells:   game.captureThroughHomeCells,
+		winTerritory:              game.winTerritory,
+		winScore:                  game.winScore,
+		tieBreak:                  game.tieBreak,
+		teams:                     game.teams,
+		teamSharedPaths:           game.teamSharedPaths,
+		isOver:                    game.isOver,
+	}
+}
+
+// botMovesFrom converts legal moves to bot moves of type action
+func botMovesFrom(action string, moves []Move) []botMove {
+	botMoves := make([]botMove, 0, len(moves))
+	for _, m := range moves {
+		botMoves = append(botMoves, botMove{action, m.Index, m.Mask})
+	}
+	return botMoves
+}
+
+// botPlacements returns every legal placement of piece for the player whose turn it is
+func (game *Game) botPlacements(piece Piece) []botMove {
+	return botMovesFrom(gameEventPlacePiece, game.legalPlacements(game.turn, piece))
+}
+
+// botBites returns every legal bite the player whose turn it is can afford
+func (game *Game) botBites() []botMove {
+	return botMovesFrom(gameEventPlaceBite, game.legalBites(game.turn))
+}
+
+// simulate returns a copy of game with move applied for the player whose turn it is.
+// The turn is not advanced.
+func (game *Game) simulate(move botMove) *Game {
+	sim := game.cloneForSimulation()
+	switch move.action {
+	case gameEventPlacePiece:
+		sim.applyPiece(Cell(sim.turn+1), move.index, move.mask)
+	case gameEventPlaceBite:
+		sim.applyBite(move.index, move.mask)
+	}
+	return sim
+}
+
+// botEvaluate rates the board for playerIndex: cells owned by the player and their teammates
+// minus cells owned by opponents
+func (game *Game) botEvaluate(playerIndex int) int {
+	value := 0
+	for i := 0; i < game.playerCount; i++ {
+		if game.teamOf(i) == game.teamOf(playerIndex) {
+			value += game.scores[i]
+		} else {
+			value -= game.scores[i]
+		}
+	}
+	return value
+}
+
+// botCandidates returns the legal placements of piece and affordable bites for the player
+// whose turn it is, valued by how much they improve botEvaluate for that player.
+func (game *Game) botCandidates(piece Piece) []botCandidate {
+	playerIndex := game.turn
+	before := game.botEvaluate(playerIndex)
+	var candidates []botCandidate
+	for _, move := range append(game.botPlacements(piece), game.botBites()...) {
+		value := game.simulate(move).botEvaluate(playerIndex) - before
+		if move.action == gameEventPlaceBite {
+			cost, _ := game.biteCost(move.mask)
+			value -= botBiteCostWeight * cost
+		}
+		candidates = append(candidates, botCandidate{move, value})
+	}
+	return candidates
+}
+
+// botSearchPieces returns the pieces the player whose turn it is may be dealt during a
+// search and the chance of each. The piece is known if it is in the piece queue. Otherwise
+// the botSearchPieces most likely pieces are used. The known piece is removed from the queue.
+func (game *Game) botSearchPieces() ([]Piece, []float64) {
+	if len(game.pieceQueue) > 0 {
+		piece := game.pieceQueue[0]
+		game.pieceQueue = game.pieceQueue[1:]
+		return []Piece{piece}, []float64{1}
+	}
+
+	pieces := slices.Clone(game.pieces)
+	slices.SortStableFunc(pieces, func(a, b Piece) int { return cmp.Compare(b.Weight, a.Weight) })
+	pieces = pieces[:min(len(pieces), botSearchPieces)]
+	var totalWeight float64
+	for _, piece := range pieces {
+		totalWeight += piece.Weight
+	}
+	chances := make([]float64, len(pieces))
+	for i, piece := range pieces {
+		if totalWeight > 0 {
+			chances[i] = piece.Weight / totalWeight
+		} else {
+			chances[i] = 1 / float64(len(pieces))
+		}
+	}
+	return pieces, chances
+}
+
+// botSearch returns the value of game for playerIndex after searching depth more plies,
+// starting with the player whose turn it is.
+func (game *Game) botSearch(playerIndex, depth int) float64 {
+	if depth <= 0 || game.isOver || game.turn < 0 {
+		return float64(game.botEvaluate(playerIndex))
+	}
+	var value float64
+	pieces, chances := game.botSearchPieces()
+	for i, piece := range pieces {
+		value += chances[i] * game.botSearchMove(playerIndex, depth, []Piece{piece})
+	}
+	return value
+}
+
+// botSearchMove returns the value of game for playerIndex after the player whose turn it is
+// plays one of offer and depth-1 more plies are searched. Players on the team of playerIndex
+// choose the move that is best for playerIndex and every other player chooses the worst one.
+func (game *Game) botSearchMove(playerIndex, depth int, offer []Piece) float64 {
+	var candidates []botCandidate
+	for _, piece := range offer {
+		candidates = append(candidates, game.botCandidates(piece)...)
+	}
+	if len(candidates) == 0 {
+		sim := game.cloneForSimulation()
+		sim.advanceTurn()
+		return sim.botSearch(playerIndex, depth-1)
+	}
+
+	slices.SortStableFunc(candidates, func(a, b botCandidate) int { return b.value - a.value })
+	candidates = candidates[:min(len(candidates), botSearchWidth)]
+	maximize := game.teamOf(game.turn) == game.teamOf(playerIndex)
+	var best float64
+	for i, c := range candidates {
+		sim := game.simulate(c.move)
+		sim.advanceTurn()
+		value := sim.botSearch(playerIndex, depth-1)
+		if i == 0 || (maximize && value > best) || (!maximize && value < best) {
+			best = value
+		}
+	}
+	return best
+}
+
+// chooseBotMove picks a move for the player whose turn it is, based on their bot level.
+// Random choices come from rng. The caller must hold game.mu.
+func (game *Game) chooseBotMove(rng *rand.Rand) botMove {
+	playerIndex := game.turn
+	level := game.players[playerIndex].Bot
+
+	var candidates []botCandidate
+	if level == botLevelRandom {
+		var moves []botMove
+		for _, piece := range game.offerOf(playerIndex) {
+			moves = append(moves, game.botPlacements(piece)...)
+		}
+		if len(moves) == 0 {
+			moves = game.botBites()
+		}
+		for _, move := range moves {
+			candidates = append(candidates, botCandidate{move, 0})
+		}
+	} else {
+		for _, piece := range game.offerOf(playerIndex) {
+			candidates = append(candidates, game.botCandidates(piece)...)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if game.rerolls[playerIndex] > 0 && len(game.pieces) > 1 {
+			return botMove{gameEventReroll, -1, 0}
+		}
+		return botMove{gameEventSkipTurn, -1, 0}
+	}
+
+	// shuffle so that ties are broken randomly
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if level == botLevelRandom {
+		return candidates[0].move
+	}
+	slices.SortStableFunc(candidates, func(a, b botCandidate) int { return b.value - a.value })
+	if level == botLevelGreedy {
+		return candidates[0].move
+	}
+
+	// lookahead: search the best few moves a few plies deep
+	candidates = candidates[:min(len(candidates), botLookaheadCandidates)]
+	best := candidates[0].move
+	bestValue := 0.0
+	for i, c := range candidates {
+		sim := game.simulate(c.move)
+		sim.advanceTurn()
+		value := sim.botSearch(playerIndex, botSearchDepth-1)
+		if c.move.action == gameEventPlaceBite {
+			cost, _ := game.biteCost(c.move.mask)
+			value -= float64(botBiteCostWeight * cost)
+		}
+		if i == 0 || value > bestValue {
+			best, bestValue = c.move, value
+		}
+	}
+	return best
+}
+
+// botNeedsToAct returns true if a bot has to take its turn or answer an undo request.
+// The caller must hold game.mu.
+func (game *Game) botNeedsToAct() bool {
+	if game.undoRequest != nil {
+		for _, i := range game.undoRequest.Pending {
+			if game.players[i].isBot() {
+				return true
+			}
+		}
+	}
+	if game.simultaneous {
+		return game.nextBotToSubmit() >= 0
+	}
+	if game.isOver || game.turn < 0 || !game.players[game.turn].isBot() {
+		return false
+	}
+
+	// stop if every player skipped their last turn to avoid playing forever
+	if len(game.history) < game.playerCount {
+		return true
+	}
+	for _, e := range game.history[len(game.history)-game.playerCount:] {
+		if e.Type != gameEventSkipTurn {
+			return true
+		}
+	}
+	return false
+}
+
+// nextBotToSubmit returns the first bot that still has to submit a move this round of
+// simultaneous turns, or -1 if there is none. The caller must hold game.mu.
+func (game *Game) nextBotToSubmit() int {
+	for i := 0; i < game.playerCount; i++ {
+		if game.players[i].isBot() && game.canSubmit(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// botsAcceptUndo accepts pending undo requests on behalf of bots.
+// Returns true if any bot accepted.
+func (game *Game) botsAcceptUndo() (bool, error) {
+	game.mu.Lock()
+	var bots []Player
+	if game.undoRequest != nil {
+		for _, i := range game.undoRequest.Pending {
+			if game.players[i].isBot() {
+				bots = append(bots, game.players[i])
+			}
+		}
+	}
+	game.mu.Unlock()
+
+	for _, bot := range bots {
+		if err := game.respondUndo(bot, true); err != nil {
+			return false, err
+		}
+	}
+	return len(bots) > 0, nil
+}
+
+// playBotTurn plays one move if it is a bot's turn. The move goes through the same
+// checks as a human player's move. With simultaneous turns, one bot submits its move.
+// Returns the bot and the move it played. The bot is the zero Player if no move was played.
+func (game *Game) playBotTurn() (Player, botMove, error) {
+	game.mu.Lock()
+	if !game.botNeedsToAct() || game.isOver {
+		game.mu.Unlock()
+		return Player{}, botMove{}, nil
+	}
+	// bots choose their move on a copy, as if it was their turn, so the search doesn't
+	// hold game.mu. The move is checked again when it is played.
+	chooser := game.cloneForSimulation()
+	if game.simultaneous {
+		chooser.turn = game.nextBotToSubmit()
+		offer := game.offerOf(chooser.turn)
+		chooser.nextPiece, chooser.draftPieces = offer[0], offer[1:]
+	}
+	if !chooser.players[chooser.turn].isBot() {
+		game.mu.Unlock()
+		return Player{}, botMove{}, nil
+	}
+	bot := chooser.players[chooser.turn]
+	// seeded from the game state so bots are repeatable without using game.rng
+	rng := rand.New(rand.NewPCG(game.seed, uint64(len(game.history))))
+	game.mu.Unlock()
+	move := chooser.chooseBotMove(rng)
+
+	var err error
+	switch move.action {
+	case gameEventPlacePiece:
+		err = game.placePiece(bot, move.index, move.mask)
+	case gameEventPlaceBite:
+		err = game.placeBite(bot, move.index, move.mask)
+	case gameEventReroll:
+		err = game.reroll(bot)
+	case gameEventSkipTurn:
+		err = game.skipTurn(bot)
+	default:
+		err = errors.New("Unexpected bot move: " + move.action)
+	}
+	return bot, move, err
+}
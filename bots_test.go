@@ -0,0 +1,241 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"os"
+	"testing"
+	"time"
+)
+
+// newBotTestGame creates a 10x10 game without bonus cells for a human and a bot of the given level.
+// The seed is fixed so the pieces and the bot's choices are the same every run.
+func newBotTestGame(t *testing.T, lobbyName string, level string) (*Game, Player, Player) {
+	human := joinLobbyWrapper(t, lobbyName, "human", "")
+	bot, err := addBotToLobby(lobbyName, level)
+	if err != nil {
+		t.Fatalf("addBotToLobby failed: %v", err)
+	}
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":                 10,
+		"has_bonus_bite_cells": false,
+		"bonus_reroll_cells":   0,
+		"seed":                 uint64(1),
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	return game, human, bot
+}
+
+func TestAddBotToLobby(t *testing.T) {
+	lobbyName := "TestAddBotToLobby"
+	joinLobbyWrapper(t, lobbyName, "human", "")
+
+	if _, err := addBotToLobby(lobbyName, "unbeatable"); err == nil {
+		t.Error("Expected an error for an invalid bot level")
+	}
+	if _, err := addBotToLobby("TestAddBotToLobbyMissing", botLevelGreedy); err == nil {
+		t.Error("Expected an error for a lobby that does not exist")
+	}
+
+	for i := 1; i < maxPlayers; i++ {
+		bot, err := addBotToLobby(lobbyName, botLevels[i%len(botLevels)])
+		if err != nil {
+			t.Fatalf("addBotToLobby failed: %v", err)
+		}
+		if !bot.isBot() || bot.Bot != botLevels[i%len(botLevels)] {
+			t.Errorf("Expected a %s bot. Got %v", botLevels[i%len(botLevels)], &bot)
+		}
+		if activeLobbies[lobbyName].player[i].id != bot.id {
+			t.Errorf("Expected bot in slot %d. Got %v", i, activeLobbies[lobbyName])
+		}
+	}
+	if _, err := addBotToLobby(lobbyName, botLevelGreedy); err == nil || err.Error() != "Lobby is full" {
+		t.Errorf("Expected 'Lobby is full' error. Got %v", err)
+	}
+
+	// remove a bot and add it back
+	if err := removeBotFromLobby(lobbyName, "human"); err == nil {
+		t.Error("Expected an error removing a human with removeBotFromLobby")
+	}
+	if err := removeBotFromLobby(lobbyName, "Bot 2"); err != nil {
+		t.Fatalf("removeBotFromLobby failed: %v", err)
+	}
+	if !activeLobbies[lobbyName].player[1].lastSeen.IsZero() {
+		t.Errorf("Expected slot 1 to be empty. Got %v", activeLobbies[lobbyName])
+	}
+	if _, err := addBotToLobby(lobbyName, botLevelRandom); err != nil {
+		t.Fatalf("addBotToLobby failed: %v", err)
+	}
+
+	// bots don't keep a lobby open once the humans are gone
+	activeLobbies[lobbyName].player[0].lastSeen = time.Now().Add(-2 * lobbyMemberIdleTimeout)
+	cleanUpLobbies(log.New(os.Stderr, "", 0), false)
+	if _, ok := activeLobbies[lobbyName]; ok {
+		t.Errorf("Expected a lobby with only bots to be removed. Got %v", activeLobbies[lobbyName])
+	}
+}
+
+func TestBotCapture(t *testing.T) {
+	for _, level := range []string{botLevelGreedy, botLevelLookahead} {
+		game, human, _ := newBotTestGame(t, "TestBotCapture_"+level, level)
+
+		// the bot (player 2) can capture two cells by placing a single square at (5,3)
+		for r := range game.board {
+			for c := range game.board[r] {
+				game.board[r][c] = 0
+			}
+		}
+		game.board[5][0] = 2 | CellFlagHome
+		for c := 0; c < 4; c++ {
+			game.board[4][c] = 2
+		}
+		game.board[6][1] = 1 | CellFlagHome
+		game.board[5][1] = 1
+		game.board[5][2] = 1
+		game.updateScores()
+
+		if err := game.skipTurn(human); err != nil {
+			t.Fatalf("skipTurn failed: %v", err)
+		}
+		game.nextPiece = Piece{PieceMask(0b1000000).generateRotations(), 1}
+
+		bot, move, err := game.playBotTurn()
+		if err != nil {
+			t.Fatalf("playBotTurn failed for %s bot: %v", level, err)
+		}
+		if bot.Bot != level {
+			t.Fatalf("Expected the %s bot to move. Got %v", level, &bot)
+		}
+		// the lookahead bot may prefer another square that takes the same cells
+		expectedIndex := game.board.getIndex1D(5, 3)
+		if level == botLevelGreedy && (move.action != gameEventPlacePiece || move.index != expectedIndex) {
+			t.Errorf("Expected %s bot to place a piece at %d. Got %+v\n%s", level, expectedIndex, move, game.board.String2D())
+		}
+		if game.scores[0] != 1 {
+			t.Errorf("Expected %s bot to take 2 cells. Scores: %v\n%s", level, game.scores, game.board.String2D())
+		}
+	}
+}
+
+func TestBotPlaysTurn(t *testing.T) {
+	for _, level := range botLevels {
+		game, human, bot := newBotTestGame(t, "TestBotPlaysTurn_"+level, level)
+
+		// nothing to do on the human's turn
+		player, _, err := game.playBotTurn()
+		if err != nil || !player.lastSeen.IsZero() {
+			t.Fatalf("Expected no bot move on a human's turn. Got %v, %v", &player, err)
+		}
+
+		if err = game.skipTurn(human); err != nil {
+			t.Fatalf("skipTurn failed: %v", err)
+		}
+		player, move, err := game.playBotTurn()
+		if err != nil {
+			t.Fatalf("playBotTurn failed for %s bot: %v", level, err)
+		}
+		if player.id != bot.id || move.action != gameEventPlacePiece {
+			t.Errorf("Expected the %s bot to place a piece. Got %v %+v", level, &player, move)
+		}
+		if game.turn != 0 || game.scores[1] <= 1 {
+			t.Errorf("Expected the %s bot's piece on the board.\n%s", level, game.board.String2D())
+		}
+
+		// a bot with no room and no resources skips
+		if err = game.skipTurn(human); err != nil {
+			t.Fatalf("skipTurn failed: %v", err)
+		}
+		for r := range game.board {
+			for c := range game.board[r] {
+				if game.board[r][c] == 0 {
+					game.board[r][c] = 1
+				}
+			}
+		}
+		game.updateScores()
+		game.bites[1] = 0
+		game.rerolls[1] = 0
+		_, move, err = game.playBotTurn()
+		if err != nil {
+			t.Fatalf("playBotTurn failed for %s bot: %v", level, err)
+		}
+		if move.action != gameEventSkipTurn {
+			t.Errorf("Expected the %s bot to skip. Got %+v", level, move)
+		}
+	}
+}
+
+func TestBotAcceptsUndo(t *testing.T) {
+	game, human, _ := newBotTestGame(t, "TestBotAcceptsUndo", botLevelRandom)
+
+	if err := game.skipTurn(human); err != nil {
+		t.Fatalf("skipTurn failed: %v", err)
+	}
+	if err := game.requestUndo(human); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	game.mu.Lock()
+	needsToAct := game.botNeedsToAct()
+	game.mu.Unlock()
+	if !needsToAct {
+		t.Error("Expected the bot to need to answer the undo request")
+	}
+
+	accepted, err := game.botsAcceptUndo()
+	if err != nil || !accepted {
+		t.Fatalf("Expected the bot to accept the undo. accepted=%t err=%v", accepted, err)
+	}
+	if game.undoRequest != nil || game.turn != 0 {
+		t.Errorf("Expected the skip to be undone. turn=%d undoRequest=%+v", game.turn, game.undoRequest)
+	}
+}
+
+func TestBotLookaheadAvoidsCapture(t *testing.T) {
+	game, _, _ := newBotTestGame(t, "TestBotLookaheadAvoidsCapture", botLevelLookahead)
+
+	// the bot (player 2) can place a square on either end of its row, but then the human
+	// captures the row by placing a square on the other end. (3,3) is safe.
+	w := CellFlagWall
+	game.board = GameBoard{
+		{1 | CellFlagHome, 1, 1, 1, 1, 1},
+		{1, w, w, w, w, 1},
+		{1, 0, 2, 2, 0, 1},
+		{w, w, 2, 0, w, w},
+		{w, w, 2 | CellFlagHome, w, w, w},
+		{w, w, w, w, w, w},
+	}
+	game.rowCount, game.colCount = 6, 6
+	game.pieces = []Piece{{PieceMask(0b1000000).generateRotations(), 1}}
+	game.nextPiece = game.pieces[0]
+	game.pieceQueue = []Piece{game.pieces[0]} // the piece after that one isn't known
+	game.bites = [maxPlayers]int{}
+	game.updateScores()
+	game.turn = 1
+
+	expectedIndex := game.board.getIndex1D(3, 3)
+	for seed := range uint64(10) {
+		move := game.chooseBotMove(rand.New(rand.NewPCG(seed, seed)))
+		if move.action != gameEventPlacePiece || move.index != expectedIndex {
+			t.Fatalf("Expected the lookahead bot to place a piece at %d. Got %+v\n%s", expectedIndex, move, game.board.String2D())
+		}
+	}
+}
+
+func BenchmarkBotLookahead(b *testing.B) {
+	lobbyName := "BenchmarkBotLookahead"
+	joinLobbyWrapper(b, lobbyName, "human", "")
+	if _, err := addBotToLobby(lobbyName, botLevelLookahead); err != nil {
+		b.Fatalf("addBotToLobby failed: %v", err)
+	}
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"seed": uint64(1)})
+	if err != nil {
+		b.Fatalf("createGame failed: %v", err)
+	}
+	game.turn = 1
+
+	for b.Loop() {
+		game.chooseBotMove(rand.New(rand.NewPCG(1, 1)))
+	}
+}
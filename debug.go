@@ -44,7 +44,7 @@ func debugHandlerListGames(w http.ResponseWriter, r *http.Request) {
 	})
 
 	for _, gameId := range keys {
-		fmt.Fprintf(w, `<p>Game: %v (%s)</p>`, gameId, activeGames[gameId].fromLobby)
+		fmt.Fprintf(w, `<p>Game: %v (%s) seed: %d</p>`, gameId, activeGames[gameId].fromLobby, activeGames[gameId].seed)
 		for i := 0; i < activeGames[gameId].playerCount; i++ {
 			player := activeGames[gameId].players[i]
 			fmt.Fprintf(w, `<button onclick="window.location.replace('/debug/join-existing-game?game-id=%v&player-id=%v&player-name=%v');">%v</button>`, gameId, player.id, url.QueryEscape(player.Name), player.Name)
@@ -4,7 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
+	"math/rand/v2"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,18 +15,26 @@ import (
 )
 
 const gbDefaultSize = 20
-const gbMinSize = pieceMaskMaxLength
+const gbDefaultRows = gbDefaultSize
+const gbDefaultCols = gbDefaultSize
+const gbMinSize = legacyPieceMaskLength
 const gbMaxSize = 128
 const gbStartOffsetDivisor = 5
+const gbCornerPlayers = 4       // start positions past this many players are on the middle of the edges
+const gbMinSizeManyPlayers = 12 // games with more than gbCornerPlayers players need at least this many rows and columns
 
 const gbDefaultRandomizeStartPos = false
 const gbDefaultStartBites = 4
 const gbDefaultStartRerolls = 3
-const gbDefaultHasBonusBiteCells = true
-const gbBonusBiteAward = 3
 const gbDefaultBonusRerollCells = 3
-const gbBonusRerollAward = 1
 const gbDefaultNewBiteFreqFactor = 1.0
+const gbMaxForcedSkips = 100 // forced skips in a row before giving up on finding a player that can move
+const gbDefaultWallMode = gameWallsNone
+const gbDefaultWallDensity = 0.1
+const gbMaxWallDensity = 0.4
+const gbWallHomeClearance = 2 // walls are kept at least this many cells away from home cells
+const gbWallAttempts = 10     // attempts at placing walls that leave every home cell reachable
+const gbTeamCount = 2         // team play splits players into this many teams, alternating in turn order
 
 const (
 	gameModeCaptureFromPiece = iota
@@ -34,6 +43,13 @@ const (
 	gameModeCaptureMax // For input validation. Not a capture mode.
 )
 
+const (
+	gameWallsNone      = iota
+	gameWallsRandom    // walls are scattered randomly
+	gameWallsSymmetric // walls are mirrored across both axes so every start position is alike
+	gameWallsMax       // For input validation. Not a wall mode.
+)
+
 var activeGames = map[uuid.UUID]*Game{}
 var activeGameMutex sync.Mutex
 var activeGameMaxAge = 24 * time.Hour
@@ -51,20 +67,75 @@ type Game struct {
 	newCellsForBitesThreshold int             // placing or capturing this many pieces grants a bite, -1 disables this
 	startBites                int
 	startRerolls              int
-	bonusBiteCells            bool
+	bonusBiteCells            int
+	biteShapes                []Bite // the bites players may place, see gameBites.go
 	bonusRerollCells          int
+	bonusBiteAward            int // see gameBonus.go for the bonus cell options
+	bonusRerollAward          int
+	bonusPlacement            int
+	bonusHomeClearance        int
+	shieldCells               int // see gameSpecialCells.go
+	multiplierCells           int
+	sporeCells                int
 	board                     GameBoard
 	rowCount                  int
 	colCount                  int
 	lastBoardUpdate           []int
 	pieces                    []Piece
+	mirrorPieces              bool // pieces may be flipped, see withMirrors
 	nextPiece                 Piece
+	pieceDistribution         int                 // how pieces are drawn, gamePiecesRandom or gamePiecesBag
+	pieceQueueLength          int                 // number of upcoming pieces shown after nextPiece
+	pieceQueue                []Piece             // pieces dealt after nextPiece, in order
+	pieceBag                  []Piece             // pieces left in the bag with gamePiecesBag
+	allowHold                 bool                // players may set their piece aside for a later turn
+	heldPieces                [maxPlayers]Piece   // piece each player set aside, the zero Piece if none
+	holdUsed                  [maxPlayers]bool    // true if the player already held a piece this turn
+	draftSize                 int                 // pieces offered each turn, see offerOf
+	draftPieces               []Piece             // pieces offered alongside nextPiece
+	playerDraftPieces         [maxPlayers][]Piece // pieces offered alongside playerPieces with simultaneous turns
 	captureMode               int
+	captureDiagonals          bool // see gameCapture.go for the capture rules
+	captureMinLength          int
+	captureMaxLength          int // 0 for no limit
+	captureChains             bool
+	captureHomeCells          bool
+	captureThroughHomeCells   bool
+	winTerritory              int // see gameWin.go for the win conditions
+	winScore                  int
+	maxRounds                 int
+	tieBreak                  int
+	round                     int // rounds played so far
 	randomizeStartPos         bool
+	wallMode                  int
+	wallDensity               float64  // fraction of the board covered by walls
+	gameMap                   *GameMap // predefined layout used instead of generating the board, may be nil
+	teams                     bool     // players alternate between teams in turn order, see teamOf
+	teamSharedPaths           bool     // with teams, cells stay connected to home through a teammate's cells
 	created                   time.Time
 	fromLobby                 string
 	isOver                    bool
+	winners                   []int // players with the best result once the game is over, more than one for a draw
 	winLossDrawRecord         [maxPlayers]WinLossDraw
+	history                   []GameEvent   // every action taken, in order
+	historyBroadcast          int           // number of history events already sent to clients
+	undoSnapshot              *gameSnapshot // state before the last move, nil if it can't be undone
+	undoRequest               *UndoRequest  // pending request to undo the last move
+	seed                      uint64        // seed for rng. The same seed and moves replay the same game.
+	rng                       *rand.Rand    // all random choices for the game come from rng
+	rngSource                 *rand.PCG     // source of rng, kept so its state can be saved
+	botsRunning               bool          // true while bot turns are being played
+	timerMode                 int
+	turnTime                  time.Duration             // time limit for each turn with gameTimerPerTurn
+	clockTime                 time.Duration             // starting time for each player with gameTimerClock
+	clockIncrement            time.Duration             // time added to a player's clock after each turn with gameTimerClock
+	timeoutAction             int                       // what happens when a player runs out of time
+	clocks                    [maxPlayers]time.Duration // time left for each player with gameTimerClock, not counting the current turn
+	turnStarted               time.Time                 // when the current turn started
+	fogOfWar                  int                       // players only see cells within this many rows and columns of their own, 0 to see everything
+	simultaneous              bool                      // every player moves at once each round, see resolveRound
+	roundMoves                [maxPlayers]*RoundMove    // moves submitted this round with simultaneous turns
+	playerPieces              [maxPlayers]Piece         // the piece each player places this round with simultaneous turns
 	uuid                      uuid.UUID
 }
 
@@ -86,6 +157,12 @@ func (g *Game) String() string {
 	b.WriteString("uuid: ")
 	b.WriteString(fmt.Sprintf("%v\n", g.uuid))
 
+	b.WriteString("size: ")
+	b.WriteString(fmt.Sprintf("%dx%d\n", g.rowCount, g.colCount))
+
+	b.WriteString("seed: ")
+	b.WriteString(fmt.Sprintf("%d\n", g.seed))
+
 	b.WriteString("turn: ")
 	b.WriteString(fmt.Sprintf("%d\n", g.turn))
 
@@ -108,11 +185,21 @@ func (g *Game) String() string {
 	b.WriteString(fmt.Sprintf("%d\n", g.startRerolls))
 
 	b.WriteString("bonusBiteCells: ")
-	b.WriteString(fmt.Sprintf("%t\n", g.bonusBiteCells))
+	b.WriteString(fmt.Sprintf("%d\n", g.bonusBiteCells))
 
 	b.WriteString("bonusRerollCells: ")
 	b.WriteString(fmt.Sprintf("%d\n", g.bonusRerollCells))
 
+	b.WriteString("specialCells: ")
+	b.WriteString(fmt.Sprintf("shield %d, multiplier %d, spore %d\n", g.shieldCells, g.multiplierCells, g.sporeCells))
+
+	b.WriteString("bonusCells: ")
+	b.WriteString(fmt.Sprintf("bite award %d, reroll award %d, placement %d, home clearance %d\n",
+		g.bonusBiteAward, g.bonusRerollAward, g.bonusPlacement, g.bonusHomeClearance))
+
+	b.WriteString("biteShapes: ")
+	b.WriteString(fmt.Sprintf("%v\n", g.biteShapes))
+
 	b.WriteString("pieces: ")
 	b.WriteString(fmt.Sprintf("%v\n", g.pieces))
 
@@ -131,12 +218,60 @@ func (g *Game) String() string {
 		b.WriteString(fmt.Sprintf("Unknown (%d)\n", g.captureMode))
 	}
 
+	b.WriteString("winConditions: ")
+	b.WriteString(fmt.Sprintf("territory %d%%, score %d, rounds %d (round %d), tie break %d\n",
+		g.winTerritory, g.winScore, g.maxRounds, g.round, g.tieBreak))
+
+	b.WriteString("captureRules: ")
+	b.WriteString(fmt.Sprintf("diagonals %t, length %d to %d, chains %t, home cells %t, through home cells %t\n",
+		g.captureDiagonals, g.captureMinLength, g.captureMaxLength, g.captureChains, g.captureHomeCells, g.captureThroughHomeCells))
+
 	b.WriteString("randomizeStartPos: ")
 	b.WriteString(fmt.Sprintf("%t\n", g.randomizeStartPos))
 
+	if g.gameMap != nil {
+		b.WriteString("map: ")
+		b.WriteString(fmt.Sprintf("%s\n", g.gameMap.Name))
+	}
+
+	b.WriteString("wallMode: ")
+	b.WriteString(fmt.Sprintf("%d\n", g.wallMode))
+
+	b.WriteString("wallDensity: ")
+	b.WriteString(fmt.Sprintf("%f\n", g.wallDensity))
+
+	b.WriteString("timer: ")
+	b.WriteString(fmt.Sprintf("mode %d, turn %s, clock %s + %s, timeout action %d, clocks %v\n",
+		g.timerMode, g.turnTime, g.clockTime, g.clockIncrement, g.timeoutAction, g.clocks[:g.playerCount]))
+
+	b.WriteString("pieces: ")
+	b.WriteString(fmt.Sprintf("distribution %d, queue length %d, %d left in bag\n",
+		g.pieceDistribution, g.pieceQueueLength, len(g.pieceBag)))
+
+	b.WriteString("mirrorPieces: ")
+	b.WriteString(fmt.Sprintf("%t\n", g.mirrorPieces))
+
+	b.WriteString("allowHold: ")
+	b.WriteString(fmt.Sprintf("%t\n", g.allowHold))
+
+	b.WriteString("draftSize: ")
+	b.WriteString(fmt.Sprintf("%d\n", g.draftSize))
+
+	b.WriteString("fogOfWar: ")
+	b.WriteString(fmt.Sprintf("%d\n", g.fogOfWar))
+
+	b.WriteString("simultaneous: ")
+	b.WriteString(fmt.Sprintf("%t\n", g.simultaneous))
+
+	b.WriteString("teams: ")
+	b.WriteString(fmt.Sprintf("%t (shared paths: %t)\n", g.teams, g.teamSharedPaths))
+
 	b.WriteString("lastBoardUpdate: ")
 	b.WriteString(fmt.Sprintf("%v\n", g.lastBoardUpdate))
 
+	b.WriteString("history: ")
+	b.WriteString(fmt.Sprintf("%d events\n", len(g.history)))
+
 	b.WriteString("Board:\n")
 	b.WriteString(g.board.String2D())
 
@@ -157,7 +292,10 @@ func (board GameBoard) String2D() string {
 			cell := board[r][c]
 
 			player := cell & CellMaskPlayer
-			if player == 0 {
+			if cell&CellFlagWall != 0 {
+				sb.WriteByte('#')
+				bytesWritten++
+			} else if player == 0 {
 				sb.WriteByte('.')
 				bytesWritten++
 			} else {
@@ -180,6 +318,21 @@ func (board GameBoard) String2D() string {
 				bytesWritten++
 			}
 
+			if cell&CellFlagShield != 0 {
+				sb.WriteByte('S')
+				bytesWritten++
+			}
+
+			if cell&CellFlagMultiplier != 0 {
+				sb.WriteByte('M')
+				bytesWritten++
+			}
+
+			if cell&CellFlagSpore != 0 {
+				sb.WriteByte('P')
+				bytesWritten++
+			}
+
 			sb.WriteByte(' ')
 			bytesWritten++
 			for bytesWritten < columnWidth {
@@ -193,6 +346,24 @@ func (board GameBoard) String2D() string {
 	return sb.String()
 }
 
+// newGameBoard returns an empty board with rows rows and cols columns
+func newGameBoard(rows, cols int) GameBoard {
+	board := make(GameBoard, rows)
+	for i := range board {
+		board[i] = make([]Cell, cols)
+	}
+	return board
+}
+
+// clone returns a deep copy of board
+func (board GameBoard) clone() GameBoard {
+	c := make(GameBoard, len(board))
+	for i := range board {
+		c[i] = append([]Cell(nil), board[i]...)
+	}
+	return c
+}
+
 // convert the row, column offset to an 1D index used by the client.
 func (board GameBoard) getIndex1D(r, c int) int {
 	return r*len(board[0]) + c
@@ -232,37 +403,46 @@ const (
 	CellFlagHome Cell = 0x100 << iota
 	CellFlagBonusBite
 	CellFlagBonusReroll
+	CellFlagWall   // impassable, never owned by a player
+	CellFlagHidden // only sent to clients, in place of cells hidden by fog of war
+	// special cells, see gameSpecialCells.go
+	CellFlagShield
+	CellFlagMultiplier
+	CellFlagSpore // the last bit of CellMaskFlags
 
 	CellMaskPlayer = 0x00ff
-	CellMaskFlags  = 0xff00
+	// Every bit of the flag byte is taken, so Cell has to be widened for new flags.
+	// Cells are sent to clients as JSON numbers, which fit up to 53 bits.
+	CellMaskFlags = 0xff00
 )
 
 // PieceMask is bitmask that represents a set of one or more squares
 // which are placed during a turn
-type PieceMask uint32
+type PieceMask uint64
 
 const maxPieceRotations = 4
+const maxPieceOrientations = 2 * maxPieceRotations // rotations and their mirror images
 
-// a piece must fit in a pieceMaskMaxLength by pieceMaskMaxLength square
+// a piece must fit in a pieceMaskMaxLength by pieceMaskMaxLength square. 7x7 masks use 49
+// bits, so they are still exact when read as JSON numbers by JavaScript.
 const (
-	pieceMaskMaxLength                 = 5
+	pieceMaskMaxLength                 = 7
 	pieceMaskSectionMask     PieceMask = (1 << pieceMaskMaxLength) - 1
 	pieceMaskFirstRowMask    PieceMask = pieceMaskSectionMask << (pieceMaskMaxLength * (pieceMaskMaxLength - 1))
 	pieceMaskFirstColumnMask PieceMask = (((1 << (pieceMaskMaxLength * pieceMaskMaxLength)) - 1) / ((1 << pieceMaskMaxLength) - 1)) << (pieceMaskMaxLength - 1)
 	pieceMaskFullMask        PieceMask = (1 << (pieceMaskMaxLength * pieceMaskMaxLength)) - 1
 )
 
+// legacyPieceMaskLength is the width of masks saved before masks were widened to
+// pieceMaskMaxLength, see resizePieceMask
+const legacyPieceMaskLength = 5
+
 const (
-	biteSmall PieceMask = 0b10000 << (pieceMaskMaxLength * (pieceMaskMaxLength - 1))
-	biteLarge PieceMask = 0b11000_11000 << (pieceMaskMaxLength * (pieceMaskMaxLength - 2))
+	biteSmall PieceMask = 0b1000000 << (pieceMaskMaxLength * (pieceMaskMaxLength - 1))
+	biteLarge PieceMask = 0b1100000_1100000 << (pieceMaskMaxLength * (pieceMaskMaxLength - 2))
 	biteNone  PieceMask = 0
 )
 
-var biteCosts = map[PieceMask]int{
-	biteSmall: biteSmall.CalcBiteCost(),
-	biteLarge: biteLarge.CalcBiteCost(),
-}
-
 // String returns a JavaScript (or Go) binary literal with bits grouped by row
 func (p PieceMask) String() string {
 	var sb strings.Builder
@@ -396,25 +576,98 @@ func (p PieceMask) shiftUp() PieceMask {
 	return shifted
 }
 
-// generateRotations returns all 4 90 degree rotations of a PieceMask normalized
-// to the top right.
-func (p PieceMask) generateRotations() [maxPieceRotations]PieceMask {
-	var rotations [maxPieceRotations]PieceMask
-	rotations[0] = p.shiftUp()
-	for i := 1; i < len(rotations); i++ {
-		rotations[i] = rotations[i-1].rotate90()
+// resizePieceMask converts p from a length by length mask to a pieceMaskMaxLength by
+// pieceMaskMaxLength mask, keeping the squares in the same rows and columns. Squares
+// that don't fit are dropped.
+func resizePieceMask(p PieceMask, length int) PieceMask {
+	var resized PieceMask
+	for r := 0; r < length; r++ {
+		for c := 0; c < length; c++ {
+			if p&(1<<((length-1-r)*length+(length-1-c))) != 0 {
+				resized |= maskAt(r, c)
+			}
+		}
+	}
+	return resized
+}
+
+// mirror returns a PieceMask flipped left to right and shifted to the top left
+func (p PieceMask) mirror() PieceMask {
+	var mirrored PieceMask
+	for r := 0; r < pieceMaskMaxLength; r++ {
+		for c := 0; c < pieceMaskMaxLength; c++ {
+			if p.has(r, c) {
+				mirrored |= maskAt(r, pieceMaskMaxLength-1-c)
+			}
+		}
+	}
+	return mirrored.shiftUp()
+}
+
+// generateRotations returns the 4 90 degree rotations of a PieceMask normalized
+// to the top left. Rotations that repeat an earlier one are left as 0, and so is
+// the second half, which holds mirror images (see generateOrientations).
+func (p PieceMask) generateRotations() [maxPieceOrientations]PieceMask {
+	var masks [maxPieceOrientations]PieceMask
+	rotation := p.shiftUp()
+	for i := 0; i < maxPieceRotations; i++ {
+		if !slices.Contains(masks[:i], rotation) {
+			masks[i] = rotation
+		}
+		rotation = rotation.rotate90()
+	}
+	return masks
+}
+
+// generateOrientations returns the rotations of a PieceMask followed by the rotations
+// of its mirror image, normalized to the top left. Orientations that repeat an earlier
+// one are left as 0, so the mirror images are all 0 for symmetric pieces.
+func (p PieceMask) generateOrientations() [maxPieceOrientations]PieceMask {
+	masks := p.generateRotations()
+	rotation := p.mirror()
+	for i := maxPieceRotations; i < maxPieceOrientations; i++ {
+		if !slices.Contains(masks[:i], rotation) {
+			masks[i] = rotation
+		}
+		rotation = rotation.rotate90()
 	}
-	return rotations
+	return masks
 }
 
 // Piece represents a game piece played during a turn
 type Piece struct {
-	// Mask contains all 90 degree rotations of a PieceMask
-	Masks  [maxPieceRotations]PieceMask `json:"masks"`
+	// Masks contains the distinct 90 degree rotations of a PieceMask, followed by the
+	// rotations of its mirror image if the game allows mirrored pieces. Unused masks are 0.
+	Masks  [maxPieceOrientations]PieceMask `json:"masks"`
 	Weight float64
 }
 
+// withMirrors returns pieces with mirror images added to their orientations. Pieces that
+// are mirror images of each other, like the l block and reverse l block, become a single
+// piece with their weights added together.
+func withMirrors(pieces []Piece) []Piece {
+	var mirrored []Piece
+	for _, p := range pieces {
+		orientations := p.Masks[0].generateOrientations()
+		merged := false
+		for i := range mirrored {
+			if mirrored[i].has(orientations[0]) {
+				mirrored[i].Weight += p.Weight
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			mirrored = append(mirrored, Piece{orientations, p.Weight})
+		}
+	}
+	return mirrored
+}
+
 func (p Piece) has(mask PieceMask) bool {
+	if mask == 0 {
+		return false
+	}
 	for _, m := range p.Masks {
 		if m == mask {
 			return true
@@ -425,51 +678,129 @@ func (p Piece) has(mask PieceMask) bool {
 
 var gbDefaultPieces = []Piece{
 	// single square
-	{PieceMask(0b10000).generateRotations(), 100},
+	{PieceMask(0b1000000).generateRotations(), 100},
 	// two squares in a row
-	{PieceMask(0b10000_10000).generateRotations(), 40},
+	{PieceMask(0b1000000_1000000).generateRotations(), 40},
 	// three squares in a row
-	{PieceMask(0b10000_10000_10000).generateRotations(), 100},
+	{PieceMask(0b1000000_1000000_1000000).generateRotations(), 100},
 	// four squares in a row
-	{PieceMask(0b10000_10000_10000_10000).generateRotations(), 12},
+	{PieceMask(0b1000000_1000000_1000000_1000000).generateRotations(), 12},
 	// 2x2 square
-	{PieceMask(0b11000_11000).generateRotations(), 100},
+	{PieceMask(0b1100000_1100000).generateRotations(), 100},
 	// corner
-	{PieceMask(0b10000_11000).generateRotations(), 100},
+	{PieceMask(0b1000000_1100000).generateRotations(), 100},
 	// t block
-	{PieceMask(0b11100_01000_00000).generateRotations(), 20},
+	{PieceMask(0b1110000_0100000_0000000).generateRotations(), 20},
 	// z block
-	{PieceMask(0b11000_01100_00000).generateRotations(), 12},
+	{PieceMask(0b1100000_0110000_0000000).generateRotations(), 12},
 	// reverse z block
-	{PieceMask(0b01100_11000_00000).generateRotations(), 12},
+	{PieceMask(0b0110000_1100000_0000000).generateRotations(), 12},
 	// l block
-	{PieceMask(0b10000_10000_11000).generateRotations(), 8},
+	{PieceMask(0b1000000_1000000_1100000).generateRotations(), 8},
 	// reverse l block
-	{PieceMask(0b11100_00100_00000).generateRotations(), 8},
+	{PieceMask(0b1110000_0010000_0000000).generateRotations(), 8},
 	// b
-	{PieceMask(0b11100_11000_00000).generateRotations(), 4},
+	{PieceMask(0b1110000_1100000_0000000).generateRotations(), 4},
 	// c
-	{PieceMask(0b11100_10100_00000).generateRotations(), 4},
+	{PieceMask(0b1110000_1010000_0000000).generateRotations(), 4},
 	// d
-	{PieceMask(0b01100_11100_00000).generateRotations(), 4},
+	{PieceMask(0b0110000_1110000_0000000).generateRotations(), 4},
 	// skip line
-	{PieceMask(0b10000_00000_10000).generateRotations(), 4},
+	{PieceMask(0b1000000_0000000_1000000).generateRotations(), 4},
 	// skip pyramid
-	{PieceMask(0b10000_01000_10000).generateRotations(), 1},
+	{PieceMask(0b1000000_0100000_1000000).generateRotations(), 1},
 	// skip 5
-	{PieceMask(0b10100_01000_10100).generateRotations(), 1},
+	{PieceMask(0b1010000_0100000_1010000).generateRotations(), 1},
 	// 2 diag
-	{PieceMask(0b10000_01000_00000).generateRotations(), 5},
+	{PieceMask(0b1000000_0100000_0000000).generateRotations(), 5},
 	// 3 diag
-	{PieceMask(0b10000_01000_00100).generateRotations(), 3},
+	{PieceMask(0b1000000_0100000_0010000).generateRotations(), 3},
 	// 4 diag
-	{PieceMask(0b10000_01000_00100_00010).generateRotations(), 2},
+	{PieceMask(0b1000000_0100000_0010000_0001000).generateRotations(), 2},
 }
 
 type WinLossDraw struct {
 	W, L, D int
 }
 
+// Game event types recorded in Game.history
+const (
+	gameEventStartGame    = "start_game"
+	gameEventPlacePiece   = "place_piece"
+	gameEventPlaceBite    = "place_bite"
+	gameEventReroll       = "reroll"
+	gameEventHoldPiece    = "hold_piece"
+	gameEventSkipTurn     = "skip_turn"
+	gameEventForfeitGame  = "forfeit_game"
+	gameEventResetGame    = "reset_game"
+	gameEventUndo         = "undo"
+	gameEventStalemate    = "stalemate"
+	gameEventResolveRound = "resolve_round" // every move of a round of simultaneous turns has been applied
+	gameEventRoundLimit   = "round_limit"   // the last round was played, see Game.maxRounds
+	gameEventWinCondition = "win_condition" // a player (or team) reached Game.winTerritory or Game.winScore
+)
+
+// GameResources is a snapshot of a single player's resources
+type GameResources struct {
+	Score            int `json:"score"`
+	Bites            int `json:"bites"`
+	Rerolls          int `json:"rerolls"`
+	NewCellsForBites int `json:"new_cells_for_bites"`
+}
+
+// GameEvent records an action taken during a game. Together with the board
+// from the most recent start_game or reset_game event, the events that follow
+// are enough to rebuild the state of the game.
+type GameEvent struct {
+	Type   string `json:"type"`
+	Player int    `json:"player"`           // index into Game.players, -1 if not caused by a player
+	Forced bool   `json:"forced,omitempty"` // true for turns skipped by the server because the player couldn't move
+	// Timeout is true for skips and forfeits caused by the player running out of time
+	Timeout bool `json:"timeout,omitempty"`
+	// Conflict is true for simultaneous moves that were dropped because they overlapped
+	// another player's move or were no longer legal
+	Conflict bool `json:"conflict,omitempty"`
+	// Mask and Index describe the piece or bite placed. For rerolls, Mask is the new piece.
+	// Index is -1 for events that don't place anything on the board.
+	Mask     PieceMask     `json:"mask"`
+	Index    int           `json:"index"`
+	Captured []int         `json:"captured,omitempty"` // 1D indexes of captured cells
+	Orphaned []int         `json:"orphaned,omitempty"` // 1D indexes of cells that were removed
+	Board    GameBoard     `json:"board,omitempty"`    // starting board for start_game and reset_game
+	Before   GameResources `json:"before"`
+	After    GameResources `json:"after"`
+	Time     time.Time     `json:"time"`
+}
+
+// playerResources returns the current resources for the player at playerIndex
+func (game *Game) playerResources(playerIndex int) GameResources {
+	if playerIndex < 0 || playerIndex >= game.playerCount {
+		return GameResources{}
+	}
+	return GameResources{
+		Score:            game.scores[playerIndex],
+		Bites:            game.bites[playerIndex],
+		Rerolls:          game.rerolls[playerIndex],
+		NewCellsForBites: game.newCellsForBites[playerIndex],
+	}
+}
+
+// addEvent timestamps event and appends it to the game history
+func (game *Game) addEvent(event GameEvent) {
+	event.Time = time.Now()
+	game.history = append(game.history, event)
+}
+
+// addBoardEvent records the start of a new game, including a copy of the board
+func (game *Game) addBoardEvent(eventType string) {
+	game.addEvent(GameEvent{
+		Type:   eventType,
+		Player: -1,
+		Index:  -1,
+		Board:  game.board.clone(),
+	})
+}
+
 // addDraw adds a draw to the record for every player if all players have at
 // least minScore (prevents adding a draw when resetting a new game)
 func (game *Game) addDraw(minScore int) {
@@ -483,11 +814,11 @@ func (game *Game) addDraw(minScore int) {
 	}
 }
 
-// addWin adds a win to the record of player at index playerIndex and
-// adds a loss to the other players' records
+// addWin adds a win to the record of player at index playerIndex and their
+// teammates, and adds a loss to the other players' records
 func (game *Game) addWin(playerIndex int) {
 	for i := 0; i < game.playerCount; i++ {
-		if i == playerIndex {
+		if game.teamOf(i) == game.teamOf(playerIndex) {
 			game.winLossDrawRecord[i].W++
 		} else {
 			game.winLossDrawRecord[i].L++
@@ -495,8 +826,76 @@ func (game *Game) addWin(playerIndex int) {
 	}
 }
 
+// addResult records a win if every player in winners is on the same team, or a draw for
+// every player in winners if not. Everyone else gets a loss.
+func (game *Game) addResult(winners []int) {
+	if !slices.ContainsFunc(winners, func(i int) bool { return game.teamOf(i) != game.teamOf(winners[0]) }) {
+		game.addWin(winners[0])
+		return
+	}
+	for i := 0; i < game.playerCount; i++ {
+		if slices.Contains(winners, i) {
+			game.winLossDrawRecord[i].D++
+		} else {
+			game.winLossDrawRecord[i].L++
+		}
+	}
+}
+
+// rankPlayers returns the player indexes ordered by score, highest first. With teams,
+// players are ordered by their team's score first.
+// Players with the same score keep their turn order.
+func (game *Game) rankPlayers() []int {
+	ranking := make([]int, game.playerCount)
+	for i := range ranking {
+		ranking[i] = i
+	}
+	slices.SortStableFunc(ranking, func(a, b int) int {
+		if d := game.teamScore(b) - game.teamScore(a); d != 0 {
+			return d
+		}
+		return game.scores[b] - game.scores[a]
+	})
+	return ranking
+}
+
+// teamOf returns the team of playerIndex. Without teams every player is their own team.
+func (game *Game) teamOf(playerIndex int) int {
+	if !game.teams {
+		return playerIndex
+	}
+	return playerIndex % gbTeamCount
+}
+
+// isFriendly returns true if cells owned by other count as player's own for captures:
+// other is player or, with teams, a teammate of player
+func (game *Game) isFriendly(player, other Cell) bool {
+	if player == 0 || other == 0 {
+		return false
+	}
+	return game.teamOf(int(player)-1) == game.teamOf(int(other)-1)
+}
+
+// teamScore returns the combined score of playerIndex and their teammates
+func (game *Game) teamScore(playerIndex int) int {
+	score := 0
+	for i := 0; i < game.playerCount; i++ {
+		if game.teamOf(i) == game.teamOf(playerIndex) {
+			score += game.scores[i]
+		}
+	}
+	return score
+}
+
 // forfeits a game
 func (game *Game) forfeitGame(whoami Player) error {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	if game.isOver {
+		return errors.New("Invalid update: game over")
+	}
+
 	playerIndex := -1
 	for i := 0; i < game.playerCount; i++ {
 		if game.players[i].id == whoami.id {
@@ -507,30 +906,61 @@ func (game *Game) forfeitGame(whoami Player) error {
 	if playerIndex < 0 {
 		return errors.New("Count not find player in forfeitGame: " + whoami.id.String())
 	}
+	game.forfeitPlayer(playerIndex, false)
+	return nil
+}
+
+// forfeitPlayer removes the home cells of playerIndex along with any cells that are
+// orphaned as a result. timeout is set if the player ran out of time.
+// The caller must hold game.mu.
+func (game *Game) forfeitPlayer(playerIndex int, timeout bool) {
 	playerCell := Cell(playerIndex + 1)
 	isPlayersTurn := playerIndex == game.turn
+	before := game.playerResources(playerIndex)
+	var removed []int
 
 	game.lastBoardUpdate = game.lastBoardUpdate[:0]
 	for r := 0; r < len(game.board); r++ {
 		for c := 0; c < len(game.board[0]); c++ {
 			cell := game.board[r][c]
-			if cell&CellMaskPlayer == playerCell && cell&CellFlagHome != 0 {
-				game.addPieceToBoard(0, game.board.getIndex1D(r, c), biteSmall)
-				game.lastBoardUpdate = append(game.lastBoardUpdate, game.handleOrphanedCells()...)
+			if cell&CellMaskPlayer == playerCell && isHomeCell(cell) {
+				index := game.board.getIndex1D(r, c)
+				game.board[r][c] &= CellMaskFlags
+				orphaned := game.handleOrphanedCells()
+				game.lastBoardUpdate = append(game.lastBoardUpdate, orphaned...)
+				removed = append(removed, index)
+				removed = append(removed, orphaned...)
 			}
 		}
 	}
 	game.updateScores()
-
-	if isPlayersTurn || game.isOver {
-		game.advanceTurn()
-		game.setNextPiece()
+	game.clearUndo()
+	game.addEvent(GameEvent{
+		Type:     gameEventForfeitGame,
+		Player:   playerIndex,
+		Timeout:  timeout,
+		Index:    -1,
+		Orphaned: removed,
+		Before:   before,
+		After:    game.playerResources(playerIndex),
+	})
+
+	if game.simultaneous && !game.isOver {
+		game.roundMoves[playerIndex] = nil
+		game.resolveRoundIfReady()
+	} else if isPlayersTurn || game.isOver {
+		game.endTurn()
 	}
-	return nil
+}
+
+// newGameRand returns the random source for a game with the given seed
+func newGameRand(seed uint64) (*rand.PCG, *rand.Rand) {
+	src := rand.NewPCG(seed, seed)
+	return src, rand.New(src)
 }
 
 // setStartingPositions places home cells on the board for each player.
-func setStartingPositions(board GameBoard, playerCount int, randomize bool) {
+func setStartingPositions(board GameBoard, playerCount int, randomize bool, rng *rand.Rand) {
 	maxR := len(board) - 1
 	maxC := len(board[0]) - 1
 
@@ -550,7 +980,7 @@ func setStartingPositions(board GameBoard, playerCount int, randomize bool) {
 	}
 
 	if randomize {
-		rand.Shuffle(len(startIndices), func(i, j int) {
+		rng.Shuffle(len(startIndices), func(i, j int) {
 			startIndices[i], startIndices[j] = startIndices[j], startIndices[i]
 		})
 	}
@@ -562,30 +992,118 @@ func setStartingPositions(board GameBoard, playerCount int, randomize bool) {
 	}
 }
 
-// setBiteFlagPositions places a bonus bite cell in each corner of the board
-func setBiteFlagPositions(board GameBoard) {
-	maxR := len(board) - 1
-	maxC := len(board[0]) - 1
+// isNearHome returns true if a home cell is within distance rows and columns of the cell at r, c
+func isNearHome(board GameBoard, r, c, distance int) bool {
+	for hr := max(r-distance, 0); hr <= min(r+distance, len(board)-1); hr++ {
+		for hc := max(c-distance, 0); hc <= min(c+distance, len(board[0])-1); hc++ {
+			if board[hr][hc]&CellFlagHome != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	board[0][0] |= CellFlagBonusBite
-	board[0][maxC] |= CellFlagBonusBite
-	board[maxR][0] |= CellFlagBonusBite
-	board[maxR][maxC] |= CellFlagBonusBite
+// canPlaceWall returns true if the cell at r, c is empty, unflagged and not too close to a home cell
+func canPlaceWall(board GameBoard, r, c int) bool {
+	return board[r][c] == 0 && !isNearHome(board, r, c, gbWallHomeClearance)
 }
 
-// setRerollFlagPositions places bonus reroll cells randomly on the board
-func setRerollFlagPositions(board GameBoard, count int) {
-	for i := 0; i < count; i++ {
-		var r, c int
-		for attempt := 0; attempt < 5; attempt++ {
-			r = rand.Intn(len(board))
-			c = rand.Intn(len(board[0]))
-			if board[r][c]&CellMaskFlags == 0 {
-				break
+// areHomesConnected returns true if every home cell can reach every other home cell without
+// crossing a wall
+func areHomesConnected(board GameBoard) bool {
+	homes := 0
+	var queue []int
+	for r := range board {
+		for c := range board[r] {
+			if board[r][c]&CellFlagHome != 0 {
+				homes++
+				if len(queue) == 0 {
+					queue = append(queue, board.getIndex1D(r, c))
+				}
+			}
+		}
+	}
+
+	visited := make([]bool, len(board)*len(board[0]))
+	if len(queue) > 0 {
+		visited[queue[0]] = true
+	}
+	for len(queue) > 0 {
+		r, c := board.getIndex2D(queue[0])
+		queue = queue[1:]
+		if board[r][c]&CellFlagHome != 0 {
+			homes--
+		}
+		for _, d := range []Direction{directionUp, directionRight, directionDown, directionLeft} {
+			nr, nc := r+d.row, c+d.col
+			if nr < 0 || nc < 0 || nr >= len(board) || nc >= len(board[0]) || board[nr][nc]&CellFlagWall != 0 {
+				continue
+			}
+			if i := board.getIndex1D(nr, nc); !visited[i] {
+				visited[i] = true
+				queue = append(queue, i)
 			}
 		}
-		board[r][c] |= CellFlagBonusReroll
 	}
+	return homes == 0
+}
+
+// setWallPositions covers about density of the board with walls. Walls are kept away from
+// home cells and other flagged cells. If no layout that keeps the home cells connected is
+// found, the board is left without walls.
+func setWallPositions(board GameBoard, mode int, density float64, rng *rand.Rand) {
+	if mode == gameWallsNone || density <= 0 {
+		return
+	}
+	rows, cols := len(board), len(board[0])
+	count := int(float64(rows*cols) * density)
+
+	for attempt := 0; attempt < gbWallAttempts; attempt++ {
+		placed := 0
+		for tries := 0; placed < count && tries < count*4; tries++ {
+			r, c := rng.IntN(rows), rng.IntN(cols)
+			cells := [][2]int{{r, c}}
+			if mode == gameWallsSymmetric {
+				cells = append(cells, [2]int{rows - 1 - r, c}, [2]int{r, cols - 1 - c}, [2]int{rows - 1 - r, cols - 1 - c})
+			}
+			if slices.ContainsFunc(cells, func(cell [2]int) bool {
+				return board[cell[0]][cell[1]] != CellFlagWall && !canPlaceWall(board, cell[0], cell[1])
+			}) {
+				continue
+			}
+			for _, cell := range cells {
+				if board[cell[0]][cell[1]] == 0 {
+					board[cell[0]][cell[1]] = CellFlagWall
+					placed++
+				}
+			}
+		}
+		if areHomesConnected(board) {
+			return
+		}
+
+		// try again from an empty board
+		for r := range board {
+			for c := range board[r] {
+				board[r][c] &^= CellFlagWall
+			}
+		}
+	}
+}
+
+// buildBoard returns a new board for game with home cells, walls and bonus cells placed
+func (game *Game) buildBoard() GameBoard {
+	if game.gameMap != nil {
+		return game.gameMap.newBoard(game.playerCount, game.randomizeStartPos, game.rng)
+	}
+	board := newGameBoard(game.rowCount, game.colCount)
+	setStartingPositions(board, game.playerCount, game.randomizeStartPos, game.rng)
+	setBiteFlagPositions(board, game.bonusBiteCells, game.bonusPlacement, game.bonusHomeClearance, game.rng)
+	setWallPositions(board, game.wallMode, game.wallDensity, game.rng)
+	setRerollFlagPositions(board, game.bonusRerollCells, game.bonusPlacement, game.bonusHomeClearance, game.rng)
+	setSpecialFlagPositions(board, game.shieldCells, game.multiplierCells, game.sporeCells, game.bonusPlacement, game.bonusHomeClearance, game.rng)
+	return board
 }
 
 // createGame creates a new game and returns the uuid for it.
@@ -593,19 +1111,63 @@ func setRerollFlagPositions(board GameBoard, count int) {
 // optional arguments can be passed in opts
 // fixme, move activeLobbies
 func createGame(fromLobby *Lobby, opts map[string]any) (*Game, error) {
-	var size int = gbDefaultSize
+	var rows int = gbDefaultRows
+	var cols int = gbDefaultCols
 	var randomizeStartPos bool = gbDefaultRandomizeStartPos
 	var startBites int = gbDefaultStartBites
 	var startRerolls int = gbDefaultStartRerolls
-	var bonusBiteCells bool = gbDefaultHasBonusBiteCells
+	var bonusBiteCells int = gbDefaultBonusBiteCells
 	var bonusRerollCells int = gbDefaultBonusRerollCells
+	var bonusBiteAward int = gbDefaultBonusBiteAward
+	var bonusRerollAward int = gbDefaultBonusRerollAward
+	var bonusPlacement int = gbDefaultBonusPlacement
+	var bonusHomeClearance int = gbDefaultBonusHomeClearance
+	var shieldCells int = gbDefaultShieldCells
+	var multiplierCells int = gbDefaultMultiplierCells
+	var sporeCells int = gbDefaultSporeCells
 	var newBitesFreqFactor float64 = 1.0
 	var captureMode int
+	var captureDiagonals bool = gbDefaultCaptureDiagonals
+	var captureMinLength int = gbDefaultCaptureMinLength
+	var captureMaxLength int = gbDefaultCaptureMaxLength
+	var captureChains bool = gbDefaultCaptureChains
+	var captureHomeCells bool = gbDefaultCaptureHomeCells
+	var captureThroughHomeCells bool = gbDefaultCaptureThroughHomeCells
+	var winTerritory int = gbDefaultWinTerritory
+	var winScore int = gbDefaultWinScore
+	var maxRounds int = gbDefaultMaxRounds
+	var tieBreak int = gbDefaultTieBreak
 	var pieces []Piece = gbDefaultPieces
+	var biteShapes []Bite = gbDefaultBites
+	var seed uint64 = rand.Uint64()
+	var wallMode int = gbDefaultWallMode
+	var wallDensity float64 = gbDefaultWallDensity
+	var gameMap *GameMap
+	var teams bool
+	var teamSharedPaths bool
+	var timerMode int = gbDefaultTimerMode
+	var turnTime time.Duration = gbDefaultTurnTime
+	var clockTime time.Duration = gbDefaultClockTime
+	var clockIncrement time.Duration = gbDefaultClockIncrement
+	var timeoutAction int = gbDefaultTimeoutAction
+	var fogOfWar int = gbDefaultFogOfWar
+	var simultaneous bool
+	var pieceDistribution int = gbDefaultPieceDistribution
+	var pieceQueueLength int = gbDefaultPieceQueueLength
+	var allowHold bool = gbDefaultAllowHold
+	var draftSize int = gbDefaultDraftSize
+	var mirrorPieces bool
+	var playerCap int // 0 for the lobby's player cap
 
 	// parse options
 	if val, ok := opts["size"].(int); ok {
-		size = val
+		rows, cols = val, val
+	}
+	if val, ok := opts["rows"].(int); ok {
+		rows = val
+	}
+	if val, ok := opts["cols"].(int); ok {
+		cols = val
 	}
 	if val, ok := opts["randomize_start_positions"].(bool); ok {
 		randomizeStartPos = val
@@ -616,12 +1178,42 @@ func createGame(fromLobby *Lobby, opts map[string]any) (*Game, error) {
 	if val, ok := opts["starting_rerolls"].(int); ok {
 		startRerolls = val
 	}
-	if val, ok := opts["has_bonus_bite_cells"].(bool); ok {
+	if val, ok := opts["has_bonus_bite_cells"].(bool); ok && !val {
+		bonusBiteCells = 0 // from before the number of bonus bite cells was an option
+	}
+	if val, ok := opts["bonus_bite_cells"].(int); ok {
 		bonusBiteCells = val
 	}
 	if val, ok := opts["bonus_reroll_cells"].(int); ok {
 		bonusRerollCells = val
 	}
+	if val, ok := opts["bonus_bite_award"].(int); ok {
+		bonusBiteAward = val
+	}
+	if val, ok := opts["bonus_reroll_award"].(int); ok {
+		bonusRerollAward = val
+	}
+	if val, ok := opts["bonus_placement"].(int); ok {
+		bonusPlacement = val
+	}
+	if val, ok := opts["bonus_home_clearance"].(int); ok {
+		bonusHomeClearance = val
+	}
+	if err := validateBonusOptions(bonusBiteCells, bonusRerollCells, bonusBiteAward, bonusRerollAward, bonusPlacement, bonusHomeClearance); err != nil {
+		return nil, err
+	}
+	if val, ok := opts["shield_cells"].(int); ok {
+		shieldCells = val
+	}
+	if val, ok := opts["multiplier_cells"].(int); ok {
+		multiplierCells = val
+	}
+	if val, ok := opts["spore_cells"].(int); ok {
+		sporeCells = val
+	}
+	if err := validateSpecialCells(shieldCells, multiplierCells, sporeCells); err != nil {
+		return nil, err
+	}
 	if val, ok := opts["new_bites_freq_factor"].(float64); ok {
 		newBitesFreqFactor = val
 	}
@@ -631,17 +1223,138 @@ func createGame(fromLobby *Lobby, opts map[string]any) (*Game, error) {
 		}
 		captureMode = val
 	}
+	if val, ok := opts["capture_diagonals"].(bool); ok {
+		captureDiagonals = val
+	}
+	if val, ok := opts["capture_min_length"].(int); ok {
+		captureMinLength = val
+	}
+	if val, ok := opts["capture_max_length"].(int); ok {
+		captureMaxLength = val
+	}
+	if err := validateCaptureLengths(captureMinLength, captureMaxLength); err != nil {
+		return nil, err
+	}
+	if val, ok := opts["capture_chains"].(bool); ok {
+		captureChains = val
+	}
+	if val, ok := opts["capture_home_cells"].(bool); ok {
+		captureHomeCells = val
+	}
+	if val, ok := opts["capture_through_home_cells"].(bool); ok {
+		captureThroughHomeCells = val
+	}
+	if val, ok := opts["win_territory"].(int); ok {
+		winTerritory = val
+	}
+	if val, ok := opts["win_score"].(int); ok {
+		winScore = val
+	}
+	if val, ok := opts["max_rounds"].(int); ok {
+		maxRounds = val
+	}
+	if val, ok := opts["tie_break"].(int); ok {
+		tieBreak = val
+	}
 	if val, ok := opts["pieces"].([]Piece); ok {
 		if len(pieces) > 0 {
 			pieces = val
 		}
 	}
+	if val, ok := opts["bites"].([]Bite); ok {
+		if len(val) > 0 {
+			biteShapes = val
+		}
+	}
+	if val, ok := opts["mirror_pieces"].(bool); ok {
+		mirrorPieces = val
+	}
+	if val, ok := opts["piece_distribution"].(int); ok {
+		pieceDistribution = val
+	}
+	if val, ok := opts["piece_queue_length"].(int); ok {
+		pieceQueueLength = val
+	}
+	if err := validatePieceOptions(pieceDistribution, pieceQueueLength); err != nil {
+		return nil, err
+	}
+	if val, ok := opts["allow_hold"].(bool); ok {
+		allowHold = val
+	}
+	if val, ok := opts["draft_size"].(int); ok {
+		if err := validateDraftSize(val); err != nil {
+			return nil, err
+		}
+		draftSize = val
+	}
+	if val, ok := opts["seed"].(uint64); ok {
+		seed = val
+	}
+	if val, ok := opts["walls"].(int); ok {
+		if val < 0 || val >= gameWallsMax {
+			return nil, errors.New("Invalid walls parameter")
+		}
+		wallMode = val
+	}
+	if val, ok := opts["wall_density"].(float64); ok {
+		if val < 0 || val > gbMaxWallDensity {
+			return nil, errors.New("Invalid wall_density parameter")
+		}
+		wallDensity = val
+	}
+	if val, ok := opts["max_players"].(int); ok {
+		playerCap = val
+	}
+	if val, ok := opts["teams"].(bool); ok {
+		teams = val
+	}
+	if val, ok := opts["team_shared_paths"].(bool); ok {
+		teamSharedPaths = val
+	}
+	if val, ok := opts["timer"].(int); ok {
+		timerMode = val
+	}
+	if val, ok := opts["turn_time"].(int); ok {
+		turnTime = time.Duration(val) * time.Second
+	}
+	if val, ok := opts["clock_time"].(int); ok {
+		clockTime = time.Duration(val) * time.Second
+	}
+	if val, ok := opts["clock_increment"].(int); ok {
+		clockIncrement = time.Duration(val) * time.Second
+	}
+	if val, ok := opts["timeout_action"].(int); ok {
+		timeoutAction = val
+	}
+	if val, ok := opts["simultaneous"].(bool); ok {
+		simultaneous = val
+	}
+	timerMode, err := validateSimultaneous(simultaneous, timerMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTimerOptions(timerMode, turnTime, clockTime, clockIncrement, timeoutAction); err != nil {
+		return nil, err
+	}
+	if val, ok := opts["fog_of_war"].(int); ok {
+		if err := validateFogOfWar(val); err != nil {
+			return nil, err
+		}
+		fogOfWar = val
+	}
+	if val, ok := opts["map"].(string); ok && val != "" {
+		gameMap, ok = gameMaps[val]
+		if !ok {
+			return nil, errors.New("Unknown map: " + val)
+		}
+		rows, cols = len(gameMap.board), len(gameMap.board[0])
+	}
 
 	// validate args
 	if fromLobby == nil {
 		return nil, errors.New("createGame lobby cannot be nil")
 	}
-	if size <= gbMinSize || size > gbMaxSize {
+	if rows <= gbMinSize || rows > gbMaxSize || cols <= gbMinSize || cols > gbMaxSize {
 		return nil, errors.New("createGame size parameter out of bounds")
 	}
 
@@ -659,18 +1372,29 @@ func createGame(fromLobby *Lobby, opts map[string]any) (*Game, error) {
 	if playerCount < 2 {
 		return nil, errors.New("A game requires at least two players")
 	}
-
-	// Build the board
-	board := make(GameBoard, size)
-	for i := range board {
-		board[i] = make([]Cell, size)
+	if playerCap == 0 {
+		playerCap = fromLobby.playerLimit()
+	}
+	if playerCap < 2 || playerCap > maxPlayers {
+		return nil, errors.New("Invalid max_players parameter")
+	}
+	if playerCount > playerCap {
+		return nil, fmt.Errorf("Too many players: %d, the game allows %d", playerCount, playerCap)
+	}
+	if gameMap == nil && playerCount > gbCornerPlayers && (rows < gbMinSizeManyPlayers || cols < gbMinSizeManyPlayers) {
+		return nil, fmt.Errorf("Games with more than %d players need a board of at least %dx%d", gbCornerPlayers, gbMinSizeManyPlayers, gbMinSizeManyPlayers)
 	}
-	setStartingPositions(board, playerCount, randomizeStartPos)
-	if bonusBiteCells {
-		setBiteFlagPositions(board)
+	if teams && (playerCount < 2*gbTeamCount || playerCount%gbTeamCount != 0) {
+		return nil, errors.New("Team play requires an even number of players, at least four")
 	}
-	if bonusRerollCells > 0 {
-		setRerollFlagPositions(board, bonusRerollCells)
+	if gameMap != nil && !gameMap.supports(playerCount) {
+		return nil, fmt.Errorf("Map %s does not support %d players", gameMap.Name, playerCount)
+	}
+
+	rngSource, rng := newGameRand(seed)
+
+	if mirrorPieces {
+		pieces = withMirrors(pieces)
 	}
 
 	// adjust game options
@@ -678,7 +1402,7 @@ func createGame(fromLobby *Lobby, opts map[string]any) (*Game, error) {
 	if newBitesFreqFactor <= 0 {
 		cellsForBitesThreshold = -1
 	} else {
-		cellsForBitesThreshold = int(float64(len(board)*2) / newBitesFreqFactor)
+		cellsForBitesThreshold = int(float64(rows+cols) / newBitesFreqFactor)
 	}
 
 	// create the game
@@ -692,22 +1416,69 @@ func createGame(fromLobby *Lobby, opts map[string]any) (*Game, error) {
 		startBites:                startBites,
 		startRerolls:              startRerolls,
 		bonusBiteCells:            bonusBiteCells,
+		biteShapes:                biteShapes,
 		bonusRerollCells:          bonusRerollCells,
-		board:                     board,
-		rowCount:                  len(board),
-		colCount:                  len(board[0]),
+		bonusBiteAward:            bonusBiteAward,
+		bonusRerollAward:          bonusRerollAward,
+		bonusPlacement:            bonusPlacement,
+		bonusHomeClearance:        bonusHomeClearance,
+		shieldCells:               shieldCells,
+		multiplierCells:           multiplierCells,
+		sporeCells:                sporeCells,
+		rowCount:                  rows,
+		colCount:                  cols,
 		pieces:                    pieces,
+		mirrorPieces:              mirrorPieces,
+		pieceDistribution:         pieceDistribution,
+		pieceQueueLength:          pieceQueueLength,
+		allowHold:                 allowHold && draftSize == 1,
+		draftSize:                 draftSize,
 		captureMode:               captureMode,
+		captureDiagonals:          captureDiagonals,
+		captureMinLength:          captureMinLength,
+		captureMaxLength:          captureMaxLength,
+		captureChains:             captureChains,
+		captureHomeCells:          captureHomeCells,
+		captureThroughHomeCells:   captureThroughHomeCells,
+		winTerritory:              winTerritory,
+		winScore:                  winScore,
+		maxRounds:                 maxRounds,
+		tieBreak:                  tieBreak,
 		randomizeStartPos:         randomizeStartPos,
+		wallMode:                  wallMode,
+		wallDensity:               wallDensity,
+		gameMap:                   gameMap,
+		teams:                     teams,
+		teamSharedPaths:           teams && teamSharedPaths,
+		timerMode:                 timerMode,
+		turnTime:                  turnTime,
+		clockTime:                 clockTime,
+		clockIncrement:            clockIncrement,
+		timeoutAction:             timeoutAction,
+		fogOfWar:                  fogOfWar,
+		simultaneous:              simultaneous,
 		created:                   time.Now(),
 		fromLobby:                 fromLobby.name,
+		seed:                      seed,
+		rng:                       rng,
+		rngSource:                 rngSource,
 		uuid:                      gameId,
 	}
+	game.board = game.buildBoard()
 	game.resetNewCellsForBites()
 	game.resetBites()
 	game.resetRerolls()
 	game.updateScores()
+	startScore, startTerritory := game.leadingShare()
+	if err := validateWinConditions(winTerritory, winScore, maxRounds, tieBreak, startScore, startTerritory); err != nil {
+		return nil, err
+	}
 	game.setNextPiece()
+	if game.simultaneous {
+		game.startRound()
+	}
+	game.resetTimers()
+	game.addBoardEvent(gameEventStartGame)
 	activeGames[gameId] = game
 
 	return game, nil
@@ -717,39 +1488,35 @@ func (game *Game) resetGame() {
 	game.mu.Lock()
 	defer game.mu.Unlock()
 
-	// Build the board
-	size := len(game.board)
-	board := make(GameBoard, size)
-	for i := range board {
-		board[i] = make([]Cell, size)
-	}
-	setStartingPositions(board, game.playerCount, game.randomizeStartPos)
-	if game.bonusBiteCells {
-		setBiteFlagPositions(board)
-	}
-	if game.bonusRerollCells > 0 {
-		setRerollFlagPositions(board, game.bonusRerollCells)
-	}
-
 	// reset the game
-	game.board = board
+	game.board = game.buildBoard()
 	game.lastBoardUpdate = nil
 	game.turn = 0
+	game.round = 0
 	if !game.isOver {
 		game.addDraw(2)
 	}
 	game.isOver = false
 	game.created = time.Now()
+	game.clearUndo()
 
 	game.resetNewCellsForBites()
 	game.resetBites()
 	game.resetRerolls()
 	game.updateScores()
+	game.resetPieceQueue()
+	game.resetHeldPieces()
 	game.setNextPiece()
+	if game.simultaneous {
+		game.startRound()
+	}
+	game.resetTimers()
+	game.addBoardEvent(gameEventResetGame)
 }
 
 // getTurnInfo returns 2 values
-// first return value: true if it is the player's turn
+// first return value: true if it is the player's turn. With simultaneous turns, true if
+// the player still has to submit a move this round.
 // second return value: The ownership Cell associated with this turn
 func (game *Game) getTurnInfo(whoami Player) (bool, Cell) {
 	requestorTurn := -1
@@ -759,6 +1526,12 @@ func (game *Game) getTurnInfo(whoami Player) (bool, Cell) {
 			break
 		}
 	}
+	if game.simultaneous {
+		if game.canSubmit(requestorTurn) {
+			return true, Cell(requestorTurn + 1)
+		}
+		return false, 0
+	}
 	if requestorTurn == game.turn {
 		return true, Cell(requestorTurn + 1)
 	}
@@ -832,8 +1605,10 @@ func (game *Game) isBiteAdjacentToPlayer(biteOwner Cell, index int, mask PieceMa
 	return false
 }
 
-// addPieceToBoard adds piece to the board at index. Owner can be 0 to make cells unowned
-// Capturable flags such as CellFlagBonusBite and CellFlagBonusReroll are processed.
+// addPieceToBoard adds piece to the board at index. Owner can be 0 to make cells unowned,
+// except for shielded cells, which are left alone.
+// Capturable flags such as CellFlagBonusBite and CellFlagBonusReroll are processed and
+// awarded to owner.
 // Updates: game.board, game.bites, and game.rerolls
 func (game *Game) addPieceToBoard(owner Cell, index int, mask PieceMask) {
 	iRow, iCol := game.board.getIndex2D(index)
@@ -841,16 +1616,19 @@ func (game *Game) addPieceToBoard(owner Cell, index int, mask PieceMask) {
 		for pCol := 0; pCol < pieceMaskMaxLength; pCol++ {
 			if mask.has(pRow, pCol) {
 				cell := game.board[iRow+pRow][iCol+pCol]
+				if owner == 0 && isShielded(cell) {
+					continue
+				}
 				cell = (cell & CellMaskFlags) | owner
 
 				if owner != 0 {
 					if cell&CellFlagBonusBite != 0 {
 						cell &= ^CellFlagBonusBite
-						game.bites[game.turn] += gbBonusBiteAward
+						game.bites[owner-1] += game.bonusBiteAward
 					}
 					if cell&CellFlagBonusReroll != 0 {
 						cell &= ^CellFlagBonusReroll
-						game.rerolls[game.turn] += gbBonusRerollAward
+						game.rerolls[owner-1] += game.bonusRerollAward
 					}
 				}
 
@@ -873,7 +1651,8 @@ func (game *Game) isPieceOnFreeSpace(index int, mask PieceMask) bool {
 	for pRow := 0; pRow < pieceMaskMaxLength; pRow++ {
 		for pCol := 0; pCol < pieceMaskMaxLength; pCol++ {
 			if mask.has(pRow, pCol) {
-				if (game.board[iRow+pRow][iCol+pCol] & CellMaskPlayer) != 0 {
+				cell := game.board[iRow+pRow][iCol+pCol]
+				if cell&CellMaskPlayer != 0 || cell&CellFlagWall != 0 {
 					return false
 				}
 			}
@@ -882,14 +1661,16 @@ func (game *Game) isPieceOnFreeSpace(index int, mask PieceMask) bool {
 	return true
 }
 
-// true if any cells of mask are owned by another player
+// true if any cells of mask are owned by another player. Shielded cells don't count,
+// since they can't be bitten.
 func (game *Game) isPieceOnOpponentsSpace(player Cell, index int, mask PieceMask) bool {
 	iRow, iCol := game.board.getIndex2D(index)
 	for pRow := 0; pRow < pieceMaskMaxLength; pRow++ {
 		for pCol := 0; pCol < pieceMaskMaxLength; pCol++ {
 			if mask.has(pRow, pCol) {
-				cellOwner := game.board[iRow+pRow][iCol+pCol] & CellMaskPlayer
-				if cellOwner != 0 && cellOwner != player {
+				cell := game.board[iRow+pRow][iCol+pCol]
+				cellOwner := cell & CellMaskPlayer
+				if cellOwner != 0 && cellOwner != player && !isShielded(cell) {
 					return true
 				}
 			}
@@ -898,6 +1679,82 @@ func (game *Game) isPieceOnOpponentsSpace(player Cell, index int, mask PieceMask
 	return false
 }
 
+// Move is a placement of a piece or bite mask at a 1D board index
+type Move struct {
+	Index int       `json:"index"`
+	Mask  PieceMask `json:"mask"`
+}
+
+// isLegalPlacement returns true if owner can place mask at index
+func (game *Game) isLegalPlacement(owner Cell, index int, mask PieceMask) bool {
+	return index >= 0 && index < game.rowCount*game.colCount &&
+		game.isPieceInBounds(index, mask) &&
+		game.isPieceOnFreeSpace(index, mask) &&
+		game.isPieceAdjacentToPlayer(owner, index, mask)
+}
+
+// isLegalBite returns true if owner can place bite at index, ignoring its cost
+func (game *Game) isLegalBite(owner Cell, index int, bite PieceMask) bool {
+	return index >= 0 && index < game.rowCount*game.colCount &&
+		game.isPieceInBounds(index, bite) &&
+		game.isPieceOnOpponentsSpace(owner, index, bite) &&
+		game.isBiteAdjacentToPlayer(owner, index, bite)
+}
+
+// legalPlacements returns every legal placement of piece for playerIndex.
+// Rotations that produce the same mask are only listed once.
+func (game *Game) legalPlacements(playerIndex int, piece Piece) []Move {
+	var moves []Move
+	owner := Cell(playerIndex + 1)
+	for i, mask := range piece.Masks {
+		if mask == 0 || slices.Contains(piece.Masks[:i], mask) {
+			continue
+		}
+		for index := 0; index < game.rowCount*game.colCount; index++ {
+			if game.isLegalPlacement(owner, index, mask) {
+				moves = append(moves, Move{index, mask})
+			}
+		}
+	}
+	return moves
+}
+
+// legalBites returns every legal bite that playerIndex can afford
+func (game *Game) legalBites(playerIndex int) []Move {
+	var moves []Move
+	owner := Cell(playerIndex + 1)
+	for _, bite := range game.biteShapes {
+		if game.bites[playerIndex] < bite.Cost {
+			continue
+		}
+		for index := 0; index < game.rowCount*game.colCount; index++ {
+			if game.isLegalBite(owner, index, bite.Mask) {
+				moves = append(moves, Move{index, bite.Mask})
+			}
+		}
+	}
+	return moves
+}
+
+// legalMoves returns the legal placements of the piece playerIndex has to place and the
+// legal bites for playerIndex
+func (game *Game) legalMoves(playerIndex int) (placements []Move, bites []Move) {
+	for _, p := range game.offerOf(playerIndex) {
+		placements = append(placements, game.legalPlacements(playerIndex, p)...)
+	}
+	return placements, game.legalBites(playerIndex)
+}
+
+// hasLegalMove returns true if playerIndex can place one of their offered pieces or a bite
+func (game *Game) hasLegalMove(playerIndex int) bool {
+	for _, p := range game.offerOf(playerIndex) {
+		if game.hasLegalPlacement(playerIndex, p) {
+			return true
+		}
+	}
+	return game.hasLegalBite(playerIndex)
+}
+
 // Direction represents a direction on the game board.
 // Positive row and col values mean to scan down and to the right, respectively.
 type Direction struct {
@@ -921,6 +1778,10 @@ var (
 )
 
 // scanForCapture looks for possible captures starting at 1D index index.
+// With teams, a teammate's cells close a capture line and are never captured.
+// Lines outside of the capture length limits are not captured. Without captureHomeCells,
+// an opponent's home cell is never captured and breaks the line, unless captureThroughHomeCells
+// is set, in which case the rest of the line is captured.
 func (game *Game) scanForCapture(player Cell, index int, direction Direction) (bool, []int) {
 	var capture []int
 	var found bool
@@ -936,20 +1797,32 @@ func (game *Game) scanForCapture(player Cell, index int, direction Direction) (b
 		return found, capture
 	}
 
+	length := 0
 	r, c := rowStart+direction.row, colStart+direction.col
 	for r >= 0 && c >= 0 && r < game.rowCount && c < game.colCount {
 		owner := game.board[r][c] & CellMaskPlayer
-		if owner == 0 {
+		if owner == 0 || game.board[r][c]&CellFlagWall != 0 {
 			break
-		} else if owner == player {
+		} else if game.isFriendly(player, owner) {
+			// a teammate's cell closes the line too
 			found = true
 			break
+		} else if isShielded(game.board[r][c]) {
+			break
 		}
 
-		capture = append(capture, r*game.colCount+c)
+		length++
+		if game.board[r][c]&CellFlagHome == 0 || game.captureHomeCells {
+			capture = append(capture, r*game.colCount+c)
+		} else if !game.captureThroughHomeCells {
+			break
+		}
 		r, c = r+direction.row, c+direction.col
 	}
 
+	if found && !game.captureLengthAllowed(length) {
+		found = false
+	}
 	if !found {
 		capture = capture[:0]
 	}
@@ -958,7 +1831,8 @@ func (game *Game) scanForCapture(player Cell, index int, direction Direction) (b
 
 // captureCells scans the game board for any pieces that can be captured by player
 // pieces are captured if they is an continuous line of an opponent's pieces between
-// the player's pieces.
+// the player's pieces. Without captureChains, only the lines on the board before any
+// cells were captured are captured.
 // Updates: game.board
 // Returns: list of cells that were updated (1D indexes)
 func (game *Game) captureCells(player Cell) []int {
@@ -966,6 +1840,31 @@ func (game *Game) captureCells(player Cell) []int {
 	var tmp []int
 	var hadCapture bool
 
+	if !game.captureChains {
+		var lines [][]int
+		for r := 0; r < game.rowCount; r++ {
+			for c := 0; c < game.colCount; c++ {
+				for _, d := range game.captureDirections(false) {
+					hadCapture, tmp = game.scanForCapture(player, game.board.getIndex1D(r, c), d)
+					if hadCapture && len(tmp) > 0 {
+						lines = append(lines, tmp)
+					}
+				}
+			}
+		}
+		for _, line := range lines {
+			for _, c := range line {
+				tmpR, tmpC := game.board.getIndex2D(c)
+				if game.board[tmpR][tmpC]&CellMaskPlayer != player {
+					game.board[tmpR][tmpC] &= CellMaskFlags
+					game.board[tmpR][tmpC] |= player
+					updates = append(updates, c)
+				}
+			}
+		}
+		return updates
+	}
+
 	// keep finding the longest capture until there are no captures left
 	longest := make([]int, 8)
 	for len(longest) > 0 {
@@ -973,12 +1872,7 @@ func (game *Game) captureCells(player Cell) []int {
 
 		for r := 0; r < game.rowCount; r++ {
 			for c := 0; c < game.colCount; c++ {
-				for _, d := range []Direction{
-					directionRight,
-					directionDown,
-					directionDownRight,
-					directionDownLeft,
-				} {
+				for _, d := range game.captureDirections(false) {
 					hadCapture, tmp = game.scanForCapture(player, game.board.getIndex1D(r, c), d)
 					if hadCapture && len(tmp) > len(longest) {
 						if len(tmp) > cap(longest) {
@@ -994,7 +1888,7 @@ func (game *Game) captureCells(player Cell) []int {
 
 		if len(longest) > 0 {
 			for _, c := range longest {
-				tmpR, tmpC := c/game.rowCount, c%game.rowCount
+				tmpR, tmpC := game.board.getIndex2D(c)
 				game.board[tmpR][tmpC] &= CellMaskFlags
 				game.board[tmpR][tmpC] |= player
 			}
@@ -1006,7 +1900,7 @@ func (game *Game) captureCells(player Cell) []int {
 }
 
 // captureCellsFromPiece looks for captures starting at piece mask at 1D index.
-// Cells that are captured may in turn capture additional cells.
+// With captureChains, cells that are captured may in turn capture additional cells.
 func (game *Game) captureCellsFromPiece(owner Cell, index int, mask PieceMask) []int {
 	var cellsToCheck []int = game.board.getPieceIndices(index, mask)
 	var updates []int
@@ -1017,24 +1911,17 @@ func (game *Game) captureCellsFromPiece(owner Cell, index int, mask PieceMask) [
 		cell := cellsToCheck[0]
 		cellsToCheck = cellsToCheck[1:]
 
-		for _, d := range []Direction{
-			directionDownLeft,
-			directionLeft,
-			directionUpLeft,
-			directionUp,
-			directionUpRight,
-			directionRight,
-			directionDownRight,
-			directionDown,
-		} {
+		for _, d := range game.captureDirections(true) {
 			hadCapture, tmp = game.scanForCapture(owner, cell, d)
 			if hadCapture {
 				for _, c := range tmp {
-					tmpR, tmpC := c/game.rowCount, c%game.rowCount
+					tmpR, tmpC := game.board.getIndex2D(c)
 					game.board[tmpR][tmpC] &= CellMaskFlags
 					game.board[tmpR][tmpC] |= owner
 				}
-				cellsToCheck = append(cellsToCheck, tmp...)
+				if game.captureChains {
+					cellsToCheck = append(cellsToCheck, tmp...)
+				}
 				updates = append(updates, tmp...)
 			}
 		}
@@ -1043,7 +1930,8 @@ func (game *Game) captureCellsFromPiece(owner Cell, index int, mask PieceMask) [
 	return updates
 }
 
-// handleOrphanedCells removes any player cells with no path back to the home cell
+// handleOrphanedCells removes any player cells with no path back to a home cell or a spore cell.
+// With teamSharedPaths, the path may also go through a teammate's cells.
 // Updates: game.board
 // Returns: list of cells that were updated (1D indexes)
 func (game *Game) handleOrphanedCells() []int {
@@ -1055,31 +1943,37 @@ func (game *Game) handleOrphanedCells() []int {
 		flaggedCells[i] = make([]Cell, game.colCount)
 	}
 
+	// isConnected returns true if cells owned by whoami connect through the cell at r, c
+	isConnected := func(whoami Cell, r, c int) bool {
+		owner := game.board[r][c] & CellMaskPlayer
+		return owner == whoami || (game.teamSharedPaths && game.isFriendly(whoami, owner))
+	}
+
 	var flagConnectedNeighbors func(Cell, int, int)
 	flagConnectedNeighbors = func(whoami Cell, r, c int) {
 		// above
-		if c > 0 && game.board[r][c-1]&CellMaskPlayer == whoami {
+		if c > 0 && isConnected(whoami, r, c-1) {
 			if flaggedCells[r][c-1] == 0 {
 				flaggedCells[r][c-1] = whoami
 				flagConnectedNeighbors(whoami, r, c-1)
 			}
 		}
 		// below
-		if c < game.colCount-1 && game.board[r][c+1]&CellMaskPlayer == whoami {
+		if c < game.colCount-1 && isConnected(whoami, r, c+1) {
 			if flaggedCells[r][c+1] == 0 {
 				flaggedCells[r][c+1] = whoami
 				flagConnectedNeighbors(whoami, r, c+1)
 			}
 		}
 		// right
-		if r < game.rowCount-1 && game.board[r+1][c]&CellMaskPlayer == whoami {
+		if r < game.rowCount-1 && isConnected(whoami, r+1, c) {
 			if flaggedCells[r+1][c] == 0 {
 				flaggedCells[r+1][c] = whoami
 				flagConnectedNeighbors(whoami, r+1, c)
 			}
 		}
 		// left
-		if r > 0 && game.board[r-1][c]&CellMaskPlayer == whoami {
+		if r > 0 && isConnected(whoami, r-1, c) {
 			if flaggedCells[r-1][c] == 0 {
 				flaggedCells[r-1][c] = whoami
 				flagConnectedNeighbors(whoami, r-1, c)
@@ -1096,8 +1990,8 @@ func (game *Game) handleOrphanedCells() []int {
 				continue
 			}
 
-			// check for home cell
-			if game.board[r][c]&CellFlagHome != 0 {
+			// check for home cell, including spore cells
+			if isHomeCell(game.board[r][c]) {
 				flaggedCells[r][c] = whoami
 				flagConnectedNeighbors(whoami, r, c)
 			}
@@ -1118,45 +2012,57 @@ func (game *Game) handleOrphanedCells() []int {
 	return updates
 }
 
-// updateScores() sets game.scores and game.isOver
+// updateScores() sets game.scores, and ends the game once a single player (or team) is
+// left. Multiplier cells count more, see cellValue.
 func (game *Game) updateScores() {
 	var scores [maxPlayers]int
 	for r := 0; r < len(game.board); r++ {
 		for c := 0; c < len(game.board[0]); c++ {
 			player := int(game.board[r][c] & CellMaskPlayer)
 			if player > 0 && player <= maxPlayers {
-				scores[player-1]++
+				scores[player-1] += cellValue(game.board[r][c])
 			}
 		}
 	}
 	game.scores = scores
 
-	var activePlayerCount int
+	// with teams, the game goes on while more than one team has cells
+	activeTeams := map[int]bool{}
 	var winnerIndex int
-	for i := 0; i < maxPlayers; i++ {
+	for i := 0; i < game.playerCount; i++ {
 		if scores[i] != 0 {
-			activePlayerCount++
+			activeTeams[game.teamOf(i)] = true
 			winnerIndex = i
 		}
 	}
 
-	game.isOver = activePlayerCount <= 1
-	if activePlayerCount == 1 {
+	// a finished game keeps its result
+	if game.isOver || len(activeTeams) > 1 {
+		return
+	}
+	game.isOver = true
+	game.winners = nil
+	if len(activeTeams) == 1 {
+		for i := 0; i < game.playerCount; i++ {
+			if game.teamOf(i) == game.teamOf(winnerIndex) {
+				game.winners = append(game.winners, i)
+			}
+		}
 		game.addWin(winnerIndex)
 	}
 }
 
-// updateNewCellsForBites() updates the current player's resetNewCellsForBites progress
-func (game *Game) updateNewCellsForBites(addedCells int) {
+// updateNewCellsForBites() updates playerIndex's resetNewCellsForBites progress
+func (game *Game) updateNewCellsForBites(playerIndex, addedCells int) {
 	if addedCells <= 0 || game.newCellsForBitesThreshold <= 0 {
 		return
 	}
-	game.newCellsForBites[game.turn] += addedCells
-	newBites := game.newCellsForBites[game.turn] / game.newCellsForBitesThreshold
-	remainder := game.newCellsForBites[game.turn] % game.newCellsForBitesThreshold
+	game.newCellsForBites[playerIndex] += addedCells
+	newBites := game.newCellsForBites[playerIndex] / game.newCellsForBitesThreshold
+	remainder := game.newCellsForBites[playerIndex] % game.newCellsForBitesThreshold
 	if newBites > 0 {
-		game.bites[game.turn] += newBites
-		game.newCellsForBites[game.turn] = remainder
+		game.bites[playerIndex] += newBites
+		game.newCellsForBites[playerIndex] = remainder
 	}
 }
 
@@ -1182,27 +2088,147 @@ func (game *Game) resetRerolls() {
 }
 
 // advanceTurn updates game.turn to the next player, skipping over players that have already lost.
-// Sets turn to -1 if the game is over.
+// Sets turn to -1 if the game is over. The time taken is charged to the player's clock.
+// Ends the game once a win condition is met, or after the last round with game.maxRounds.
 func (game *Game) advanceTurn() {
+	now := time.Now()
+	game.chargeTurnTime(now)
+	game.turnStarted = now
 	if game.isOver {
 		game.turn = -1
 		return
 	}
+	if game.winConditionMet() {
+		game.endWithLeaders(gameEventWinCondition)
+		return
+	}
+	previousTurn := game.turn
 	for i := 0; i < game.playerCount; i++ {
 		game.turn = (game.turn + 1) % game.playerCount
 		if game.scores[game.turn] != 0 {
 			break
 		}
 	}
+	if game.simultaneous || game.turn <= previousTurn {
+		game.round++
+		if game.maxRounds > 0 && game.round >= game.maxRounds {
+			game.endWithLeaders(gameEventRoundLimit)
+		}
+	}
+}
+
+// hasLegalPlacement returns true if playerIndex can place piece anywhere
+func (game *Game) hasLegalPlacement(playerIndex int, piece Piece) bool {
+	owner := Cell(playerIndex + 1)
+	for _, mask := range piece.Masks {
+		for index := 0; mask != 0 && index < game.rowCount*game.colCount; index++ {
+			if game.isLegalPlacement(owner, index, mask) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasLegalBite returns true if playerIndex can afford and place a bite anywhere
+func (game *Game) hasLegalBite(playerIndex int) bool {
+	owner := Cell(playerIndex + 1)
+	for _, bite := range game.biteShapes {
+		for index := 0; game.bites[playerIndex] >= bite.Cost && index < game.rowCount*game.colCount; index++ {
+			if game.isLegalBite(owner, index, bite.Mask) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func getWeightedRandomPiece(pieces []Piece) Piece {
+// canMove returns true if playerIndex can place their piece or a bite, can swap in
+// a held piece that fits, or can reroll into a piece that fits
+func (game *Game) canMove(playerIndex int) bool {
+	if game.hasLegalMove(playerIndex) {
+		return true
+	}
+	if held := game.heldPieces[playerIndex]; game.canHold(playerIndex) && !held.isEmpty() && game.hasLegalPlacement(playerIndex, held) {
+		return true
+	}
+	if game.rerolls[playerIndex] <= 0 {
+		return false
+	}
+	offer := game.offerOf(playerIndex)
+	for _, p := range game.pieces {
+		if !slices.Contains(offer, p) && p.Weight > 0 && game.hasLegalPlacement(playerIndex, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStalemate returns true if no player left in the game can place any piece or bite
+func (game *Game) isStalemate() bool {
+	for i := 0; i < game.playerCount; i++ {
+		if game.scores[i] == 0 {
+			continue
+		}
+		if game.hasLegalBite(i) {
+			return false
+		}
+		for _, p := range game.pieces {
+			if p.Weight > 0 && game.hasLegalPlacement(i, p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// endStalemate ends a game that no one can move in. The players (or team) with the
+// highest score win, or draw if the tie can't be broken.
+func (game *Game) endStalemate() {
+	game.lastBoardUpdate = nil
+	game.endWithLeaders(gameEventStalemate)
+	game.setNextPiece()
+}
+
+// skipStuckPlayers skips the turn of every player who can't move until it is the turn of
+// a player who can. Ends the game if no one can move.
+func (game *Game) skipStuckPlayers() {
+	for i := 0; i < gbMaxForcedSkips && !game.isOver && game.turn >= 0; i++ {
+		if game.canMove(game.turn) {
+			return
+		}
+		if game.isStalemate() {
+			game.endStalemate()
+			return
+		}
+		game.addEvent(GameEvent{
+			Type:   gameEventSkipTurn,
+			Player: game.turn,
+			Forced: true,
+			Index:  -1,
+			Before: game.playerResources(game.turn),
+			After:  game.playerResources(game.turn),
+		})
+		game.advanceTurn()
+		game.setNextPiece()
+	}
+}
+
+// endTurn passes the turn to the next player, deals their piece and skips them
+// if they can't move
+func (game *Game) endTurn() {
+	game.advanceTurn()
+	game.setNextPiece()
+	game.skipStuckPlayers()
+}
+
+func getWeightedRandomPiece(pieces []Piece, rng *rand.Rand) Piece {
 	var totalWeight float64
 	for _, p := range pieces {
 		totalWeight += p.Weight
 	}
 
-	r := rand.Float64() * totalWeight
+	r := rng.Float64() * totalWeight
 	for _, p := range pieces {
 		r -= p.Weight
 		if r <= 0 {
@@ -1215,36 +2241,99 @@ func getWeightedRandomPiece(pieces []Piece) Piece {
 }
 
 func (game *Game) setNextPiece() {
+	game.holdUsed = [maxPlayers]bool{}
 	if game.isOver {
 		game.nextPiece = Piece{PieceMask(0).generateRotations(), 0}
 	} else {
-		game.nextPiece = getWeightedRandomPiece(game.pieces)
+		game.nextPiece = game.dealPiece()
 	}
+	game.dealDraft()
 }
 
 func (game *Game) reroll(whoami Player) error {
-	isPlayersTurn, _ := game.getTurnInfo(whoami)
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	isPlayersTurn, owner := game.getTurnInfo(whoami)
 	if !isPlayersTurn {
 		return errors.New("Invalid update: not player's turn")
 	}
-	if game.rerolls[game.turn] <= 0 {
+	playerIndex := int(owner) - 1
+	if game.rerolls[playerIndex] <= 0 {
 		return errors.New("Invalid update: no rerolls remaining")
 	}
+	before := game.playerResources(playerIndex)
+
+	game.redrawOffer(playerIndex)
+
+	game.rerolls[playerIndex]--
+	// the last move can't be undone once the next player acts
+	game.clearUndo()
+	game.addEvent(GameEvent{
+		Type:   gameEventReroll,
+		Player: playerIndex,
+		Mask:   game.pieceOf(playerIndex).Masks[0],
+		Index:  -1,
+		Before: before,
+		After:  game.playerResources(playerIndex),
+	})
+	if game.simultaneous {
+		game.submitForcedSkips()
+		game.resolveRoundIfReady()
+	} else {
+		game.skipStuckPlayers()
+	}
+	return nil
+}
 
-	// get the list of pieces, excluding the current piece
-	rerollPieces := make([]Piece, 0, len(game.pieces)-1)
-	for _, p := range game.pieces {
-		if p != game.nextPiece {
-			rerollPieces = append(rerollPieces, p)
+// applyPiece adds a piece for owner to the board and resolves captures and orphaned cells.
+// Bonus cells, scores and new bite progress are awarded to game.turn. Moves are not validated.
+// Updates game.lastBoardUpdate and returns the captured and orphaned cells.
+func (game *Game) applyPiece(owner Cell, index int, mask PieceMask) (captured, orphaned []int) {
+	scoreBefore := game.scores[game.turn]
+	game.addPieceToBoard(owner, index, mask)
+
+	// necessary for game pieces with gaps
+	orphaned = append(orphaned, game.handleOrphanedCells()...)
+
+	if game.captureMode == gameModeCaptureFromPiece {
+		// Handle captures caused by newly placed piece.
+		captured = append(captured, game.captureCellsFromPiece(owner, index, mask)...)
+	} else if game.captureMode == gameModeCaptureAnywhereCurrentPlayer {
+		// Handle captures for the current player only.
+		captured = append(captured, game.captureCells(owner)...)
+	} else if game.captureMode == gameModeCaptureAnywhereAllPlayers {
+		// Handle captures for all players, anywhere on the board.
+		// Current player goes last so that established pieces win.
+		for i := 1; i <= game.playerCount; i++ {
+			capturer := Cell(((game.turn + i) % game.playerCount) + 1)
+			captured = append(captured, game.captureCells(capturer)...)
 		}
+	} else {
+		panic(fmt.Sprintf("game.captureMode unimplemented. Got %d", game.captureMode))
 	}
 
-	if len(rerollPieces) > 0 {
-		game.nextPiece = getWeightedRandomPiece(rerollPieces)
-	}
+	orphanedAfterCapture := game.handleOrphanedCells()
+	game.lastBoardUpdate = game.lastBoardUpdate[:0]
+	game.lastBoardUpdate = append(game.lastBoardUpdate, orphaned...)
+	game.lastBoardUpdate = append(game.lastBoardUpdate, captured...)
+	game.lastBoardUpdate = append(game.lastBoardUpdate, orphanedAfterCapture...)
+	orphaned = append(orphaned, orphanedAfterCapture...)
 
-	game.rerolls[game.turn]--
-	return nil
+	game.updateScores()
+	game.updateNewCellsForBites(game.turn, game.scores[game.turn]-scoreBefore)
+	return captured, orphaned
+}
+
+// applyBite clears the cells of bite at index and removes orphaned cells.
+// The bite is paid for by game.turn. Moves are not validated.
+// Updates game.lastBoardUpdate to the orphaned cells.
+func (game *Game) applyBite(index int, bite PieceMask) {
+	game.addPieceToBoard(0, index, bite)
+	cost, _ := game.biteCost(bite)
+	game.bites[game.turn] -= cost
+	game.lastBoardUpdate = game.handleOrphanedCells()
+	game.updateScores()
 }
 
 // placePiece adds the proposed update to the board, if allowed
@@ -1274,47 +2363,37 @@ func (game *Game) placePiece(whoami Player, index int, mask PieceMask) error {
 	if !game.isPieceAdjacentToPlayer(pieceOwner, index, mask) {
 		return errors.New("Invalid update: piece not adjactent")
 	}
-	if !game.nextPiece.has(mask) {
-		return errors.New("Invalid update: unexpected game piece")
-	}
-
-	scoreBefore := game.scores[game.turn]
-
-	game.addPieceToBoard(pieceOwner, index, mask)
-	game.lastBoardUpdate = game.lastBoardUpdate[:0]
-
-	// necessary for game pieces with gaps
-	game.lastBoardUpdate = append(game.lastBoardUpdate, game.handleOrphanedCells()...)
-
-	if game.captureMode == gameModeCaptureFromPiece {
-		// Handle captures caused by newly placed piece.
-		game.lastBoardUpdate = append(
-			game.lastBoardUpdate,
-			game.captureCellsFromPiece(pieceOwner, index, mask)...,
-		)
-	} else if game.captureMode == gameModeCaptureAnywhereCurrentPlayer {
-		// Handle captures for the current player only.
-		game.lastBoardUpdate = append(
-			game.lastBoardUpdate,
-			game.captureCells(pieceOwner)...,
-		)
-	} else if game.captureMode == gameModeCaptureAnywhereAllPlayers {
-		// Handle captures for all players, anywhere on the board.
-		// Current player goes last so that established pieces win.
-		for i := 1; i <= game.playerCount; i++ {
-			capturer := Cell(((game.turn + i) % game.playerCount) + 1)
-			game.lastBoardUpdate = append(game.lastBoardUpdate,
-				game.captureCells(capturer)...)
+	playerIndex := int(pieceOwner) - 1
+	fromHold := false
+	if !game.offerHas(playerIndex, mask) {
+		if !game.heldPieceHas(playerIndex, mask) {
+			return errors.New("Invalid update: unexpected game piece")
 		}
-	} else {
-		panic(fmt.Sprintf("game.captureMode unimplemented. Got %d", game.captureMode))
-	}
-
-	game.lastBoardUpdate = append(game.lastBoardUpdate, game.handleOrphanedCells()...)
-	game.updateScores()
-	game.updateNewCellsForBites(game.scores[game.turn] - scoreBefore)
-	game.advanceTurn()
-	game.setNextPiece()
+		fromHold = true
+	}
+	if game.simultaneous {
+		game.submitMove(playerIndex, RoundMove{Action: gameEventPlacePiece, Index: index, Mask: mask, Held: fromHold})
+		return nil
+	}
+
+	game.saveUndoSnapshot()
+	if fromHold {
+		game.swapHeldPiece(playerIndex)
+	}
+	before := game.playerResources(playerIndex)
+	captured, orphaned := game.applyPiece(pieceOwner, index, mask)
+
+	game.addEvent(GameEvent{
+		Type:     gameEventPlacePiece,
+		Player:   playerIndex,
+		Mask:     mask,
+		Index:    index,
+		Captured: captured,
+		Orphaned: orphaned,
+		Before:   before,
+		After:    game.playerResources(playerIndex),
+	})
+	game.endTurn()
 	return nil
 }
 
@@ -1345,34 +2424,68 @@ func (game *Game) placeBite(whoami Player, index int, bite PieceMask) error {
 	if !game.isBiteAdjacentToPlayer(pieceOwner, index, bite) {
 		return errors.New("Invalid update: bite not adjacent")
 	}
-	cost, ok := biteCosts[bite]
+	cost, ok := game.biteCost(bite)
 	if !ok {
 		return errors.New("Invalid update: invalid bite mask")
 	}
-	if game.bites[game.turn] < cost {
+	playerIndex := int(pieceOwner) - 1
+	if game.bites[playerIndex] < cost {
 		return errors.New("Invalid update: not enough bites remaining")
 	}
-
-	game.addPieceToBoard(0, index, bite)
-	game.bites[game.turn] -= cost
-	game.lastBoardUpdate = game.handleOrphanedCells()
-	game.updateScores()
-	game.advanceTurn()
-	game.setNextPiece()
+	if game.simultaneous {
+		game.submitMove(playerIndex, RoundMove{Action: gameEventPlaceBite, Index: index, Mask: bite})
+		return nil
+	}
+
+	game.saveUndoSnapshot()
+	before := game.playerResources(playerIndex)
+
+	game.applyBite(index, bite)
+	game.addEvent(GameEvent{
+		Type:     gameEventPlaceBite,
+		Player:   playerIndex,
+		Mask:     bite,
+		Index:    index,
+		Orphaned: append([]int(nil), game.lastBoardUpdate...),
+		Before:   before,
+		After:    game.playerResources(playerIndex),
+	})
+	game.endTurn()
 	return nil
 }
 
 func (game *Game) skipTurn(whoami Player) error {
-	isPlayersTurn, _ := game.getTurnInfo(whoami)
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	isPlayersTurn, owner := game.getTurnInfo(whoami)
 	if !isPlayersTurn {
 		return errors.New("Invalid update: not player's turn")
 	}
-	game.lastBoardUpdate = nil
-	game.advanceTurn()
-	game.setNextPiece()
+	if game.simultaneous {
+		game.submitMove(int(owner)-1, RoundMove{Action: gameEventSkipTurn, Index: -1})
+		return nil
+	}
+	game.skipCurrentTurn(false)
 	return nil
 }
 
+// skipCurrentTurn passes the turn of the current player without a move. timeout is
+// set if the player ran out of time. The caller must hold game.mu.
+func (game *Game) skipCurrentTurn(timeout bool) {
+	game.saveUndoSnapshot()
+	game.lastBoardUpdate = nil
+	game.addEvent(GameEvent{
+		Type:    gameEventSkipTurn,
+		Player:  game.turn,
+		Timeout: timeout,
+		Index:   -1,
+		Before:  game.playerResources(game.turn),
+		After:   game.playerResources(game.turn),
+	})
+	game.endTurn()
+}
+
 func (game *Game) clearLastBoardUpdate() {
 	game.lastBoardUpdate = nil
 }
@@ -0,0 +1,25 @@
+package main
+
+// Bite is a shape a player can clear from the board and the number of bites it costs
+type Bite struct {
+	Mask PieceMask `json:"mask"`
+	Cost int       `json:"cost"`
+}
+
+const gbMaxBiteShapes = 8 // bites a game may offer, each gets its own button
+
+// bites offered by default, see CalcBiteCost
+var gbDefaultBites = []Bite{
+	{biteSmall, biteSmall.CalcBiteCost()},
+	{biteLarge, biteLarge.CalcBiteCost()},
+}
+
+// biteCost returns the cost of bite and true if bite is one of the game's bites
+func (game *Game) biteCost(bite PieceMask) (int, bool) {
+	for _, b := range game.biteShapes {
+		if b.Mask == bite {
+			return b.Cost, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCustomBites(t *testing.T) {
+	var err error
+
+	line := Bite{0b1110000_0000000_0000000_0000000_0000000_0000000_0000000, 2}
+
+	lobbyName := "TestCustomBites"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":  6,
+		"bites": []Bite{line},
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	home1 := 1 | CellFlagHome
+	home2 := 2 | CellFlagHome
+	game.board = GameBoard{
+		{home1, 0, 0, 0, 0, 0},
+		{1, 2, 2, 2, 2, home2},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+	}
+	game.updateScores()
+	game.turn = 0
+	game.bites[0] = 2
+
+	// only the game's bites can be placed
+	if err = game.placeBite(p1, game.board.getIndex1D(1, 1), biteSmall); err == nil {
+		t.Error("Expected a bite that isn't part of the game to be rejected")
+	}
+	for _, move := range game.legalBites(0) {
+		if move.Mask != line.Mask {
+			t.Errorf("Expected only the line bite to be legal. Got %v", move)
+		}
+	}
+
+	if err = game.placeBite(p1, game.board.getIndex1D(1, 1), line.Mask); err != nil {
+		t.Fatalf("placeBite failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.bites[0] != 0 || game.scores[1] != 2 {
+		t.Errorf("Expected the line bite to cost 2 and clear 3 cells. bites=%v scores=%v\n%s",
+			game.bites, game.scores, game.board.String2D())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+)
+
+const gbDefaultBonusBiteCells = 4
+const gbMaxBonusCells = 100 // bonus bite or reroll cells per board
+const gbDefaultBonusBiteAward = 3
+const gbDefaultBonusRerollAward = 1
+const gbMaxBonusAward = 10
+const gbDefaultBonusHomeClearance = 0 // bonus cells are kept at least this many cells away from home cells
+const gbMaxBonusHomeClearance = 10
+
+// How bonus cells are placed on the board
+const (
+	gameBonusCorners      = iota // bonus bite cells start in the corners and work inward, other cells are random
+	gameBonusRandom              // every bonus cell is random
+	gameBonusSymmetric           // random cells mirrored between the seats, see boardSymmetries
+	gameBonusPlacementMax        // For input validation. Not a placement.
+)
+
+const gbDefaultBonusPlacement = gameBonusCorners
+
+// validateBonusOptions checks the bonus cell options passed to createGame
+func validateBonusOptions(biteCells, rerollCells, biteAward, rerollAward, placement, homeClearance int) error {
+	if biteCells < 0 || biteCells > gbMaxBonusCells {
+		return errors.New("Invalid bonus_bite_cells parameter")
+	}
+	if rerollCells < 0 || rerollCells > gbMaxBonusCells {
+		return errors.New("Invalid bonus_reroll_cells parameter")
+	}
+	if biteAward < 1 || biteAward > gbMaxBonusAward {
+		return errors.New("Invalid bonus_bite_award parameter")
+	}
+	if rerollAward < 1 || rerollAward > gbMaxBonusAward {
+		return errors.New("Invalid bonus_reroll_award parameter")
+	}
+	if placement < 0 || placement >= gameBonusPlacementMax {
+		return errors.New("Invalid bonus_placement parameter")
+	}
+	if homeClearance < 0 || homeClearance > gbMaxBonusHomeClearance {
+		return errors.New("Invalid bonus_home_clearance parameter")
+	}
+	return nil
+}
+
+// canPlaceBonus returns true if the cell at r, c is empty, unflagged and at least
+// homeClearance cells away from every home cell
+func canPlaceBonus(board GameBoard, r, c, homeClearance int) bool {
+	return board[r][c] == 0 && !isNearHome(board, r, c, homeClearance)
+}
+
+// setBiteFlagPositions places count bonus bite cells on the board
+func setBiteFlagPositions(board GameBoard, count, placement, homeClearance int, rng *rand.Rand) {
+	if placement == gameBonusCorners {
+		setCornerFlagPositions(board, CellFlagBonusBite, count, homeClearance)
+		return
+	}
+	setBonusFlagPositions(board, CellFlagBonusBite, count, placement == gameBonusSymmetric, homeClearance, rng)
+}
+
+// setRerollFlagPositions places count bonus reroll cells on the board
+func setRerollFlagPositions(board GameBoard, count, placement, homeClearance int, rng *rand.Rand) {
+	setBonusFlagPositions(board, CellFlagBonusReroll, count, placement == gameBonusSymmetric, homeClearance, rng)
+}
+
+// setCornerFlagPositions places count cells with flag in the corners of the board. After
+// the corners, cells are placed diagonally inward, one corner after the other. Cells that
+// are taken are skipped.
+func setCornerFlagPositions(board GameBoard, flag Cell, count, homeClearance int) {
+	maxR := len(board) - 1
+	maxC := len(board[0]) - 1
+
+	placed := 0
+	for depth := 0; placed < count && depth <= min(maxR, maxC)/2; depth++ {
+		for _, corner := range [][2]int{{depth, depth}, {depth, maxC - depth}, {maxR - depth, depth}, {maxR - depth, maxC - depth}} {
+			if placed < count && canPlaceBonus(board, corner[0], corner[1], homeClearance) {
+				board[corner[0]][corner[1]] |= flag
+				placed++
+			}
+		}
+	}
+}
+
+// boardSymmetries returns the mirrors and rotations of board that map every home cell onto
+// a home cell, starting with the identity. Quarter turns are only possible on square boards.
+// Seats that one of them swaps are alike. Some layouts have seats that none of them swap,
+// such as three players, or the corner and middle seats with five or more players.
+func boardSymmetries(board GameBoard) []func(r, c int) (int, int) {
+	maxR := len(board) - 1
+	maxC := len(board[0]) - 1
+
+	symmetries := []func(r, c int) (int, int){
+		func(r, c int) (int, int) { return r, c },
+		func(r, c int) (int, int) { return maxR - r, c },
+		func(r, c int) (int, int) { return r, maxC - c },
+		func(r, c int) (int, int) { return maxR - r, maxC - c },
+	}
+	if maxR == maxC {
+		symmetries = append(symmetries,
+			func(r, c int) (int, int) { return c, r },
+			func(r, c int) (int, int) { return maxC - c, maxR - r },
+			func(r, c int) (int, int) { return c, maxR - r },
+			func(r, c int) (int, int) { return maxC - c, r },
+		)
+	}
+
+	var homes [][2]int
+	for r := range board {
+		for c := range board[r] {
+			if board[r][c]&CellFlagHome != 0 {
+				homes = append(homes, [2]int{r, c})
+			}
+		}
+	}
+	return slices.DeleteFunc(symmetries, func(symmetry func(r, c int) (int, int)) bool {
+		return slices.ContainsFunc(homes, func(home [2]int) bool {
+			r, c := symmetry(home[0], home[1])
+			return board[r][c]&CellFlagHome == 0
+		})
+	})
+}
+
+// setBonusFlagPositions places count cells with flag at random free cells. With symmetric,
+// each cell is copied to its images under boardSymmetries, so that seats the board can swap
+// get the same cells. Groups of images that don't fit in what is left of count are skipped,
+// and any cells still missing after that are placed without their images.
+func setBonusFlagPositions(board GameBoard, flag Cell, count int, symmetric bool, homeClearance int, rng *rand.Rand) {
+	var symmetries []func(r, c int) (int, int)
+	if symmetric {
+		symmetries = boardSymmetries(board)
+	}
+
+	var candidates [][2]int
+	for r := range board {
+		for c := range board[r] {
+			if canPlaceBonus(board, r, c, homeClearance) {
+				candidates = append(candidates, [2]int{r, c})
+			}
+		}
+	}
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	placed := 0
+	place := func(mirror bool) {
+		for _, cell := range candidates {
+			if placed >= count {
+				return
+			}
+			cells := [][2]int{cell}
+			if mirror {
+				for _, symmetry := range symmetries {
+					r, c := symmetry(cell[0], cell[1])
+					if image := [2]int{r, c}; !slices.Contains(cells, image) {
+						cells = append(cells, image)
+					}
+				}
+			}
+			if len(cells) > count-placed || slices.ContainsFunc(cells, func(cell [2]int) bool {
+				return !canPlaceBonus(board, cell[0], cell[1], homeClearance)
+			}) {
+				continue
+			}
+			for _, cell := range cells {
+				board[cell[0]][cell[1]] |= flag
+				placed++
+			}
+		}
+	}
+	if symmetric {
+		place(true)
+	}
+	place(false)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+)
+
+// countBonusCells returns the number of cells with flag and the number of cells with more
+// than one bonus or wall flag
+func countBonusCells(board GameBoard, flag Cell) (count, overlaps int) {
+	for r := range board {
+		for c := range board[r] {
+			if board[r][c]&flag != 0 {
+				count++
+			}
+			flags := board[r][c] & (CellFlagBonusBite | CellFlagBonusReroll | CellFlagWall | CellFlagHome)
+			if flags&(flags-1) != 0 {
+				overlaps++
+			}
+		}
+	}
+	return count, overlaps
+}
+
+func TestBonusCells(t *testing.T) {
+	var err error
+	var count, overlaps int
+
+	lobbyName := "TestBonusCells"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, opts := range []map[string]any{
+		{"bonus_bite_cells": -1},
+		{"bonus_reroll_cells": gbMaxBonusCells + 1},
+		{"bonus_bite_award": 0},
+		{"bonus_reroll_award": gbMaxBonusAward + 1},
+		{"bonus_placement": gameBonusPlacementMax},
+		{"bonus_home_clearance": gbMaxBonusHomeClearance + 1},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	// the old option still turns off bonus bite cells
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"has_bonus_bite_cells": false})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if count, _ = countBonusCells(game.board, CellFlagBonusBite); count != 0 {
+		t.Errorf("Expected no bonus bite cells. Got %d\n%s", count, game.board.String2D())
+	}
+
+	// corners, then diagonally inward
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{"size": 10, "bonus_bite_cells": 8})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	for _, cell := range [][2]int{{0, 0}, {0, 9}, {9, 0}, {9, 9}, {1, 1}, {1, 8}, {8, 1}, {8, 8}} {
+		if game.board[cell[0]][cell[1]]&CellFlagBonusBite == 0 {
+			t.Errorf("Bonus bite cell not found at %v. Board is:\n%s", cell, game.board.String2D())
+		}
+	}
+
+	// random and symmetric cells never overlap other flags or come near a home cell
+	for _, placement := range []int{gameBonusRandom, gameBonusSymmetric} {
+		game, err = createGame(activeLobbies[lobbyName], map[string]any{
+			"size":                 16,
+			"bonus_bite_cells":     8,
+			"bonus_reroll_cells":   20,
+			"bonus_placement":      placement,
+			"bonus_home_clearance": 2,
+			"walls":                gameWallsRandom,
+		})
+		if err != nil {
+			t.Fatalf("createGame failed: %v", err)
+		}
+		if count, overlaps = countBonusCells(game.board, CellFlagBonusBite); count != 8 || overlaps != 0 {
+			t.Errorf("placement %d: Expected 8 bonus bite cells and no overlaps. Got %d and %d\n%s",
+				placement, count, overlaps, game.board.String2D())
+		}
+		if count, _ = countBonusCells(game.board, CellFlagBonusReroll); count != 20 {
+			t.Errorf("placement %d: Expected 20 bonus reroll cells. Got %d\n%s", placement, count, game.board.String2D())
+		}
+		for r := range game.board {
+			for c := range game.board[r] {
+				cell := game.board[r][c]
+				if cell&(CellFlagBonusBite|CellFlagBonusReroll) != 0 && isNearHome(game.board, r, c, 2) {
+					t.Errorf("placement %d: Bonus cell at %d,%d is too close to a home cell\n%s", placement, r, c, game.board.String2D())
+				}
+				mirrored := game.board[15-r][15-c]
+				if placement == gameBonusSymmetric && cell&(CellFlagBonusBite|CellFlagBonusReroll) != mirrored&(CellFlagBonusBite|CellFlagBonusReroll) {
+					t.Errorf("Bonus cell at %d,%d isn't mirrored\n%s", r, c, game.board.String2D())
+				}
+			}
+		}
+	}
+
+	// counts that aren't whole mirrored groups are still placed exactly
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"size":               10,
+		"bonus_bite_cells":   3,
+		"bonus_reroll_cells": 5,
+		"bonus_placement":    gameBonusSymmetric,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if count, _ = countBonusCells(game.board, CellFlagBonusBite); count != 3 {
+		t.Errorf("Expected 3 bonus bite cells. Got %d\n%s", count, game.board.String2D())
+	}
+	if count, _ = countBonusCells(game.board, CellFlagBonusReroll); count != 5 {
+		t.Errorf("Expected 5 bonus reroll cells. Got %d\n%s", count, game.board.String2D())
+	}
+
+	// mirrors and rotations come from the seats
+	for _, test := range []struct {
+		rows, cols, players, symmetries int
+	}{
+		{10, 10, 2, 4},
+		{10, 12, 2, 2},
+		{10, 10, 3, 2},
+		{10, 10, 4, 8},
+		{11, 11, 8, 8},
+	} {
+		board := newGameBoard(test.rows, test.cols)
+		setStartingPositions(board, test.players, false, nil)
+		if symmetries := boardSymmetries(board); len(symmetries) != test.symmetries {
+			t.Errorf("Expected %d symmetries for %d players on a %dx%d board. Got %d",
+				test.symmetries, test.players, test.rows, test.cols, len(symmetries))
+		}
+	}
+
+	// awards
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"size":               10,
+		"bonus_bite_award":   5,
+		"bonus_reroll_award": 2,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(10, 10)
+	game.board[0][0] = CellFlagBonusBite
+	game.board[0][1] = CellFlagBonusReroll
+	game.bites[0], game.rerolls[0] = 0, 0
+	game.addPieceToBoard(1, 0, 0b1100000_0000000_0000000_0000000_0000000_0000000_0000000)
+	if game.bites[0] != 5 || game.rerolls[0] != 2 {
+		t.Errorf("Expected 5 bites and 2 rerolls. Got %d and %d", game.bites[0], game.rerolls[0])
+	}
+
+	// bonus options are saved with the game
+	game.bonusBiteCells, game.bonusPlacement = 6, gameBonusSymmetric
+	s, err := game.toState()
+	if err != nil {
+		t.Fatalf("toState failed: %v", err)
+	}
+	loaded, err := gameFromState(s)
+	if err != nil {
+		t.Fatalf("gameFromState failed: %v", err)
+	}
+	if loaded.bonusBiteCells != 6 || loaded.bonusPlacement != gameBonusSymmetric || loaded.bonusBiteAward != 5 ||
+		loaded.bonusRerollAward != 2 {
+		t.Errorf("Expected the bonus options to be restored. Got %d cells, placement %d, awards %d and %d",
+			loaded.bonusBiteCells, loaded.bonusPlacement, loaded.bonusBiteAward, loaded.bonusRerollAward)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// Capture rules, applied on top of the capture mode
+const gbDefaultCaptureDiagonals = true // capture lines may be diagonal, not only vertical and horizontal
+const gbDefaultCaptureMinLength = 1
+const gbDefaultCaptureMaxLength = 0 // 0 for no limit
+const gbMaxCaptureLength = 20
+const gbDefaultCaptureChains = true            // captured cells may capture additional cells
+const gbDefaultCaptureHomeCells = true         // an opponent's home cell can be captured
+const gbDefaultCaptureThroughHomeCells = false // without captureHomeCells, the rest of a line with a home cell is still captured
+
+// validateCaptureLengths checks the capture_min_length and capture_max_length options passed to createGame
+func validateCaptureLengths(minLength, maxLength int) error {
+	if minLength < 1 || minLength > gbMaxCaptureLength {
+		return errors.New("Invalid capture_min_length parameter")
+	}
+	if maxLength != 0 && (maxLength < minLength || maxLength > gbMaxCaptureLength) {
+		return errors.New("Invalid capture_max_length parameter")
+	}
+	return nil
+}
+
+// captureDirections returns the directions to scan for captures. With bothWays, every
+// direction is returned. Otherwise only one direction of each line is returned, for
+// scans that start from every cell on the board.
+func (game *Game) captureDirections(bothWays bool) []Direction {
+	directions := []Direction{
+		directionRight,
+		directionDown,
+		directionDownRight,
+		directionDownLeft,
+	}
+	if bothWays {
+		directions = []Direction{
+			directionDownLeft,
+			directionLeft,
+			directionUpLeft,
+			directionUp,
+			directionUpRight,
+			directionRight,
+			directionDownRight,
+			directionDown,
+		}
+	}
+	if !game.captureDiagonals {
+		directions = slices.DeleteFunc(directions, func(d Direction) bool {
+			return d.row != 0 && d.col != 0
+		})
+	}
+	return directions
+}
+
+// captureLengthAllowed returns true if a line of length opponent cells may be captured
+func (game *Game) captureLengthAllowed(length int) bool {
+	return length >= game.captureMinLength && (game.captureMaxLength == 0 || length <= game.captureMaxLength)
+}
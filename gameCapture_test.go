@@ -0,0 +1,122 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCaptureRules(t *testing.T) {
+	var err error
+	var captured []int
+
+	lobbyName := "TestCaptureRules"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, opts := range []map[string]any{
+		{"capture_min_length": 0},
+		{"capture_min_length": gbMaxCaptureLength + 1},
+		{"capture_min_length": 3, "capture_max_length": 2},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 6})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	home := 2 | CellFlagHome
+
+	// orthogonal captures only
+	game.captureDiagonals = false
+	game.board = GameBoard{
+		{1, 2, 1, 0, 0, 0},
+		{0, 2, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+	}
+	captured = game.captureCells(1)
+	if !slices.Equal(captured, []int{1}) {
+		t.Errorf("Expected only the horizontal line to be captured. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.captureDiagonals = true
+
+	// capture lengths
+	game.captureMinLength = 2
+	game.captureMaxLength = 2
+	game.board = GameBoard{
+		{1, 2, 1, 0, 0, 0},
+		{1, 2, 2, 1, 0, 0},
+		{1, 2, 2, 2, 1, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+	}
+	captured = game.captureCells(1)
+	slices.Sort(captured)
+	if !slices.Equal(captured, []int{7, 8}) {
+		t.Errorf("Expected only the line of length 2 to be captured. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.captureMinLength = gbDefaultCaptureMinLength
+	game.captureMaxLength = gbDefaultCaptureMaxLength
+
+	// chain captures
+	chainBoard := GameBoard{
+		{0, 0, 0, 0, 0, 0},
+		{1, 2, 1, 0, 0, 0},
+		{0, 2, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+	}
+	game.board = chainBoard.clone()
+	captured = game.captureCells(1)
+	slices.Sort(captured)
+	if !slices.Equal(captured, []int{7, 13}) {
+		t.Errorf("Expected the captured cell to capture the next one. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.captureChains = false
+	game.board = chainBoard.clone()
+	captured = game.captureCells(1)
+	if !slices.Equal(captured, []int{7}) {
+		t.Errorf("Expected no chain capture. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.board = chainBoard.clone()
+	captured = game.captureCellsFromPiece(1, game.board.getIndex1D(1, 2), biteSmall)
+	if !slices.Equal(captured, []int{7}) {
+		t.Errorf("Expected no chain capture from the piece. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.captureChains = true
+
+	// home cells
+	homeBoard := GameBoard{
+		{1, 2, home, 2, 1, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+	}
+	game.board = homeBoard.clone()
+	captured = game.captureCells(1)
+	if len(captured) != 3 || game.board[0][2] != 1|CellFlagHome {
+		t.Errorf("Expected the home cell to be captured. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.captureHomeCells = false
+	game.board = homeBoard.clone()
+	captured = game.captureCells(1)
+	if len(captured) != 0 {
+		t.Errorf("Expected the home cell to break the line. Got %v\n%s", captured, game.board.String2D())
+	}
+	game.captureThroughHomeCells = true
+	game.board = homeBoard.clone()
+	captured = game.captureCells(1)
+	slices.Sort(captured)
+	if !slices.Equal(captured, []int{1, 3}) || game.board[0][2] != home {
+		t.Errorf("Expected the line to be captured around the home cell. Got %v\n%s", captured, game.board.String2D())
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+const gbDefaultDraftSize = 1 // pieces offered each turn, 1 disables the draft
+const gbMaxDraftSize = 4
+
+// validateDraftSize checks the draft_size option passed to createGame
+func validateDraftSize(size int) error {
+	if size < 1 || size > gbMaxDraftSize {
+		return errors.New("Invalid draft_size parameter")
+	}
+	return nil
+}
+
+// offerOf returns every piece playerIndex may choose from this turn, starting with pieceOf.
+// Without a draft, that is only pieceOf.
+func (game *Game) offerOf(playerIndex int) []Piece {
+	extra := game.draftPieces
+	if game.simultaneous && playerIndex >= 0 && playerIndex < game.playerCount {
+		extra = game.playerDraftPieces[playerIndex]
+	}
+	return append([]Piece{game.pieceOf(playerIndex)}, extra...)
+}
+
+// setOfferOf changes every piece playerIndex may choose from this turn
+func (game *Game) setOfferOf(playerIndex int, offer []Piece) {
+	game.setPieceOf(playerIndex, offer[0])
+	if game.simultaneous {
+		game.playerDraftPieces[playerIndex] = offer[1:]
+	} else {
+		game.draftPieces = offer[1:]
+	}
+}
+
+// offerHas returns true if mask is a rotation of any piece offered to playerIndex
+func (game *Game) offerHas(playerIndex int, mask PieceMask) bool {
+	for _, p := range game.offerOf(playerIndex) {
+		if p.has(mask) {
+			return true
+		}
+	}
+	return false
+}
+
+// dealDraft deals the pieces offered alongside game.nextPiece
+func (game *Game) dealDraft() {
+	game.draftPieces = nil
+	if game.isOver {
+		return
+	}
+	for len(game.draftPieces) < game.draftSize-1 {
+		game.draftPieces = append(game.draftPieces, game.dealPiece())
+	}
+}
+
+// redrawOffer replaces the whole offer of playerIndex with new pieces, leaving out the
+// pieces that were already offered. The offer is kept if there are no other pieces.
+func (game *Game) redrawOffer(playerIndex int) {
+	offer := game.offerOf(playerIndex)
+	rerollPieces := make([]Piece, 0, len(game.pieces))
+	for _, p := range game.pieces {
+		if !slices.Contains(offer, p) {
+			rerollPieces = append(rerollPieces, p)
+		}
+	}
+	if len(rerollPieces) == 0 {
+		return
+	}
+	newOffer := make([]Piece, 0, len(offer))
+	for range offer {
+		newOffer = append(newOffer, getWeightedRandomPiece(rerollPieces, game.rng))
+	}
+	game.setOfferOf(playerIndex, newOffer)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPieceDraft(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	dot := Piece{PieceMask(0b1000000).generateRotations(), 1}
+	bar := Piece{PieceMask(0b1000000_1000000).generateRotations(), 1}
+	corner := Piece{PieceMask(0b1000000_1100000).generateRotations(), 1}
+	square := Piece{PieceMask(0b1100000_1100000).generateRotations(), 1}
+
+	lobbyName := "TestPieceDraft"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, size := range []int{0, gbMaxDraftSize + 1} {
+		if _, err = createGame(activeLobbies[lobbyName], map[string]any{"draft_size": size}); err == nil {
+			t.Errorf("createGame should have failed with draft_size=%d", size)
+		}
+	}
+
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":       boardSize,
+		"pieces":     []Piece{dot, bar, corner, square},
+		"draft_size": 3,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if len(game.offerOf(0)) != 3 || game.allowHold {
+		t.Errorf("Expected 3 offered pieces and no hold. Got %d pieces, allowHold=%t", len(game.offerOf(0)), game.allowHold)
+	}
+	game.board = newGameBoard(boardSize, boardSize)
+	game.board[0][0] = 1 | CellFlagHome
+	game.board[9][9] = 2 | CellFlagHome
+	game.updateScores()
+	game.turn = 0
+	game.nextPiece = dot
+	game.draftPieces = []Piece{bar, corner}
+
+	// any rotation of any offered piece can be placed
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), square.Masks[0]); err == nil {
+		t.Error("Expected a piece that wasn't offered to be rejected")
+	}
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), corner.Masks[1]); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.scores[0] != 4 || game.turn != 1 || len(game.offerOf(1)) != 3 {
+		t.Errorf("Expected the corner on the board and a new offer for Player 2. scores=%v turn=%d\n%s",
+			game.scores, game.turn, game.board.String2D())
+	}
+
+	// a reroll replaces the whole offer
+	game.nextPiece = dot
+	game.draftPieces = []Piece{bar, bar}
+	game.rerolls[1] = 1
+	if err = game.reroll(p2); err != nil {
+		t.Fatalf("reroll failed: %v", err)
+	}
+	offer := game.offerOf(1)
+	if len(offer) != 3 || slices.Contains(offer, dot) || slices.Contains(offer, bar) {
+		t.Errorf("Expected a new offer without the dot or the bar. Got %v", offer)
+	}
+}
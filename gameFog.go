@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+const gbDefaultFogOfWar = 0 // vision radius, 0 disables fog of war
+const gbMaxFogOfWar = 10
+
+// validateFogOfWar checks the fog_of_war option passed to createGame
+func validateFogOfWar(radius int) error {
+	if radius < 0 || radius > gbMaxFogOfWar {
+		return errors.New("Invalid fog_of_war parameter")
+	}
+	return nil
+}
+
+// hasFog returns true if players can't see the whole board
+func (game *Game) hasFog() bool {
+	return game.fogOfWar > 0 && !game.isOver
+}
+
+// visibleCells returns which cells playerIndex can see, by 1D index. A cell is visible
+// if it is within game.fogOfWar rows and columns of a cell owned by the player or,
+// with teams, by a teammate. Returns nil if the player can see the whole board.
+func (game *Game) visibleCells(playerIndex int) []bool {
+	if !game.hasFog() {
+		return nil
+	}
+
+	// mark every cell within fogOfWar columns of a friendly cell, then spread
+	// those marks fogOfWar rows up and down
+	rows, cols, radius := game.rowCount, game.colCount, game.fogOfWar
+	player := Cell(playerIndex + 1)
+	nearRow := make([]bool, rows*cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if !game.isFriendly(player, game.board[r][c]&CellMaskPlayer) {
+				continue
+			}
+			for vc := max(c-radius, 0); vc <= min(c+radius, cols-1); vc++ {
+				nearRow[r*cols+vc] = true
+			}
+		}
+	}
+	visible := make([]bool, rows*cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if !nearRow[r*cols+c] {
+				continue
+			}
+			for vr := max(r-radius, 0); vr <= min(r+radius, rows-1); vr++ {
+				visible[vr*cols+c] = true
+			}
+		}
+	}
+	return visible
+}
+
+// fogBoard returns a copy of board with the cells that aren't visible replaced by
+// CellFlagHidden. board is returned as is if visible is nil.
+func fogBoard(board GameBoard, visible []bool) GameBoard {
+	if visible == nil || board == nil {
+		return board
+	}
+	fogged := board.clone()
+	for r := range fogged {
+		for c := range fogged[r] {
+			if !visible[fogged.getIndex1D(r, c)] {
+				fogged[r][c] = CellFlagHidden
+			}
+		}
+	}
+	return fogged
+}
+
+// fogIndices returns the 1D indices that are visible
+func fogIndices(indices []int, visible []bool) []int {
+	if visible == nil {
+		return indices
+	}
+	var filtered []int
+	for _, index := range indices {
+		if index >= 0 && index < len(visible) && visible[index] {
+			filtered = append(filtered, index)
+		}
+	}
+	return filtered
+}
+
+// fogMask returns the part of mask at 1D index that is visible. Cells off the board
+// are removed as well.
+func (game *Game) fogMask(index int, mask PieceMask, visible []bool) PieceMask {
+	if visible == nil {
+		return mask
+	}
+	if index < 0 || index >= len(visible) {
+		return 0
+	}
+	bRow, bCol := game.board.getIndex2D(index)
+	var fogged PieceMask
+	for pRow := 0; pRow < pieceMaskMaxLength; pRow++ {
+		for pCol := 0; pCol < pieceMaskMaxLength; pCol++ {
+			r, c := bRow+pRow, bCol+pCol
+			if !mask.has(pRow, pCol) || r >= game.rowCount || c >= game.colCount {
+				continue
+			}
+			if visible[game.board.getIndex1D(r, c)] {
+				fogged |= maskAt(pRow, pCol)
+			}
+		}
+	}
+	return fogged
+}
+
+// allCells returns a visibility slice where every cell is visible
+func (game *Game) allCells() []bool {
+	all := make([]bool, game.rowCount*game.colCount)
+	for i := range all {
+		all[i] = true
+	}
+	return all
+}
+
+// fogEvents returns a copy of events with everything playerIndex can't see removed.
+// Pieces and bites are cut down to their visible cells, or removed entirely (Index -1)
+// if none of their cells are visible.
+// Events are filtered by what the player can see now, not by what they could see when
+// the event happened. Squares the player can see now show their whole history, and
+// squares they could see before but can't anymore are hidden in past events too.
+func (game *Game) fogEvents(events []GameEvent, playerIndex int) []GameEvent {
+	visible := game.visibleCells(playerIndex)
+	if visible == nil {
+		return events
+	}
+	fogged := slices.Clone(events)
+	for i := range fogged {
+		event := &fogged[i]
+		if event.Index >= 0 {
+			event.Mask = game.fogMask(event.Index, event.Mask, visible)
+			if event.Mask == 0 {
+				event.Index = -1
+			}
+		}
+		event.Captured = fogIndices(event.Captured, visible)
+		event.Orphaned = fogIndices(event.Orphaned, visible)
+		event.Board = fogBoard(event.Board, visible)
+	}
+	return fogged
+}
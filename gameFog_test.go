@@ -0,0 +1,181 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFogOfWar(t *testing.T) {
+	var boardSize int = 12
+	var err error
+
+	lobbyName := "TestFogOfWar"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, radius := range []int{-1, gbMaxFogOfWar + 1} {
+		if _, err = createGame(activeLobbies[lobbyName], map[string]any{"fog_of_war": radius}); err == nil {
+			t.Errorf("createGame should have failed with fog_of_war=%d", radius)
+		}
+	}
+
+	// without fog, everything is visible
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": boardSize})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game.visibleCells(0) != nil {
+		t.Error("Expected every cell to be visible without fog of war")
+	}
+
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"size":       boardSize,
+		"fog_of_war": 2,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(boardSize, boardSize)
+	game.board[0][0] = 1 | CellFlagHome
+	game.board[1][0] = 1
+	game.board[3][3] = 2 | CellFlagHome
+	game.board[11][11] = 2 | CellFlagHome
+	game.board[6][6] = CellFlagBonusReroll
+	game.updateScores()
+	game.turn = 0
+	game.history[0].Board = game.board.clone()
+
+	// Player 1 sees 2 cells around their own
+	visible := game.visibleCells(0)
+	for _, tc := range []struct {
+		r, c    int
+		visible bool
+	}{
+		{0, 0, true},
+		{3, 2, true},
+		{3, 3, false},
+		{0, 2, true},
+		{2, 3, false},
+		{6, 6, false},
+		{11, 11, false},
+	} {
+		if visible[game.board.getIndex1D(tc.r, tc.c)] != tc.visible {
+			t.Errorf("Expected visibility of (%d,%d) for Player 1 to be %t", tc.r, tc.c, tc.visible)
+		}
+	}
+
+	// hidden cells don't reveal their owner or flags
+	board := fogBoard(game.board, visible)
+	if board[0][0] != 1|CellFlagHome || board[3][3] != CellFlagHidden || board[11][11] != CellFlagHidden {
+		t.Errorf("Unexpected fogged board for Player 1\n%s", board.String2D())
+	}
+	if game.board[3][3] != 2|CellFlagHome {
+		t.Error("fogBoard must not modify the board")
+	}
+	if !slices.Equal(fogIndices([]int{0, 39, 143}, visible), []int{0}) {
+		t.Errorf("Expected only index 0 to be visible. Got %v", fogIndices([]int{0, 39, 143}, visible))
+	}
+
+	// masks are cut down to the visible cells
+	square := maskAt(0, 0) | maskAt(0, 1) | maskAt(1, 0) | maskAt(1, 1)
+	if mask := game.fogMask(game.board.getIndex1D(2, 2), square, visible); mask != maskAt(0, 0)|maskAt(1, 0) {
+		t.Errorf("Expected the left half of the square to be visible. Got\n%s", mask.String2D())
+	}
+	if mask := game.fogMask(game.board.getIndex1D(8, 8), square, visible); mask != 0 {
+		t.Errorf("Expected none of the square to be visible. Got\n%s", mask.String2D())
+	}
+	if mask := game.fogMask(-1, square, visible); mask != 0 {
+		t.Errorf("Expected an invalid index to hide the whole mask. Got\n%s", mask.String2D())
+	}
+
+	// a partly visible preview would give its position away, so it is cleared instead
+	for _, tc := range []struct {
+		r, c  int
+		clear bool
+	}{
+		{0, 0, false},
+		{2, 2, true},
+		{8, 8, true},
+	} {
+		update := &MessagePayloadBoardUpdatePreview{"piece", 2, game.board.getIndex1D(tc.r, tc.c), square}
+		if preview := game.fogPreview(update, visible); (preview.Action == "clear") != tc.clear ||
+			(!tc.clear && *preview != *update) {
+			t.Errorf("Unexpected preview of the square at (%d,%d) for Player 1. Got %+v", tc.r, tc.c, preview)
+		}
+	}
+
+	// Player 2 places a piece where Player 1 can't see it
+	game.turn = 1
+	game.nextPiece = Piece{square.generateRotations(), 1}
+	p2 := game.players[1]
+	if err = game.placePiece(p2, game.board.getIndex1D(3, 4), square); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	events := game.fogEvents(game.history, 0)
+	start, placed := events[0], events[len(events)-1]
+	if start.Board[0][0] != 1|CellFlagHome || start.Board[11][11] != CellFlagHidden {
+		t.Errorf("Expected the start board to be fogged\n%s", start.Board.String2D())
+	}
+	if placed.Type != gameEventPlacePiece || placed.Index != -1 || placed.Mask != 0 {
+		t.Errorf("Expected Player 2's move to be hidden from Player 1. Got %+v", placed)
+	}
+	if game.history[len(game.history)-1].Index < 0 || game.history[0].Board[11][11] != 2|CellFlagHome {
+		t.Error("fogEvents must not modify the history")
+	}
+	if events = game.fogEvents(game.history, 1); events[len(events)-1].Mask != square {
+		t.Errorf("Expected Player 2 to see their own move. Got %+v", events[len(events)-1])
+	}
+
+	// the fog lifts once the game is over
+	if err = game.forfeitGame(p1); err != nil {
+		t.Fatalf("forfeitGame failed: %v", err)
+	}
+	if game.visibleCells(0) != nil {
+		t.Error("Expected every cell to be visible after the game is over")
+	}
+}
+
+func TestFogOfWarTeams(t *testing.T) {
+	var boardSize int = 12
+	var err error
+
+	lobbyName := "TestFogOfWarTeams"
+	for _, name := range []string{"p1", "p2", "p3", "p4"} {
+		_ = joinLobbyWrapper(t, lobbyName, name, "")
+	}
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":       boardSize,
+		"teams":      true,
+		"fog_of_war": 1,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+
+	// teammates share what they can see
+	visible := game.visibleCells(0)
+	for _, tc := range []struct {
+		player  int
+		visible bool
+	}{
+		{0, true},
+		{1, false},
+		{2, true},
+		{3, false},
+	} {
+		r, c := -1, -1
+		for i := range game.board {
+			for j := range game.board[i] {
+				if game.board[i][j] == Cell(tc.player+1)|CellFlagHome {
+					r, c = i, j
+				}
+			}
+		}
+		if r < 0 {
+			t.Fatalf("No home cell for Player %d\n%s", tc.player+1, game.board.String2D())
+		}
+		if visible[game.board.getIndex1D(r, c)] != tc.visible {
+			t.Errorf("Expected Player %d's home to be visible to Player 1: %t", tc.player+1, tc.visible)
+		}
+	}
+}
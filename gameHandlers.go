@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +28,9 @@ type Message struct {
 	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
+// botMoveDelay is how long bots wait before moving so that players can follow along
+var botMoveDelay = 750 * time.Millisecond
+
 // MessagePayloadPlayerInfo is the payload for messages where
 // type == "player_info"
 type MessagePayloadPlayerInfo struct {
@@ -33,6 +38,8 @@ type MessagePayloadPlayerInfo struct {
 	// Identity tells the requestor which Player they are (index into Players)
 	Identity          int           `json:"identity"`
 	WinLossDrawRecord []WinLossDraw `json:"win_loss_draw_record"`
+	// Teams holds the team of each player, nil without team play
+	Teams []int `json:"teams"`
 }
 
 // MessagePayloadGameAction is the payload for messages where
@@ -61,6 +68,45 @@ type MessagePayloadGameInfo struct {
 	Bites           []int     `json:"bites"`
 	Rerolls         []int     `json:"rerolls"`
 	GameOver        bool      `json:"game_over"`
+	// Winners are the players with the best result once the game is over. More than one is a draw.
+	Winners []int `json:"winners"`
+	// Ranking is every player ordered by score once the game is over
+	Ranking []int `json:"ranking"`
+	// UndoPlayer is the player allowed to request an undo (-1 if none)
+	UndoPlayer  int          `json:"undo_player"`
+	UndoRequest *UndoRequest `json:"undo_request"`
+	// TimeLeft is the time each player has for their current or next turn in milliseconds,
+	// nil if the game has no timer
+	TimeLeft []int64 `json:"time_left_ms"`
+	// Simultaneous is true if every player moves at once each round
+	Simultaneous bool `json:"simultaneous"`
+	// Submitted holds which players have submitted a move this round, nil without simultaneous turns
+	Submitted []bool `json:"submitted"`
+	// PendingMove is the move the receiving player submitted this round, if any
+	PendingMove *RoundMove `json:"pending_move"`
+	// UpcomingPieces are the pieces dealt after NextPiece, in order
+	UpcomingPieces []Piece `json:"upcoming_pieces"`
+	// HeldPieces are the pieces each player set aside (masks of 0 if none), nil if holding isn't allowed
+	HeldPieces []Piece `json:"held_pieces"`
+	// CanHold is true if the receiving player may still hold a piece this turn
+	CanHold bool `json:"can_hold"`
+	// OfferedPieces are the pieces the receiving player may choose from this turn, starting with NextPiece
+	OfferedPieces []Piece `json:"offered_pieces"`
+	// MirrorPieces is true if pieces may be flipped
+	MirrorPieces bool `json:"mirror_pieces"`
+	// BiteShapes are the bites players may place and their costs
+	BiteShapes []Bite `json:"bite_shapes"`
+	// Round is the number of rounds played so far. The game ends after MaxRounds, 0 if there is no limit.
+	Round     int `json:"round"`
+	MaxRounds int `json:"max_rounds"`
+}
+
+// MessagePayloadGameHistory is the payload for messages where
+// type == "game_history"
+// Events replace the client's history starting at Offset.
+type MessagePayloadGameHistory struct {
+	Offset int         `json:"offset"`
+	Events []GameEvent `json:"events"`
 }
 
 // MessagePayloadBoardUpdate is the payload for messages where
@@ -87,14 +133,21 @@ type MessagePayloadError struct {
 	Message string `json:"message"`
 }
 
-// gameArgCustomPieces is the payload for custom game pieces
-type gameArgCustomPieces struct {
-	Data []gameArgCustomPiece `json:"data"`
+// gameArgCustomMasks is the payload for custom game pieces or bites
+type gameArgCustomMasks[T any] struct {
+	Data []T `json:"data"`
+	// MaskLength is the number of rows and columns of each mask. Pieces saved by older
+	// clients don't have it and are legacyPieceMaskLength by legacyPieceMaskLength.
+	MaskLength int `json:"mask_length"`
 }
 type gameArgCustomPiece struct {
 	Mask   PieceMask `json:"mask"`
 	Weight float64   `json:"weight"`
 }
+type gameArgCustomBite struct {
+	Mask PieceMask `json:"mask"`
+	Cost *int      `json:"cost"` // omitted or null for the default cost, see CalcBiteCost
+}
 
 // getGamePlayerFromReq gets the player based on cookies and optionally updates the
 // lastSeen field for that player.
@@ -145,10 +198,12 @@ func getGamePlayerFromReq(r *http.Request, updateLastSeen bool) (uuid.UUID, Play
 	return uuid.Nil, Player{}, errors.New("Player not found")
 }
 
-// handle converting the pieces url arg to the format expected by createGame
-func parsePiecesArg(arg string) ([]Piece, error) {
-	var unmarshalled gameArgCustomPieces
-	var pieces []Piece
+// parseMasksArg decodes the pieces or bites url arg named name. Entries with an empty
+// mask or a mask that doesn't fit in mask_length are skipped, the others are resized
+// to pieceMaskMaxLength. maskOf returns the mask of an entry.
+func parseMasksArg[T any](arg string, name string, maskOf func(*T) *PieceMask) ([]T, error) {
+	var unmarshalled gameArgCustomMasks[T]
+	var entries []T
 
 	jstring, err := url.QueryUnescape(arg)
 	if err != nil {
@@ -160,16 +215,42 @@ func parsePiecesArg(arg string) ([]Piece, error) {
 		return nil, err
 	}
 
-	for _, p := range unmarshalled.Data {
-		if p.Weight <= 0 {
+	maskLength := unmarshalled.MaskLength
+	if maskLength == 0 {
+		maskLength = legacyPieceMaskLength
+	}
+	if maskLength < 0 || maskLength > pieceMaskMaxLength {
+		return nil, fmt.Errorf("Invalid %s parameter: mask_length out of range", name)
+	}
+	fullMask := PieceMask(1)<<(maskLength*maskLength) - 1
+
+	for _, entry := range unmarshalled.Data {
+		mask := maskOf(&entry)
+		if *mask == 0 || *mask&^fullMask != 0 {
 			if debug {
-				serverlog.Printf("Skipping custom piece with zero or negative weight: %v", p)
+				serverlog.Printf("Skipping custom %s entry with invalid mask: %v mask: %b", name, entry, *mask)
 			}
 			continue
 		}
-		if p.Mask == 0 || p.Mask&^pieceMaskFullMask != 0 {
+		*mask = resizePieceMask(*mask, maskLength)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// handle converting the pieces url arg to the format expected by createGame
+func parsePiecesArg(arg string) ([]Piece, error) {
+	var pieces []Piece
+
+	custom, err := parseMasksArg(arg, "pieces", func(p *gameArgCustomPiece) *PieceMask { return &p.Mask })
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range custom {
+		if p.Weight <= 0 {
 			if debug {
-				serverlog.Printf("Skipping custom piece with invalid mask: peice: %v mask: %b", p, p.Mask)
+				serverlog.Printf("Skipping custom piece with zero or negative weight: %v", p)
 			}
 			continue
 		}
@@ -178,6 +259,79 @@ func parsePiecesArg(arg string) ([]Piece, error) {
 	return pieces, nil
 }
 
+// handle converting the bites url arg to the format expected by createGame
+func parseBitesArg(arg string) ([]Bite, error) {
+	var bites []Bite
+
+	custom, err := parseMasksArg(arg, "bites", func(b *gameArgCustomBite) *PieceMask { return &b.Mask })
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range custom {
+		bite := Bite{b.Mask.shiftUp(), 0}
+		if b.Cost == nil {
+			bite.Cost = bite.Mask.CalcBiteCost()
+		} else if *b.Cost >= 0 {
+			bite.Cost = *b.Cost
+		} else {
+			if debug {
+				serverlog.Printf("Skipping custom bite with negative cost: %v", b)
+			}
+			continue
+		}
+		if slices.ContainsFunc(bites, func(other Bite) bool { return other.Mask == bite.Mask }) {
+			if debug {
+				serverlog.Printf("Skipping duplicate custom bite: %v", b)
+			}
+			continue
+		}
+		bites = append(bites, bite)
+	}
+	if len(bites) > gbMaxBiteShapes {
+		return nil, fmt.Errorf("Invalid bites parameter: more than %d bites", gbMaxBiteShapes)
+	}
+	return bites, nil
+}
+
+// MapInfo describes a map that games can be created from
+type MapInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Players     []int  `json:"players"`
+	Rows        int    `json:"rows"`
+	Cols        int    `json:"cols"`
+}
+
+// MapList is the response for mapListHandler
+type MapList struct {
+	Maps []MapInfo `json:"maps"`
+}
+
+// mapListHandler returns the maps loaded with --maps-dir as json
+func mapListHandler(w http.ResponseWriter, r *http.Request) {
+	maps := MapList{Maps: []MapInfo{}}
+	for _, m := range gameMaps {
+		maps.Maps = append(maps.Maps, MapInfo{
+			Name:        m.Name,
+			Description: m.Description,
+			Players:     m.Players,
+			Rows:        len(m.board),
+			Cols:        len(m.board[0]),
+		})
+	}
+	slices.SortFunc(maps.Maps, func(a, b MapInfo) int { return strings.Compare(a.Name, b.Name) })
+
+	j, err := json.Marshal(maps)
+	if err != nil {
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(j))
+}
+
 // createGameHandler creates a new game for the players in the lobby with the requestor.
 // It redirects the player to the join game endpoint.
 func createGameHandler(w http.ResponseWriter, r *http.Request) {
@@ -209,6 +363,15 @@ func createGameHandler(w http.ResponseWriter, r *http.Request) {
 	for _, boolArg := range []string{
 		"randomize_start_positions",
 		"has_bonus_bite_cells",
+		"teams",
+		"team_shared_paths",
+		"simultaneous",
+		"allow_hold",
+		"mirror_pieces",
+		"capture_diagonals",
+		"capture_chains",
+		"capture_home_cells",
+		"capture_through_home_cells",
 	} {
 		if s := r.URL.Query().Get(boolArg); s != "" {
 			if parsed, err := strconv.ParseBool(s); err == nil {
@@ -220,10 +383,37 @@ func createGameHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	for _, intArg := range []string{
 		"size",
+		"rows",
+		"cols",
 		"starting_bites",
 		"starting_rerolls",
+		"bonus_bite_cells",
 		"bonus_reroll_cells",
+		"bonus_bite_award",
+		"bonus_reroll_award",
+		"bonus_placement",
+		"bonus_home_clearance",
+		"shield_cells",
+		"multiplier_cells",
+		"spore_cells",
 		"capture_mode",
+		"capture_min_length",
+		"capture_max_length",
+		"walls",
+		"timer",
+		"turn_time",
+		"clock_time",
+		"clock_increment",
+		"timeout_action",
+		"fog_of_war",
+		"piece_distribution",
+		"piece_queue_length",
+		"draft_size",
+		"win_territory",
+		"win_score",
+		"max_rounds",
+		"tie_break",
+		"max_players",
 	} {
 		if s := r.URL.Query().Get(intArg); s != "" {
 			if parsed, err := strconv.Atoi(s); err == nil {
@@ -233,7 +423,7 @@ func createGameHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	for _, floatArg := range []string{"new_bites_freq_factor"} {
+	for _, floatArg := range []string{"new_bites_freq_factor", "wall_density"} {
 		if s := r.URL.Query().Get(floatArg); s != "" {
 			if parsed, err := strconv.ParseFloat(s, 64); err == nil {
 				createGameOpts[floatArg] = parsed
@@ -242,6 +432,16 @@ func createGameHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	if s := r.URL.Query().Get("map"); s != "" {
+		createGameOpts["map"] = s
+	}
+	if s := r.URL.Query().Get("seed"); s != "" {
+		if parsed, err := strconv.ParseUint(s, 10, 64); err == nil {
+			createGameOpts["seed"] = parsed
+		} else {
+			serverlog.Printf("Failed to parse unsigned integer URL arg seed with value %s.\n", s)
+		}
+	}
 	// custom game pieces need to be unmarshalled and converted to []Piece
 	if j := r.URL.Query().Get("pieces"); j != "" {
 		pieces, err := parsePiecesArg(j)
@@ -252,12 +452,42 @@ func createGameHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// custom bites are unmarshalled the same way
+	if j := r.URL.Query().Get("bites"); j != "" {
+		bites, err := parseBitesArg(j)
+		if err == nil {
+			createGameOpts["bites"] = bites
+		} else {
+			serverlog.Printf("Failed to parse bites URL arg with value %s: %v\n", j, err)
+		}
+	}
+
 	game, err := createGame(lobby, createGameOpts)
 	if err != nil {
 		if err.Error() == "A game requires at least two players" {
 			http.Redirect(w, r, "/static/error_pages/lobby.html?err=not_enough_players", http.StatusFound)
 			return
 		}
+		if strings.HasPrefix(err.Error(), "Too many players") {
+			http.Redirect(w, r, "/static/error_pages/lobby.html?err=too_many_players", http.StatusFound)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "Games with more than") {
+			http.Redirect(w, r, "/static/error_pages/lobby.html?err=board_too_small", http.StatusFound)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "Team play requires") {
+			http.Redirect(w, r, "/static/error_pages/lobby.html?err=team_players", http.StatusFound)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "Simultaneous turns") {
+			http.Redirect(w, r, "/static/error_pages/lobby.html?err=simultaneous_clock", http.StatusFound)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "Unknown map") || strings.HasPrefix(err.Error(), "Map ") {
+			http.Redirect(w, r, "/static/error_pages/lobby.html?err=unsupported_map", http.StatusFound)
+			return
+		}
 		if debug {
 			serverlog.Printf("createGame(%s) had error: %s\n", lobbyName, err.Error())
 		}
@@ -270,6 +500,7 @@ func createGameHandler(w http.ResponseWriter, r *http.Request) {
 	if debug {
 		serverlog.Println(game.String())
 	}
+	go gameRunBots(game)
 
 	http.Redirect(w, r, "/game/join", http.StatusFound)
 }
@@ -366,12 +597,19 @@ func gameWsHandler(w http.ResponseWriter, r *http.Request) {
 		c.Close(websocket.StatusInternalError, "send error")
 		return
 	}
-	err = gameWsSendGameInfo(c, thisGame, false)
+	err = gameWsSendGameHistory(c, thisGame, player, 0, false)
+	if err != nil {
+		serverlog.Printf("Failed to send game_history to client: %v\n", err)
+		c.Close(websocket.StatusInternalError, "send error")
+		return
+	}
+	err = gameWsSendGameInfo(c, thisGame, player, false)
 	if err != nil {
 		serverlog.Printf("Failed to send game_info to client: %v\n", err)
 		c.Close(websocket.StatusInternalError, "send error")
 		return
 	}
+	go gameRunBots(thisGame) // in case the bots stopped, e.g. after a restart
 
 	// Wait for client messages
 	for {
@@ -466,6 +704,11 @@ func gameWsSendPlayerInfo(conn *websocket.Conn, game *Game, whoami Player, hasLo
 		Identity:          identity,
 		WinLossDrawRecord: game.winLossDrawRecord[:game.playerCount],
 	}
+	if game.teams {
+		for i := 0; i < game.playerCount; i++ {
+			payload.Teams = append(payload.Teams, game.teamOf(i))
+		}
+	}
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -496,20 +739,53 @@ func gameWsBroadcastPlayerInfo(game *Game) {
 	wg.Wait() // wait for go routines to complete before releasing game.mu lock
 }
 
-// gameWsSendGameInfo sends the client a message of type "game_info" for game
-func gameWsSendGameInfo(conn *websocket.Conn, game *Game, hasLock bool) error {
+// gameWsSendGameInfo sends the client a message of type "game_info" for game. With fog of
+// war, only the cells whoami can see are sent.
+func gameWsSendGameInfo(conn *websocket.Conn, game *Game, whoami Player, hasLock bool) error {
 	if !hasLock {
 		game.mu.Lock()
 	}
+	playerIndex := game.getPlayerIndex(whoami)
+	visible := game.visibleCells(playerIndex)
 	payload := MessagePayloadGameInfo{
-		Board:           game.board,
-		LastBoardUpdate: game.lastBoardUpdate,
+		Board:           fogBoard(game.board, visible),
+		LastBoardUpdate: fogIndices(game.lastBoardUpdate, visible),
 		Turn:            game.turn,
-		NextPiece:       game.nextPiece,
+		NextPiece:       game.pieceOf(playerIndex),
+		UpcomingPieces:  game.upcomingPieces(),
+		CanHold:         game.canHold(playerIndex),
+		OfferedPieces:   game.offerOf(playerIndex),
+		MirrorPieces:    game.mirrorPieces,
+		BiteShapes:      game.biteShapes,
+		Round:           game.round,
+		MaxRounds:       game.maxRounds,
 		Scores:          game.scores[:game.playerCount],
 		Bites:           game.bites[:game.playerCount],
 		Rerolls:         game.rerolls[:game.playerCount],
 		GameOver:        game.isOver,
+		UndoPlayer:      -1,
+		TimeLeft:        game.timesLeft(time.Now()),
+		Simultaneous:    game.simultaneous,
+		Submitted:       game.submitted(),
+	}
+	if game.allowHold {
+		payload.HeldPieces = game.heldPieces[:game.playerCount]
+	}
+	if playerIndex >= 0 && game.roundMoves[playerIndex] != nil {
+		pendingMove := *game.roundMoves[playerIndex]
+		payload.PendingMove = &pendingMove
+	}
+	if game.isOver {
+		payload.Winners = slices.Clone(game.winners)
+		payload.Ranking = game.rankPlayers()
+	}
+	if game.undoSnapshot != nil {
+		payload.UndoPlayer = game.undoSnapshot.turn
+	}
+	if game.undoRequest != nil {
+		undoRequest := *game.undoRequest
+		undoRequest.Pending = slices.Clone(undoRequest.Pending)
+		payload.UndoRequest = &undoRequest
 	}
 	if !hasLock {
 		game.mu.Unlock()
@@ -536,7 +812,7 @@ func gameWsBroadcastGameInfo(game *Game) {
 		if game.wsConns[i] != nil {
 			wg.Add(1)
 			go func(connIndex int) {
-				gameWsSendGameInfo(game.wsConns[connIndex], game, true)
+				gameWsSendGameInfo(game.wsConns[connIndex], game, game.players[connIndex], true)
 				defer wg.Done()
 			}(i)
 		}
@@ -544,6 +820,59 @@ func gameWsBroadcastGameInfo(game *Game) {
 	wg.Wait() // wait for go routines to complete before releasing game.mu lock
 }
 
+// gameWsSendGameHistory sends the client a message of type "game_history" containing
+// the events of game starting at offset. With fog of war, only what whoami can see is sent.
+func gameWsSendGameHistory(conn *websocket.Conn, game *Game, whoami Player, offset int, hasLock bool) error {
+	if !hasLock {
+		game.mu.Lock()
+	}
+	offset = min(max(offset, 0), len(game.history))
+	payload := MessagePayloadGameHistory{
+		Offset: offset,
+		Events: game.fogEvents(game.history[offset:], game.getPlayerIndex(whoami)),
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if !hasLock {
+		game.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	msg := Message{
+		Type:    "game_history",
+		Payload: payloadBytes,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return wsjson.Write(ctx, conn, msg)
+}
+
+// send "game_history" update with events that haven't been broadcast yet to all
+// connected players for game
+func gameWsBroadcastGameHistory(game *Game) {
+	var wg sync.WaitGroup
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	if game.historyBroadcast >= len(game.history) {
+		return
+	}
+	offset := game.historyBroadcast
+	if game.fogOfWar > 0 && game.isOver {
+		offset = 0 // the fog has lifted, resend everything that was hidden
+	}
+	for i := 0; i < game.playerCount; i++ {
+		if game.wsConns[i] != nil {
+			wg.Add(1)
+			go func(connIndex int) {
+				gameWsSendGameHistory(game.wsConns[connIndex], game, game.players[connIndex], offset, true)
+				defer wg.Done()
+			}(i)
+		}
+	}
+	wg.Wait() // wait for go routines to complete before releasing game.mu lock
+	game.historyBroadcast = len(game.history)
+}
+
 // gameWsSendError sends the client a message of type "error"
 func gameWsSendError(conn *websocket.Conn, message string) error {
 	payload := MessagePayloadError{
@@ -589,7 +918,7 @@ func gameWsHandleBoardUpdate(conn *websocket.Conn, game *Game, whoami Player, ms
 	handleError := func(serverMessage, clientMessage string) {
 		serverlog.Println(serverMessage + ": " + clientMessage)
 		_ = gameWsSendError(conn, clientMessage)
-		err := gameWsSendGameInfo(conn, game, false) // reset the board
+		err := gameWsSendGameInfo(conn, game, whoami, false) // reset the board
 		if err != nil {
 			serverlog.Printf("Failed to send error message to client: %v\n", err)
 			conn.Close(websocket.StatusInternalError, "send error")
@@ -635,7 +964,9 @@ func gameWsHandleBoardUpdate(conn *websocket.Conn, game *Game, whoami Player, ms
 			)
 			return
 		}
-		gameWsBroadcastBoardUpdatePreview(game, &preCapturePreview, []int{playerIndex})
+		if !game.simultaneous {
+			gameWsBroadcastBoardUpdatePreview(game, &preCapturePreview, []int{playerIndex})
+		}
 	case "place_bite":
 		err = game.placeBite(whoami, payload.Index, payload.Mask)
 		if err != nil {
@@ -645,7 +976,9 @@ func gameWsHandleBoardUpdate(conn *websocket.Conn, game *Game, whoami Player, ms
 			)
 			return
 		}
-		gameWsBroadcastBoardUpdatePreview(game, &preCapturePreview, []int{playerIndex})
+		if !game.simultaneous {
+			gameWsBroadcastBoardUpdatePreview(game, &preCapturePreview, []int{playerIndex})
+		}
 	default:
 		serverlog.Println("Skipping unexpected board_update action: " + payload.Action)
 		return
@@ -665,8 +998,10 @@ func gameWsHandleBoardUpdate(conn *websocket.Conn, game *Game, whoami Player, ms
 	if game.isOver {
 		gameWsBroadcastPlayerInfo(game)
 	}
+	gameWsBroadcastGameHistory(game)
 	gameWsBroadcastGameInfo(game)
 	game.clearLastBoardUpdate()
+	go gameRunBots(game)
 }
 
 // gameWsSendBoardUpdatePreview sends the client a message of type "board_update_preview"
@@ -684,8 +1019,21 @@ func gameWsSendBoardUpdatePreview(conn *websocket.Conn, update *MessagePayloadBo
 	return wsjson.Write(ctx, conn, msg)
 }
 
+// fogPreview returns the version of update that a player who can see visible is sent.
+// A piece or bite that is only partly visible would give away where it is, so the
+// player is sent a preview that clears their board instead.
+func (game *Game) fogPreview(update *MessagePayloadBoardUpdatePreview, visible []bool) *MessagePayloadBoardUpdatePreview {
+	if visible == nil || update.Action == "clear" {
+		return update
+	}
+	if game.fogMask(update.Index, update.Mask, visible) != game.fogMask(update.Index, update.Mask, game.allCells()) {
+		return &MessagePayloadBoardUpdatePreview{Action: "clear"}
+	}
+	return update
+}
+
 // send "board_update_preview" update to all connected players for game, except for players
-// in skip
+// in skip. With fog of war, players only see previews that are entirely visible to them.
 func gameWsBroadcastBoardUpdatePreview(game *Game, update *MessagePayloadBoardUpdatePreview, skip []int) {
 	var wg sync.WaitGroup
 	game.mu.Lock()
@@ -701,10 +1049,11 @@ func gameWsBroadcastBoardUpdatePreview(game *Game, update *MessagePayloadBoardUp
 		if doSkip {
 			continue
 		}
+		playerUpdate := game.fogPreview(update, game.visibleCells(i))
 		if game.wsConns[i] != nil {
 			wg.Add(1)
 			go func(connIndex int) {
-				gameWsSendBoardUpdatePreview(game.wsConns[i], update)
+				gameWsSendBoardUpdatePreview(game.wsConns[i], playerUpdate)
 				defer wg.Done()
 			}(i)
 		}
@@ -715,6 +1064,9 @@ func gameWsBroadcastBoardUpdatePreview(game *Game, update *MessagePayloadBoardUp
 // gameWsHandleBoardUpdatePreview sends a preview of a player's move to all players, except the
 // one sending the update
 func gameWsHandleBoardUpdatePreview(conn *websocket.Conn, game *Game, whoami Player, msg *Message) {
+	if game.simultaneous {
+		return // moves stay secret until the round is resolved
+	}
 	var payload MessagePayloadBoardUpdatePreview
 	err := json.Unmarshal(msg.Payload, &payload)
 	if err != nil {
@@ -731,7 +1083,7 @@ func gameWsHandleGameAction(conn *websocket.Conn, game *Game, whoami Player, msg
 	handleError := func(serverMessage, clientMessage string) {
 		serverlog.Println(serverMessage + ": " + clientMessage)
 		_ = gameWsSendError(conn, clientMessage)
-		err := gameWsSendGameInfo(conn, game, false) // reset the board
+		err := gameWsSendGameInfo(conn, game, whoami, false) // reset the board
 		if err != nil {
 			serverlog.Printf("Failed to send error message to client: %v\n", err)
 			conn.Close(websocket.StatusInternalError, "send error")
@@ -764,8 +1116,42 @@ func gameWsHandleGameAction(conn *websocket.Conn, game *Game, whoami Player, msg
 			)
 			return
 		}
+	case "hold_piece":
+		err = game.holdPiece(whoami)
+		if err != nil {
+			handleError(
+				fmt.Sprintf("holdPiece failed. Player=%v %v", whoami.id, game.shortDesc()),
+				err.Error(),
+			)
+			return
+		}
+	case "request_undo":
+		err = game.requestUndo(whoami)
+		if err != nil {
+			handleError(
+				fmt.Sprintf("requestUndo failed. Player=%v %v", whoami.id, game.shortDesc()),
+				err.Error(),
+			)
+			return
+		}
+	case "accept_undo", "decline_undo":
+		err = game.respondUndo(whoami, payload.Action == "accept_undo")
+		if err != nil {
+			handleError(
+				fmt.Sprintf("respondUndo failed. Player=%v %v", whoami.id, game.shortDesc()),
+				err.Error(),
+			)
+			return
+		}
 	case "forfeit_game":
-		game.forfeitGame(whoami)
+		err = game.forfeitGame(whoami)
+		if err != nil {
+			handleError(
+				fmt.Sprintf("forfeitGame failed. Player=%v %v", whoami.id, game.shortDesc()),
+				err.Error(),
+			)
+			return
+		}
 	case "reset_game":
 		game.resetGame()
 		gameWsBroadcastPlayerInfo(game)
@@ -774,9 +1160,129 @@ func gameWsHandleGameAction(conn *websocket.Conn, game *Game, whoami Player, msg
 		return
 	}
 
-	// send game_info to all connected players of game
+	// skipping or rerolling can end the game if no one is left who can move
+	if game.isOver {
+		gameWsBroadcastPlayerInfo(game)
+	}
+	// send game_history and game_info to all connected players of game
+	gameWsBroadcastGameHistory(game)
 	gameWsBroadcastGameInfo(game)
 	game.clearLastBoardUpdate()
+	go gameRunBots(game)
+}
+
+// gameExpireTurns skips or forfeits players who ran out of time in every active game
+// and sends the updates to the connected players
+func gameExpireTurns() {
+	activeGameMutex.Lock()
+	games := make([]*Game, 0, len(activeGames))
+	for _, game := range activeGames {
+		games = append(games, game)
+	}
+	activeGameMutex.Unlock()
+
+	now := time.Now()
+	for _, game := range games {
+		if !game.expireTurn(now) {
+			continue
+		}
+		if debug {
+			serverlog.Printf("Player ran out of time. %s\n", game.shortDesc())
+		}
+		game.mu.Lock()
+		isOver := game.isOver
+		game.mu.Unlock()
+		if isOver {
+			gameWsBroadcastPlayerInfo(game)
+		}
+		gameWsBroadcastGameHistory(game)
+		gameWsBroadcastGameInfo(game)
+		game.clearLastBoardUpdate()
+		go gameRunBots(game)
+	}
+}
+
+// gameExpireTurnsBackgroundTask calls gameExpireTurns every second, so that turns
+// time out even if nobody is connected to the game
+func gameExpireTurnsBackgroundTask() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		gameExpireTurns()
+	}
+}
+
+// gameRunBots plays bot turns and answers undo requests for bots until a human has
+// to act. Updates are broadcast after every bot move. Only one runner per game is
+// active at a time.
+func gameRunBots(game *Game) {
+	for {
+		game.mu.Lock()
+		if game.botsRunning || !game.botNeedsToAct() {
+			game.mu.Unlock()
+			return
+		}
+		game.botsRunning = true
+		game.mu.Unlock()
+
+		err := gameRunBotsUntilIdle(game)
+
+		game.mu.Lock()
+		game.botsRunning = false
+		game.mu.Unlock()
+		if err != nil {
+			serverlog.Printf("Stopped running bots. %v: %v\n", game.shortDesc(), err)
+			return
+		}
+		// loop to check again in case a human moved while the last bot move was being played
+	}
+}
+
+// gameRunBotsUntilIdle is the body of gameRunBots. The caller must have set game.botsRunning.
+func gameRunBotsUntilIdle(game *Game) error {
+	for {
+		accepted, err := game.botsAcceptUndo()
+		if err != nil {
+			return fmt.Errorf("Bot failed to accept undo: %v", err)
+		}
+		if accepted {
+			gameWsBroadcastGameHistory(game)
+			gameWsBroadcastGameInfo(game)
+		}
+
+		time.Sleep(botMoveDelay)
+		bot, move, err := game.playBotTurn()
+		if err != nil {
+			return fmt.Errorf("Bot %s move %+v failed: %v", bot.Name, move, err)
+		}
+		if move.action == "" {
+			return nil // no bot needs to move
+		}
+
+		if !game.simultaneous && (move.action == gameEventPlacePiece || move.action == gameEventPlaceBite) {
+			game.mu.Lock()
+			owner := Cell(game.getPlayerIndex(bot) + 1)
+			game.mu.Unlock()
+			preview := MessagePayloadBoardUpdatePreview{
+				Action: move.action,
+				Owner:  owner,
+				Index:  move.index,
+				Mask:   move.mask,
+			}
+			gameWsBroadcastBoardUpdatePreview(game, &preview, nil)
+		}
+		game.mu.Lock()
+		isOver := game.isOver
+		game.mu.Unlock()
+		if isOver {
+			gameWsBroadcastPlayerInfo(game)
+		}
+		gameWsBroadcastGameHistory(game)
+		gameWsBroadcastGameInfo(game)
+		game.clearLastBoardUpdate()
+	}
 }
 
 // gameWsSendButtonInfo sends the client a message of type "button_update"
@@ -816,6 +1322,9 @@ func gameWsBroadcastButtonInfo(game *Game, update *MessagePayloadButtonAction) {
 }
 
 func gameWsHandleButtonAction(conn *websocket.Conn, game *Game, whoami Player, msg *Message) {
+	if game.simultaneous {
+		return // moves stay secret until the round is resolved
+	}
 	var payload MessagePayloadButtonAction
 	err := json.Unmarshal(msg.Payload, &payload)
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -60,10 +61,10 @@ func TestParsePiecesArg(t *testing.T) {
 		{"mask": 17039360, "weight": 5   }
 	]}`
 	expected := []Piece{
-		{PieceMask(33325056).generateRotations(), 100},
-		{PieceMask(13369344).generateRotations(), 12.5},
-		{PieceMask(21254144).generateRotations(), 1},
-		{PieceMask(17039360).generateRotations(), 5},
+		{resizePieceMask(33325056, legacyPieceMaskLength).generateRotations(), 100},
+		{resizePieceMask(13369344, legacyPieceMaskLength).generateRotations(), 12.5},
+		{resizePieceMask(21254144, legacyPieceMaskLength).generateRotations(), 1},
+		{resizePieceMask(17039360, legacyPieceMaskLength).generateRotations(), 5},
 	}
 	res, err = parsePiecesArg(url.QueryEscape(arg))
 	if err != nil {
@@ -72,6 +73,76 @@ func TestParsePiecesArg(t *testing.T) {
 	if !slices.Equal(res, expected) {
 		t.Errorf("Unexpected result. Got %v. Expected: %v.", res, expected)
 	}
+
+	// pieces without a mask_length are 5x5, like the ones saved by older clients
+	if res[0].Masks[0] != 0b1111100_1100100_0000000_0000000_0000000_0000000_0000000 {
+		t.Errorf("Expected a 5x5 mask to be converted. Got %v", res[0].Masks[0])
+	}
+
+	// larger pieces need a mask_length
+	bigPiece := PieceMask(0b1111111_0000001_0000000_0000000_0000000_0000000_0000000)
+	arg = fmt.Sprintf(`{"mask_length": %d, "data": [{"mask": %d, "weight": 1}]}`, pieceMaskMaxLength, bigPiece)
+	res, err = parsePiecesArg(url.QueryEscape(arg))
+	if err != nil {
+		t.Error("Unexpected error parsing a large piece", err)
+	}
+	if len(res) != 1 || res[0].Masks[0] != bigPiece {
+		t.Errorf("Expected the large piece to be kept. Got %v", res)
+	}
+
+	arg = fmt.Sprintf(`{"mask_length": %d, "data": [{"mask": 1, "weight": 1}]}`, pieceMaskMaxLength+1)
+	if _, err = parsePiecesArg(url.QueryEscape(arg)); err == nil {
+		t.Error("Expected an error for a mask_length that is too large")
+	}
+}
+
+func TestParseBitesArg(t *testing.T) {
+	var arg string
+	var res []Bite
+	var err error
+
+	if !testing.Verbose() {
+		serverLogFile := server_flags.Logfile{Logger: &serverlog}
+		serverLogFile.Set(os.DevNull)
+	}
+
+	line := PieceMask(0b0000000_0000000_0000000_0000000_0000111_0000000_0000000)
+	plus := PieceMask(0b0100000_1110000_0100000_0000000_0000000_0000000_0000000)
+
+	// masks are moved to the top left, an omitted cost is the default cost, a cost of 0 is
+	// free, and invalid or duplicate bites are ignored
+	arg = fmt.Sprintf(`{"mask_length": %d, "data": [
+		{"mask": %d, "cost": 2 },
+		{"mask": %d },
+		{"mask": %d, "cost": 5 },
+		{"mask": 0,  "cost": 1 },
+		{"mask": %d, "cost": -1},
+		{"mask": %d, "cost": 0 }
+	]}`, pieceMaskMaxLength, line, plus, line.shiftUp(), biteSmall, biteSmall)
+	expected := []Bite{
+		{line.shiftUp(), 2},
+		{plus, plus.CalcBiteCost()},
+		{biteSmall, 0},
+	}
+	res, err = parseBitesArg(url.QueryEscape(arg))
+	if err != nil {
+		t.Error("Unexpected error parsing bites", err)
+	}
+	if !slices.Equal(res, expected) {
+		t.Errorf("Unexpected result. Got %v. Expected: %v.", res, expected)
+	}
+
+	// too many bites, using lines of different lengths
+	var data []string
+	bar := PieceMask(0)
+	for r := 0; r < pieceMaskMaxLength; r++ {
+		bar |= maskAt(r, 0)
+		data = append(data, fmt.Sprintf(`{"mask": %d, "cost": 1}`, bar), fmt.Sprintf(`{"mask": %d, "cost": 1}`, bar.rotate90()))
+	}
+	arg = fmt.Sprintf(`{"mask_length": %d, "data": [%s]}`, pieceMaskMaxLength, strings.Join(data, ","))
+	if _, err = parseBitesArg(url.QueryEscape(arg)); err == nil {
+		t.Error("Expected an error for too many bites")
+	}
 }
 
 func TestGameWsHandler_PlayerInfo(t *testing.T) {
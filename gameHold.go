@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+)
+
+const gbDefaultAllowHold = false
+
+// isEmpty returns true for the zero Piece, e.g. when a player isn't holding a piece
+func (p Piece) isEmpty() bool {
+	return p == Piece{}
+}
+
+// canHold returns true if playerIndex may still swap their piece with the held piece this turn
+func (game *Game) canHold(playerIndex int) bool {
+	return game.allowHold && playerIndex >= 0 && playerIndex < game.playerCount && !game.holdUsed[playerIndex]
+}
+
+// heldPieceHas returns true if playerIndex can place mask by swapping in their held piece
+func (game *Game) heldPieceHas(playerIndex int, mask PieceMask) bool {
+	return game.canHold(playerIndex) && !game.heldPieces[playerIndex].isEmpty() && game.heldPieces[playerIndex].has(mask)
+}
+
+// swapHeldPiece sets the piece of playerIndex aside and gives them the piece they were
+// holding. If they weren't holding a piece, they are dealt the next piece instead. With
+// simultaneous turns, every player is dealt the same pieces, so they get a random piece
+// like a reroll and the queue is left alone.
+func (game *Game) swapHeldPiece(playerIndex int) {
+	held := game.heldPieces[playerIndex]
+	game.heldPieces[playerIndex] = game.pieceOf(playerIndex)
+	if held.isEmpty() && game.simultaneous {
+		held = getWeightedRandomPiece(game.pieces, game.rng)
+	} else if held.isEmpty() {
+		held = game.dealPiece()
+	}
+	game.setPieceOf(playerIndex, held)
+	game.holdUsed[playerIndex] = true
+}
+
+// resetHeldPieces empties every player's hold
+func (game *Game) resetHeldPieces() {
+	game.heldPieces = [maxPlayers]Piece{}
+	game.holdUsed = [maxPlayers]bool{}
+}
+
+// holdPiece swaps the piece of whoami with their held piece. This can be done once per turn
+// and does not end the turn.
+func (game *Game) holdPiece(whoami Player) error {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	isPlayersTurn, owner := game.getTurnInfo(whoami)
+	if !isPlayersTurn {
+		return errors.New("Invalid update: not player's turn")
+	}
+	if !game.allowHold {
+		return errors.New("Invalid update: holding pieces is not allowed in this game")
+	}
+	playerIndex := int(owner) - 1
+	if !game.canHold(playerIndex) {
+		return errors.New("Invalid update: already held a piece this turn")
+	}
+	before := game.playerResources(playerIndex)
+
+	game.swapHeldPiece(playerIndex)
+	// the last move can't be undone once the next player acts
+	game.clearUndo()
+	game.addEvent(GameEvent{
+		Type:   gameEventHoldPiece,
+		Player: playerIndex,
+		Mask:   game.pieceOf(playerIndex).Masks[0],
+		Index:  -1,
+		Before: before,
+		After:  game.playerResources(playerIndex),
+	})
+	if game.simultaneous {
+		game.submitForcedSkips()
+		game.resolveRoundIfReady()
+	} else {
+		game.skipStuckPlayers()
+	}
+	return nil
+}
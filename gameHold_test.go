@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestHoldPiece(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	dot := Piece{PieceMask(0b1000000).generateRotations(), 1}
+	bar := Piece{PieceMask(0b1000000_1000000).generateRotations(), 1}
+
+	lobbyName := "TestHoldPiece"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	// holding is off by default
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if err = game.holdPiece(p1); err == nil {
+		t.Error("Expected holdPiece to fail when holding isn't allowed")
+	}
+
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"size":               boardSize,
+		"pieces":             []Piece{dot, bar},
+		"piece_queue_length": 1,
+		"allow_hold":         true,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(boardSize, boardSize)
+	game.board[0][0] = 1 | CellFlagHome
+	game.board[9][9] = 2 | CellFlagHome
+	game.updateScores()
+	game.turn = 0
+	game.nextPiece = dot
+	game.pieceQueue = []Piece{bar}
+
+	// with nothing held, holding deals the next piece from the queue
+	if err = game.holdPiece(p2); err == nil {
+		t.Error("Expected holdPiece to fail on another player's turn")
+	}
+	if err = game.holdPiece(p1); err != nil {
+		t.Fatalf("holdPiece failed: %v", err)
+	}
+	lastEvent := game.history[len(game.history)-1]
+	if game.heldPieces[0] != dot || game.nextPiece != bar || game.turn != 0 || lastEvent.Type != gameEventHoldPiece {
+		t.Errorf("Expected Player 1 to hold the dot and get the bar. held=%v next=%v turn=%d", game.heldPieces[0], game.nextPiece, game.turn)
+	}
+
+	// only once per turn
+	if err = game.holdPiece(p1); err == nil {
+		t.Error("Expected a second hold in the same turn to fail")
+	}
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), dot.Masks[0]); err == nil {
+		t.Error("Expected placing the held piece to fail after holding this turn")
+	}
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), bar.Masks[0]); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if err = game.skipTurn(p2); err != nil {
+		t.Fatalf("skipTurn failed: %v", err)
+	}
+
+	// on a later turn, the held piece can be placed directly
+	game.nextPiece = bar
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 2), dot.Masks[0]); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.board[0][2] != 1 || game.heldPieces[0] != bar {
+		t.Errorf("Expected the dot on the board and the bar held. held=%v\n%s", game.heldPieces[0], game.board.String2D())
+	}
+
+	// undoing the move gives the dot back
+	if err = game.requestUndo(p1); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	if err = game.respondUndo(p2, true); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+	if game.heldPieces[0] != dot || game.nextPiece != bar || !game.canHold(0) {
+		t.Errorf("Expected the undo to restore the held piece. held=%v next=%v", game.heldPieces[0], game.nextPiece)
+	}
+
+	game.resetGame()
+	if !game.heldPieces[0].isEmpty() || !game.canHold(0) {
+		t.Errorf("Expected no held pieces after a reset. Got %v", game.heldPieces[0])
+	}
+}
+
+func TestHoldPieceSimultaneous(t *testing.T) {
+	var err error
+
+	dot := Piece{PieceMask(0b1000000).generateRotations(), 1}
+	bar := Piece{PieceMask(0b1000000_1000000).generateRotations(), 1}
+	corner := Piece{PieceMask(0b1000000_1100000).generateRotations(), 1}
+
+	lobbyName := "TestHoldPieceSimultaneous"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":               10,
+		"pieces":             []Piece{dot, bar, corner},
+		"piece_queue_length": 2,
+		"simultaneous":       true,
+		"allow_hold":         true,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.playerPieces[0], game.playerPieces[1] = dot, dot
+	game.nextPiece = dot
+	game.pieceQueue = []Piece{bar, corner}
+
+	// holding doesn't change the piece queue or the other player's piece
+	if err = game.holdPiece(p1); err != nil {
+		t.Fatalf("holdPiece failed: %v", err)
+	}
+	if game.heldPieces[0] != dot || game.pieceOf(0).isEmpty() {
+		t.Errorf("Expected Player 1 to hold the dot and get a new piece. held=%v piece=%v", game.heldPieces[0], game.pieceOf(0))
+	}
+	if game.pieceOf(1) != dot || game.nextPiece != dot || len(game.pieceQueue) != 2 ||
+		game.pieceQueue[0] != bar || game.pieceQueue[1] != corner {
+		t.Errorf("Expected the other pieces to be unchanged. piece=%v next=%v queue=%v", game.pieceOf(1), game.nextPiece, game.pieceQueue)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maps loaded with loadGameMaps, by name
+var gameMaps = map[string]*GameMap{}
+
+// GameMap is a predefined board layout, loaded from a JSON file such as:
+//
+//	{
+//	  "name": "Crossroads",
+//	  "description": "Four corners joined by narrow paths",
+//	  "players": [2, 4],
+//	  "grid": [
+//	    ".B . . . . . . . . . .B",
+//	    ". 1H . . # . . # . . 3H .",
+//	    ...
+//	  ]
+//	}
+//
+// Each grid row lists its cells separated by spaces, written the same way as
+// GameBoard.String2D: "." is an empty cell, "#" is a wall and a digit is a cell
+// owned by that seat. A cell may be followed by "H" for a home cell, "B" for a
+// bonus bite cell and "R" for a bonus reroll cell.
+// Seats are handed out in order, so a 2 player game uses seats 1 and 2. Cells of
+// seats without a player are left empty.
+type GameMap struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Players     []int    `json:"players"` // player counts the map supports
+	Grid        []string `json:"grid"`
+
+	board GameBoard // parsed from Grid
+	seats int       // number of seats with a home cell
+}
+
+// parseMapCell converts a single cell of a map grid to a Cell
+func parseMapCell(token string) (Cell, error) {
+	var cell Cell
+	switch {
+	case token == "#":
+		return CellFlagWall, nil
+	case token[0] == '.':
+	case token[0] >= '1' && int(token[0]-'0') <= maxPlayers:
+		cell = Cell(token[0] - '0')
+	default:
+		return 0, fmt.Errorf("Invalid map cell %q", token)
+	}
+
+	for _, flag := range token[1:] {
+		var f Cell
+		switch flag {
+		case 'H':
+			f = CellFlagHome
+		case 'B':
+			f = CellFlagBonusBite
+		case 'R':
+			f = CellFlagBonusReroll
+		case 'S':
+			f = CellFlagShield
+		case 'M':
+			f = CellFlagMultiplier
+		case 'P':
+			f = CellFlagSpore
+		default:
+			return 0, fmt.Errorf("Invalid map cell %q", token)
+		}
+		if cell&f != 0 {
+			return 0, fmt.Errorf("Invalid map cell %q", token)
+		}
+		cell |= f
+	}
+	if cell&CellFlagHome != 0 && cell&CellMaskPlayer == 0 {
+		return 0, fmt.Errorf("Invalid map cell %q: home cells need an owner", token)
+	}
+	return cell, nil
+}
+
+// parse builds m.board from m.Grid and validates the map
+func (m *GameMap) parse() error {
+	if m.Name == "" {
+		return errors.New("Map has no name")
+	}
+	if len(m.Grid) <= gbMinSize || len(m.Grid) > gbMaxSize {
+		return fmt.Errorf("Map %s has %d rows. Expected more than %d and at most %d", m.Name, len(m.Grid), gbMinSize, gbMaxSize)
+	}
+
+	board := make(GameBoard, len(m.Grid))
+	var homes [maxPlayers + 1]bool
+	owners := map[Cell]bool{}
+	for r, row := range m.Grid {
+		tokens := strings.Fields(row)
+		if r > 0 && len(tokens) != len(board[0]) {
+			return fmt.Errorf("Map %s row %d has %d cells. Expected %d", m.Name, r, len(tokens), len(board[0]))
+		}
+		if len(tokens) <= gbMinSize || len(tokens) > gbMaxSize {
+			return fmt.Errorf("Map %s has %d columns. Expected more than %d and at most %d", m.Name, len(tokens), gbMinSize, gbMaxSize)
+		}
+		board[r] = make([]Cell, len(tokens))
+		for c, token := range tokens {
+			cell, err := parseMapCell(token)
+			if err != nil {
+				return fmt.Errorf("Map %s row %d column %d: %v", m.Name, r, c, err)
+			}
+			owner := cell & CellMaskPlayer
+			if cell&CellFlagHome != 0 {
+				homes[owner] = true
+			} else if owner != 0 {
+				owners[owner] = true
+			}
+			board[r][c] = cell
+		}
+	}
+
+	// seats must be numbered without gaps
+	seats := 0
+	for seat := 1; seat <= maxPlayers && homes[seat]; seat++ {
+		seats = seat
+	}
+	for seat := seats + 1; seat <= maxPlayers; seat++ {
+		if homes[seat] || owners[Cell(seat)] {
+			return fmt.Errorf("Map %s has cells for seat %d, but seat %d has no home cell", m.Name, seat, seats+1)
+		}
+	}
+
+	if len(m.Players) == 0 {
+		return fmt.Errorf("Map %s does not list the player counts it supports", m.Name)
+	}
+	for _, n := range m.Players {
+		if n < 2 || n > seats {
+			return fmt.Errorf("Map %s can't support %d players with %d seats", m.Name, n, seats)
+		}
+	}
+
+	m.board = board
+	m.seats = seats
+	return nil
+}
+
+// newBoard returns a board for a game with playerCount players. If randomize is
+// set, players are assigned to the seats in a random order.
+func (m *GameMap) newBoard(playerCount int, randomize bool, rng *rand.Rand) GameBoard {
+	// seatOwner[seat] is the player that gets seat
+	seatOwner := make([]Cell, m.seats+1)
+	for seat := 1; seat <= playerCount; seat++ {
+		seatOwner[seat] = Cell(seat)
+	}
+	if randomize {
+		rng.Shuffle(playerCount, func(i, j int) {
+			seatOwner[i+1], seatOwner[j+1] = seatOwner[j+1], seatOwner[i+1]
+		})
+	}
+
+	board := m.board.clone()
+	for r := range board {
+		for c := range board[r] {
+			seat := board[r][c] & CellMaskPlayer
+			if seat == 0 {
+				continue
+			}
+			if int(seat) > playerCount {
+				// nobody is in this seat
+				board[r][c] &= CellMaskFlags &^ CellFlagHome
+				continue
+			}
+			board[r][c] = board[r][c]&CellMaskFlags | seatOwner[seat]
+		}
+	}
+	return board
+}
+
+// supports returns true if the map can be played by playerCount players
+func (m *GameMap) supports(playerCount int) bool {
+	return slices.Contains(m.Players, playerCount)
+}
+
+// readGameMap reads and parses the map file name. The map is named after the file
+// if it doesn't set a name.
+func readGameMap(name string) (*GameMap, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var m GameMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if m.Name == "" {
+		m.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	if err := m.parse(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &m, nil
+}
+
+// loadGameMaps loads every *.json map file in dir into gameMaps.
+// Returns the names of the loaded maps.
+func loadGameMaps(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		m, err := readGameMap(f)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := gameMaps[m.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate map name %s", f, m.Name)
+		}
+		gameMaps[m.Name] = m
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
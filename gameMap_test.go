@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testMapGrid is a 6x8 map with 3 seats
+var testMapGrid = []string{
+	".B . . # . . . .",
+	". 1H 1 # . . 3H .",
+	". . . . .R . . .",
+	". . . . . . . .",
+	". . . # . . 2H .",
+	". . . # . . . .B",
+}
+
+func TestParseGameMap(t *testing.T) {
+	m := GameMap{Name: "test", Players: []int{2, 3}, Grid: testMapGrid}
+	if err := m.parse(); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if m.seats != 3 || len(m.board) != 6 || len(m.board[0]) != 8 {
+		t.Errorf("Expected a 6x8 map with 3 seats. Got %dx%d with %d seats", len(m.board), len(m.board[0]), m.seats)
+	}
+	for _, tc := range []struct {
+		row, col int
+		cell     Cell
+	}{
+		{0, 0, CellFlagBonusBite},
+		{0, 3, CellFlagWall},
+		{1, 1, 1 | CellFlagHome},
+		{1, 2, 1},
+		{2, 4, CellFlagBonusReroll},
+		{4, 6, 2 | CellFlagHome},
+		{5, 7, CellFlagBonusBite},
+	} {
+		if m.board[tc.row][tc.col] != tc.cell {
+			t.Errorf("Expected board[%d][%d] to be 0x%04x. Got 0x%04x", tc.row, tc.col, tc.cell, m.board[tc.row][tc.col])
+		}
+	}
+
+	// invalid maps
+	for _, bad := range []GameMap{
+		{Name: "", Players: []int{2}, Grid: testMapGrid},
+		{Name: "no players", Grid: testMapGrid},
+		{Name: "too many players", Players: []int{4}, Grid: testMapGrid},
+		{Name: "too few rows", Players: []int{2}, Grid: testMapGrid[:gbMinSize]},
+		{Name: "ragged", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". .")},
+		{Name: "bad cell", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". . . x . . . .")},
+		{Name: "bad flag", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". . . .Q . . . .")},
+		{Name: "duplicate flag", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". . . .BB . . . .")},
+		{Name: "ownerless home", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". . . .H . . . .")},
+		{Name: "seat gap", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". . . . . 5H . .")},
+		{Name: "seat without home", Players: []int{2}, Grid: append(slices.Clone(testMapGrid), ". . . . . 4 . .")},
+	} {
+		if err := bad.parse(); err == nil {
+			t.Errorf("Expected parse to fail for map %q", bad.Name)
+		}
+	}
+}
+
+func TestMapNewBoard(t *testing.T) {
+	m := GameMap{Name: "test", Players: []int{2, 3}, Grid: testMapGrid}
+	if err := m.parse(); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	// seat 3 has no player in a 2 player game
+	board := m.newBoard(2, false, nil)
+	if board[1][6] != 0 {
+		t.Errorf("Expected the unused seat to be empty. Got 0x%04x\n%s", board[1][6], board.String2D())
+	}
+	if board[1][1] != 1|CellFlagHome || board[1][2] != 1 || board[4][6] != 2|CellFlagHome {
+		t.Errorf("Expected seats 1 and 2 to be kept in order\n%s", board.String2D())
+	}
+	if m.board[1][6] != 3|CellFlagHome {
+		t.Errorf("newBoard must not modify the map")
+	}
+
+	// every player gets exactly one seat when randomized
+	_, rng := newGameRand(1)
+	board = m.newBoard(3, true, rng)
+	var homes []Cell
+	for _, pos := range [][2]int{{1, 1}, {4, 6}, {1, 6}} {
+		cell := board[pos[0]][pos[1]]
+		if cell&CellFlagHome == 0 {
+			t.Errorf("Expected a home cell at %v\n%s", pos, board.String2D())
+		}
+		homes = append(homes, cell&CellMaskPlayer)
+	}
+	slices.Sort(homes)
+	if !slices.Equal(homes, []Cell{1, 2, 3}) {
+		t.Errorf("Expected one home for each player. Got %v\n%s", homes, board.String2D())
+	}
+	if board[1][2] != board[1][1]&CellMaskPlayer {
+		t.Errorf("Expected the cell next to seat 1's home to move with it\n%s", board.String2D())
+	}
+}
+
+func TestCreateGameWithMap(t *testing.T) {
+	dir := t.TempDir()
+	for name, m := range map[string]GameMap{
+		"TestCreateGameWithMap.json":  {Players: []int{2, 3}, Grid: testMapGrid},
+		"TestCreateGameWithMap2.json": {Name: "TestCreateGameWithMap", Players: []int{2}, Grid: testMapGrid},
+	} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("json.Marshal failed: %v", err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if _, err := loadGameMaps(dir); err == nil {
+		t.Error("Expected loadGameMaps to fail on duplicate map names")
+	}
+	delete(gameMaps, "TestCreateGameWithMap")
+	os.Remove(filepath.Join(dir, "TestCreateGameWithMap2.json"))
+	names, err := loadGameMaps(dir)
+	if err != nil || !slices.Equal(names, []string{"TestCreateGameWithMap"}) {
+		t.Fatalf("Expected the map to be named after its file. Got %v, %v", names, err)
+	}
+
+	lobbyName := "TestCreateGameWithMap"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	if _, err = createGame(activeLobbies[lobbyName], map[string]any{"map": "TestCreateGameWithMapMissing"}); err == nil {
+		t.Error("Expected createGame to fail for an unknown map")
+	}
+
+	// the map's dimensions win over the size options
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"map":                       "TestCreateGameWithMap",
+		"size":                      20,
+		"randomize_start_positions": false,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game.rowCount != 6 || game.colCount != 8 {
+		t.Errorf("Expected a 6x8 board. Got %dx%d", game.rowCount, game.colCount)
+	}
+	if game.board[0][3] != CellFlagWall || game.board[1][1] != 1|CellFlagHome || game.board[1][6] != 0 {
+		t.Errorf("Expected the board from the map. Got\n%s", game.board.String2D())
+	}
+	if game.scores[0] != 2 || game.scores[1] != 1 {
+		t.Errorf("Expected scores from the map. Got %v", game.scores)
+	}
+
+	// the map survives a reset and a save
+	game.resetGame()
+	if game.board[0][3] != CellFlagWall || game.rowCount != 6 {
+		t.Errorf("Expected the map after a reset. Got\n%s", game.board.String2D())
+	}
+	s, err := game.toState()
+	if err != nil {
+		t.Fatalf("toState failed: %v", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var loaded gameState
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	restored, err := gameFromState(loaded)
+	if err != nil {
+		t.Fatalf("gameFromState failed: %v", err)
+	}
+	if restored.gameMap == nil || restored.gameMap.seats != 3 {
+		t.Errorf("Expected the map to be restored. Got %+v", restored.gameMap)
+	}
+
+	// the map doesn't support 4 players
+	_ = joinLobbyWrapper(t, lobbyName, "p3", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p4", "")
+	if _, err = createGame(activeLobbies[lobbyName], map[string]any{"map": "TestCreateGameWithMap"}); err == nil {
+		t.Error("Expected createGame to fail for an unsupported player count")
+	}
+}
+
+func TestExampleMaps(t *testing.T) {
+	files, err := filepath.Glob("maps/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Expected example maps. Got %v, %v", files, err)
+	}
+	for _, f := range files {
+		if _, err := readGameMap(f); err != nil {
+			t.Errorf("readGameMap failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// How the next piece is chosen
+const (
+	gamePiecesRandom = iota // every piece is drawn independently, weighted by Piece.Weight
+	gamePiecesBag           // pieces are dealt from a shuffled bag holding each piece in proportion to its Weight
+	gamePiecesMax           // For input validation. Not a piece distribution.
+)
+
+const gbDefaultPieceDistribution = gamePiecesRandom
+const gbDefaultPieceQueueLength = 3 // upcoming pieces shown to the players
+const gbMaxPieceQueueLength = 6
+const gbMaxBagSize = 200
+
+// validatePieceOptions checks the piece_distribution and piece_queue_length options passed to createGame
+func validatePieceOptions(distribution, queueLength int) error {
+	if distribution < 0 || distribution >= gamePiecesMax {
+		return errors.New("Invalid piece_distribution parameter")
+	}
+	if queueLength < 0 || queueLength > gbMaxPieceQueueLength {
+		return errors.New("Invalid piece_queue_length parameter")
+	}
+	return nil
+}
+
+// fillBag refills game.pieceBag with every piece in proportion to its Weight, in random order.
+// The rarest piece gets one copy, unless that would make the bag larger than gbMaxBagSize.
+// Every piece with a positive Weight gets at least one copy.
+func (game *Game) fillBag() {
+	var minWeight, totalWeight float64
+	for _, p := range game.pieces {
+		if p.Weight <= 0 {
+			continue
+		}
+		if minWeight == 0 || p.Weight < minWeight {
+			minWeight = p.Weight
+		}
+		totalWeight += p.Weight
+	}
+	if totalWeight <= 0 {
+		return
+	}
+	scale := 1 / minWeight
+	if totalWeight*scale > gbMaxBagSize {
+		scale = gbMaxBagSize / totalWeight
+	}
+
+	game.pieceBag = game.pieceBag[:0]
+	for _, p := range game.pieces {
+		if p.Weight <= 0 {
+			continue
+		}
+		for n := max(int(math.Round(p.Weight*scale)), 1); n > 0; n-- {
+			game.pieceBag = append(game.pieceBag, p)
+		}
+	}
+	game.rng.Shuffle(len(game.pieceBag), func(i, j int) {
+		game.pieceBag[i], game.pieceBag[j] = game.pieceBag[j], game.pieceBag[i]
+	})
+}
+
+// drawPiece returns a new piece according to game.pieceDistribution
+func (game *Game) drawPiece() Piece {
+	if game.pieceDistribution == gamePiecesBag {
+		if len(game.pieceBag) == 0 {
+			game.fillBag()
+		}
+		if len(game.pieceBag) > 0 {
+			piece := game.pieceBag[0]
+			game.pieceBag = slices.Delete(game.pieceBag, 0, 1)
+			return piece
+		}
+	}
+	return getWeightedRandomPiece(game.pieces, game.rng)
+}
+
+// dealPiece returns the piece at the front of the queue and tops the queue up to
+// game.pieceQueueLength upcoming pieces
+func (game *Game) dealPiece() Piece {
+	for len(game.pieceQueue) < game.pieceQueueLength+1 {
+		game.pieceQueue = append(game.pieceQueue, game.drawPiece())
+	}
+	piece := game.pieceQueue[0]
+	game.pieceQueue = slices.Delete(game.pieceQueue, 0, 1)
+	return piece
+}
+
+// upcomingPieces returns the pieces that will be dealt after game.nextPiece, in order.
+// Returns nil once the game is over.
+func (game *Game) upcomingPieces() []Piece {
+	if game.isOver {
+		return nil
+	}
+	return slices.Clone(game.pieceQueue)
+}
+
+// resetPieceQueue empties the queue and the bag so that the next piece starts a new sequence
+func (game *Game) resetPieceQueue() {
+	game.pieceQueue = nil
+	game.pieceBag = nil
+}
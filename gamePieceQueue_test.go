@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPieceBag(t *testing.T) {
+	var err error
+
+	lobbyName := "TestPieceBag"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, opts := range []map[string]any{
+		{"piece_distribution": gamePiecesMax},
+		{"piece_queue_length": -1},
+		{"piece_queue_length": gbMaxPieceQueueLength + 1},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	// every bag holds two squares for each line
+	square := Piece{PieceMask(0b1100000_1100000).generateRotations(), 50}
+	line := Piece{PieceMask(0b1000000_1000000).generateRotations(), 25}
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"pieces":             []Piece{square, line},
+		"piece_distribution": gamePiecesBag,
+		"piece_queue_length": 0,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	for bag := 0; bag < 4; bag++ {
+		squares, lines := 0, 0
+		for i := 0; i < 3; i++ {
+			switch game.nextPiece {
+			case square:
+				squares++
+			case line:
+				lines++
+			}
+			game.setNextPiece()
+		}
+		if squares != 2 || lines != 1 {
+			t.Errorf("Expected 2 squares and 1 line in bag %d. Got %d and %d", bag+1, squares, lines)
+		}
+	}
+
+	// a piece that is much rarer than the others still gets into the bag
+	rare := Piece{PieceMask(0b1000000).generateRotations(), 0.1}
+	game.pieces = []Piece{square, line, rare}
+	game.pieceBag = nil
+	game.fillBag()
+	if len(game.pieceBag) > gbMaxBagSize+len(game.pieces) || !slices.Contains(game.pieceBag, rare) {
+		t.Errorf("Expected a bag of at most %d pieces with the rare piece. Got %d pieces", gbMaxBagSize, len(game.pieceBag))
+	}
+}
+
+func TestPieceQueue(t *testing.T) {
+	var err error
+
+	lobbyName := "TestPieceQueue"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"piece_queue_length": 3})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	upcoming := game.upcomingPieces()
+	if len(upcoming) != 3 {
+		t.Fatalf("Expected 3 upcoming pieces. Got %d", len(upcoming))
+	}
+
+	// the queue moves up by one each turn
+	nextPiece := game.nextPiece
+	if err = game.skipTurn(p1); err != nil {
+		t.Fatalf("skipTurn failed: %v", err)
+	}
+	if game.nextPiece != upcoming[0] || !slices.Equal(game.upcomingPieces()[:2], upcoming[1:]) {
+		t.Errorf("Expected the queue to move up. Had %v, got %v then %v", upcoming, game.nextPiece, game.upcomingPieces())
+	}
+
+	// undoing the turn puts the queue back
+	if err = game.requestUndo(p1); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	if err = game.respondUndo(p2, true); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+	if game.nextPiece != nextPiece || !slices.Equal(game.upcomingPieces(), upcoming) {
+		t.Errorf("Expected the undo to restore the queue. Had %v, got %v", upcoming, game.upcomingPieces())
+	}
+
+	// the queue is hidden once the game is over
+	if err = game.forfeitGame(p1); err != nil {
+		t.Fatalf("forfeitGame failed: %v", err)
+	}
+	if game.upcomingPieces() != nil {
+		t.Errorf("Expected no upcoming pieces after the game is over. Got %v", game.upcomingPieces())
+	}
+}
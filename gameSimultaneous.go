@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// RoundMove is a move submitted during a round of simultaneous turns. Moves are
+// kept secret until every player still in the game has submitted one.
+type RoundMove struct {
+	Action  string    `json:"action"` // gameEventPlacePiece, gameEventPlaceBite or gameEventSkipTurn
+	Index   int       `json:"index"`
+	Mask    PieceMask `json:"mask"`
+	Forced  bool      `json:"forced,omitempty"`  // skipped by the server because the player couldn't move
+	Timeout bool      `json:"timeout,omitempty"` // skipped because the round ran out of time
+	Held    bool      `json:"held,omitempty"`    // the held piece is placed and the player's piece is held instead
+}
+
+// validateSimultaneous checks that the timer mode can be used with simultaneous turns
+// and returns the timer mode to use. Rounds always have a time limit.
+func validateSimultaneous(simultaneous bool, timerMode int) (int, error) {
+	if !simultaneous {
+		return timerMode, nil
+	}
+	switch timerMode {
+	case gameTimerNone:
+		return gameTimerPerTurn, nil
+	case gameTimerClock:
+		return timerMode, errors.New("Simultaneous turns can't be played with a chess clock")
+	}
+	return timerMode, nil
+}
+
+// pieceOf returns the piece playerIndex has to place. With simultaneous turns every player
+// starts the round with the same piece, but rerolls only change their own.
+func (game *Game) pieceOf(playerIndex int) Piece {
+	if game.simultaneous && playerIndex >= 0 && playerIndex < game.playerCount {
+		return game.playerPieces[playerIndex]
+	}
+	return game.nextPiece
+}
+
+// setPieceOf changes the piece playerIndex has to place
+func (game *Game) setPieceOf(playerIndex int, piece Piece) {
+	if game.simultaneous {
+		game.playerPieces[playerIndex] = piece
+	} else {
+		game.nextPiece = piece
+	}
+}
+
+// canSubmit returns true if playerIndex still has to submit a move this round
+func (game *Game) canSubmit(playerIndex int) bool {
+	return game.simultaneous && !game.isOver &&
+		playerIndex >= 0 && playerIndex < game.playerCount &&
+		game.scores[playerIndex] != 0 && game.roundMoves[playerIndex] == nil
+}
+
+// submitted returns which players have submitted a move this round, nil without
+// simultaneous turns
+func (game *Game) submitted() []bool {
+	if !game.simultaneous {
+		return nil
+	}
+	submitted := make([]bool, game.playerCount)
+	for i := range submitted {
+		submitted[i] = game.roundMoves[i] != nil
+	}
+	return submitted
+}
+
+// isRoundComplete returns true if every player still in the game has submitted a move
+func (game *Game) isRoundComplete() bool {
+	if game.isOver {
+		return false
+	}
+	for i := 0; i < game.playerCount; i++ {
+		if game.scores[i] != 0 && game.roundMoves[i] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// roundOrder returns every player, starting with game.turn. Conflicts between moves are
+// resolved in this order. game.turn moves on after every round, so no player always
+// goes first.
+func (game *Game) roundOrder() []int {
+	order := make([]int, 0, game.playerCount)
+	for i := 0; i < game.playerCount; i++ {
+		order = append(order, (max(game.turn, 0)+i)%game.playerCount)
+	}
+	return order
+}
+
+// startRound deals game.nextPiece to every player and submits a skip for the players who
+// can't move. Ends the game if no one can move. The caller must hold game.mu.
+func (game *Game) startRound() {
+	game.roundMoves = [maxPlayers]*RoundMove{}
+	for i := 0; i < game.playerCount; i++ {
+		game.playerPieces[i] = game.nextPiece
+		game.playerDraftPieces[i] = slices.Clone(game.draftPieces)
+	}
+	game.submitForcedSkips()
+}
+
+// submitForcedSkips submits a skip for every player who hasn't moved yet and can't.
+// Ends the game if no one can move. The caller must hold game.mu.
+func (game *Game) submitForcedSkips() {
+	if game.isOver {
+		return
+	}
+	stuck := false
+	for i := 0; i < game.playerCount; i++ {
+		if game.canSubmit(i) && !game.canMove(i) {
+			game.roundMoves[i] = &RoundMove{Action: gameEventSkipTurn, Index: -1, Forced: true}
+			stuck = true
+		}
+	}
+	if stuck && game.isStalemate() {
+		game.roundMoves = [maxPlayers]*RoundMove{}
+		game.endStalemate()
+	}
+}
+
+// submitMove records the move of playerIndex for this round and resolves the round once
+// every player has moved. The move must already be validated. The caller must hold game.mu.
+func (game *Game) submitMove(playerIndex int, move RoundMove) {
+	game.roundMoves[playerIndex] = &move
+	game.resolveRoundIfReady()
+}
+
+// resolveRoundIfReady resolves the round if every player has submitted a move, along with
+// any following rounds where no one is able to move. The caller must hold game.mu.
+func (game *Game) resolveRoundIfReady() {
+	for i := 0; i < gbMaxForcedSkips && game.isRoundComplete(); i++ {
+		game.resolveRound()
+		if !game.isOver {
+			game.startRound()
+		}
+	}
+}
+
+// expireRound submits a skip for every player who ran out of time, or makes them forfeit,
+// depending on game.timeoutAction. The caller must hold game.mu.
+func (game *Game) expireRound() {
+	var late []int
+	for i := 0; i < game.playerCount; i++ {
+		if game.canSubmit(i) {
+			late = append(late, i)
+		}
+	}
+	for _, i := range late {
+		if game.isOver {
+			break
+		}
+		if game.timeoutAction == gameTimeoutForfeit {
+			game.forfeitPlayer(i, true)
+		} else {
+			game.roundMoves[i] = &RoundMove{Action: gameEventSkipTurn, Index: -1, Timeout: true}
+		}
+	}
+	game.resolveRoundIfReady()
+}
+
+// resolveRound applies every move submitted this round at once:
+//   - moves that are no longer legal, e.g. because a player forfeited, are dropped
+//   - placements that overlap each other are all dropped
+//   - bites are applied, then placements
+//   - cells that lost their path home are removed, captures are resolved in roundOrder,
+//     and cells that lost their path home are removed again
+//
+// Moves are added to the history in roundOrder, followed by a gameEventResolveRound event
+// with every captured and orphaned cell. The caller must hold game.mu.
+func (game *Game) resolveRound() {
+	order := game.roundOrder()
+	moves := game.roundMoves
+	var before [maxPlayers]GameResources
+	var conflict [maxPlayers]bool
+	for _, i := range order {
+		before[i] = game.playerResources(i)
+		move := moves[i]
+		if move == nil {
+			continue
+		}
+		switch move.Action {
+		case gameEventPlacePiece:
+			conflict[i] = !game.isLegalPlacement(Cell(i+1), move.Index, move.Mask)
+		case gameEventPlaceBite:
+			cost, ok := game.biteCost(move.Mask)
+			conflict[i] = !ok || !game.isLegalBite(Cell(i+1), move.Index, move.Mask) || game.bites[i] < cost
+		}
+	}
+
+	// placements that claim the same cell bounce off each other
+	claims := map[int]int{}
+	for _, i := range order {
+		if moves[i] != nil && moves[i].Action == gameEventPlacePiece && !conflict[i] {
+			for _, index := range game.board.getPieceIndices(moves[i].Index, moves[i].Mask) {
+				claims[index]++
+			}
+		}
+	}
+	for _, i := range order {
+		if moves[i] != nil && moves[i].Action == gameEventPlacePiece && !conflict[i] {
+			for _, index := range game.board.getPieceIndices(moves[i].Index, moves[i].Mask) {
+				if claims[index] > 1 {
+					conflict[i] = true
+				}
+			}
+		}
+	}
+
+	// bites first, so that a bite can't remove a piece placed in the same round
+	var placed []int
+	for _, i := range order {
+		if moves[i] == nil || conflict[i] {
+			continue
+		}
+		switch moves[i].Action {
+		case gameEventPlaceBite:
+			cost, _ := game.biteCost(moves[i].Mask)
+			game.addPieceToBoard(0, moves[i].Index, moves[i].Mask)
+			game.bites[i] -= cost
+		case gameEventPlacePiece:
+			placed = append(placed, i)
+		}
+	}
+	for _, i := range placed {
+		game.addPieceToBoard(Cell(i+1), moves[i].Index, moves[i].Mask)
+		if moves[i].Held {
+			game.heldPieces[i] = game.pieceOf(i)
+		}
+	}
+
+	orphaned := game.handleOrphanedCells()
+	var captured []int
+	switch game.captureMode {
+	case gameModeCaptureFromPiece:
+		for _, i := range placed {
+			captured = append(captured, game.captureCellsFromPiece(Cell(i+1), moves[i].Index, moves[i].Mask)...)
+		}
+	case gameModeCaptureAnywhereCurrentPlayer:
+		for _, i := range placed {
+			captured = append(captured, game.captureCells(Cell(i+1))...)
+		}
+	case gameModeCaptureAnywhereAllPlayers:
+		for _, i := range order {
+			captured = append(captured, game.captureCells(Cell(i+1))...)
+		}
+	}
+	orphanedAfterCapture := game.handleOrphanedCells()
+	game.lastBoardUpdate = game.lastBoardUpdate[:0]
+	game.lastBoardUpdate = append(game.lastBoardUpdate, orphaned...)
+	game.lastBoardUpdate = append(game.lastBoardUpdate, captured...)
+	game.lastBoardUpdate = append(game.lastBoardUpdate, orphanedAfterCapture...)
+	orphaned = append(orphaned, orphanedAfterCapture...)
+
+	game.updateScores()
+	for _, i := range placed {
+		game.updateNewCellsForBites(i, game.scores[i]-before[i].Score)
+	}
+
+	for _, i := range order {
+		move := moves[i]
+		if move == nil {
+			continue
+		}
+		game.addEvent(GameEvent{
+			Type:     move.Action,
+			Player:   i,
+			Forced:   move.Forced,
+			Timeout:  move.Timeout,
+			Conflict: conflict[i],
+			Mask:     move.Mask,
+			Index:    move.Index,
+			Before:   before[i],
+			After:    game.playerResources(i),
+		})
+	}
+	game.addEvent(GameEvent{
+		Type:     gameEventResolveRound,
+		Player:   -1,
+		Index:    -1,
+		Captured: captured,
+		Orphaned: orphaned,
+	})
+
+	game.roundMoves = [maxPlayers]*RoundMove{}
+	game.clearUndo()
+	game.advanceTurn()
+	game.setNextPiece()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newSimultaneousTestGame returns a simultaneous game on an empty 10x10 board with
+// Player 1's home at (0,0) and Player 2's home at p2Row,p2Col. Both players place a
+// single cell this round.
+func newSimultaneousTestGame(t *testing.T, lobbyName string, p2Row, p2Col int, opts map[string]any) (*Game, Player, Player) {
+	var boardSize int = 10
+
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+	opts["size"] = boardSize
+	opts["simultaneous"] = true
+	opts["has_bonus_bite_cells"] = false
+	opts["bonus_reroll_cells"] = 0
+	game, err := createGame(activeLobbies[lobbyName], opts)
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(boardSize, boardSize)
+	game.board[0][0] = 1 | CellFlagHome
+	game.board[p2Row][p2Col] = 2 | CellFlagHome
+	game.updateScores()
+	game.turn = 0
+	game.nextPiece = Piece{maskAt(0, 0).generateRotations(), 1}
+	game.startRound()
+	return game, p1, p2
+}
+
+func TestSimultaneousOptions(t *testing.T) {
+	var err error
+
+	lobbyName := "TestSimultaneousOptions"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	if _, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"simultaneous": true,
+		"timer":        gameTimerClock,
+	}); err == nil {
+		t.Error("createGame should have failed with simultaneous turns and a chess clock")
+	}
+
+	// rounds always have a time limit
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"simultaneous": true})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game.timerMode != gameTimerPerTurn {
+		t.Errorf("Expected a time limit per turn with simultaneous turns. Got timer mode %d", game.timerMode)
+	}
+	if !game.canSubmit(0) || !game.canSubmit(1) {
+		t.Errorf("Expected both players to be able to move in the first round. Got %v", game.submitted())
+	}
+}
+
+func TestSimultaneousRound(t *testing.T) {
+	var err error
+
+	game, p1, p2 := newSimultaneousTestGame(t, "TestSimultaneousRound", 9, 9, map[string]any{})
+	historyLen := len(game.history)
+
+	// Player 1's move is kept secret until Player 2 moves
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), maskAt(0, 0)); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.board[0][1] != 0 || len(game.history) != historyLen {
+		t.Errorf("Expected the move to wait for the end of the round\n%s", game.board.String2D())
+	}
+	if submitted := game.submitted(); !submitted[0] || submitted[1] {
+		t.Errorf("Expected only Player 1 to have submitted a move. Got %v", submitted)
+	}
+	if err = game.placePiece(p1, game.board.getIndex1D(1, 0), maskAt(0, 0)); err == nil {
+		t.Error("Expected a second move in the same round to fail")
+	}
+
+	// Player 2 moves and the round is resolved
+	if err = game.placePiece(p2, game.board.getIndex1D(9, 8), maskAt(0, 0)); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.board[0][1] != 1 || game.board[9][8] != 2 {
+		t.Errorf("Expected both pieces on the board\n%s", game.board.String2D())
+	}
+	events := game.history[historyLen:]
+	if len(events) != 3 {
+		t.Fatalf("Expected 2 moves and the end of the round. Got %+v", events)
+	}
+	if events[0].Player != 0 || events[1].Player != 1 || events[0].Conflict || events[1].Conflict {
+		t.Errorf("Expected the moves in round order without conflicts. Got %+v", events)
+	}
+	if events[2].Type != gameEventResolveRound {
+		t.Errorf("Expected the round to be resolved. Got %+v", events[2])
+	}
+	if game.turn != 1 || game.submitted()[0] || game.submitted()[1] {
+		t.Errorf("Expected a new round led by Player 2. turn=%d submitted=%v", game.turn, game.submitted())
+	}
+}
+
+func TestSimultaneousConflict(t *testing.T) {
+	var err error
+
+	// both players reach for (0,1)
+	game, p1, p2 := newSimultaneousTestGame(t, "TestSimultaneousConflict", 0, 2, map[string]any{})
+	historyLen := len(game.history)
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), maskAt(0, 0)); err != nil {
+		t.Fatalf("placePiece failed: %v", err)
+	}
+	if err = game.placePiece(p2, game.board.getIndex1D(0, 1), maskAt(0, 0)); err != nil {
+		t.Fatalf("placePiece failed: %v", err)
+	}
+	if game.board[0][1] != 0 {
+		t.Errorf("Expected both pieces to bounce\n%s", game.board.String2D())
+	}
+	events := game.history[historyLen:]
+	if len(events) != 3 || !events[0].Conflict || !events[1].Conflict {
+		t.Errorf("Expected both moves to conflict. Got %+v", events)
+	}
+	if game.scores[0] != 1 || game.scores[1] != 1 {
+		t.Errorf("Expected the scores to be unchanged. Got %v", game.scores)
+	}
+}
+
+func TestSimultaneousTimeout(t *testing.T) {
+	var err error
+
+	game, p1, _ := newSimultaneousTestGame(t, "TestSimultaneousTimeout", 9, 9, map[string]any{"turn_time": 30})
+	if err = game.skipTurn(p1); err != nil {
+		t.Fatalf("skipTurn failed: %v", err)
+	}
+	if game.expireTurn(time.Now()) {
+		t.Error("Expected the round to not expire with time left")
+	}
+
+	// Player 2 runs out of time and the round is resolved without them
+	historyLen := len(game.history)
+	game.turnStarted = time.Now().Add(-31 * time.Second)
+	if !game.expireTurn(time.Now()) {
+		t.Fatal("Expected the round to expire")
+	}
+	events := game.history[historyLen:]
+	if len(events) != 3 || events[0].Type != gameEventSkipTurn || events[0].Timeout {
+		t.Fatalf("Expected Player 1 to skip on purpose. Got %+v", events)
+	}
+	if events[1].Type != gameEventSkipTurn || !events[1].Timeout || events[1].Player != 1 {
+		t.Errorf("Expected Player 2 to time out. Got %+v", events[1])
+	}
+	if left := game.timeLeft(0, time.Now()); left < 29*time.Second {
+		t.Errorf("Expected the timer to restart for the next round. Got %s", left)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+)
+
+// Special cells are placed like bonus cells, see gameBonus.go.
+// Shield cells can't be captured or bitten while they're owned, multiplier cells count
+// gbMultiplierFactor times in the score, and owned spore cells are extra home cells.
+const gbDefaultShieldCells = 0
+const gbDefaultMultiplierCells = 0
+const gbDefaultSporeCells = 0
+const gbMultiplierFactor = 2
+
+// validateSpecialCells checks the special cell options passed to createGame
+func validateSpecialCells(shieldCells, multiplierCells, sporeCells int) error {
+	if shieldCells < 0 || shieldCells > gbMaxBonusCells {
+		return errors.New("Invalid shield_cells parameter")
+	}
+	if multiplierCells < 0 || multiplierCells > gbMaxBonusCells {
+		return errors.New("Invalid multiplier_cells parameter")
+	}
+	if sporeCells < 0 || sporeCells > gbMaxBonusCells {
+		return errors.New("Invalid spore_cells parameter")
+	}
+	return nil
+}
+
+// setSpecialFlagPositions places shield, multiplier and spore cells on the board, the same
+// way as bonus reroll cells
+func setSpecialFlagPositions(board GameBoard, shieldCells, multiplierCells, sporeCells, placement, homeClearance int, rng *rand.Rand) {
+	symmetric := placement == gameBonusSymmetric
+	setBonusFlagPositions(board, CellFlagShield, shieldCells, symmetric, homeClearance, rng)
+	setBonusFlagPositions(board, CellFlagMultiplier, multiplierCells, symmetric, homeClearance, rng)
+	setBonusFlagPositions(board, CellFlagSpore, sporeCells, symmetric, homeClearance, rng)
+}
+
+// isShielded returns true if cell is a shield cell owned by a player
+func isShielded(cell Cell) bool {
+	return cell&CellFlagShield != 0 && cell&CellMaskPlayer != 0
+}
+
+// isHomeCell returns true if cell is a home cell or a spore cell. Cells with a path back to
+// one their owner owns are safe from being orphaned.
+func isHomeCell(cell Cell) bool {
+	return cell&(CellFlagHome|CellFlagSpore) != 0
+}
+
+// cellValue returns how much cell adds to its owner's score
+func cellValue(cell Cell) int {
+	if cell&CellFlagMultiplier != 0 {
+		return gbMultiplierFactor
+	}
+	return 1
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpecialCells(t *testing.T) {
+	var err error
+	var captured []int
+
+	lobbyName := "TestSpecialCells"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, opts := range []map[string]any{
+		{"shield_cells": -1},
+		{"multiplier_cells": gbMaxBonusCells + 1},
+		{"spore_cells": -1},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	// placement
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":             12,
+		"shield_cells":     3,
+		"multiplier_cells": 4,
+		"spore_cells":      5,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	for _, test := range []struct {
+		flag  Cell
+		count int
+	}{
+		{CellFlagShield, 3},
+		{CellFlagMultiplier, 4},
+		{CellFlagSpore, 5},
+	} {
+		count, _ := countBonusCells(game.board, test.flag)
+		if count != test.count {
+			t.Errorf("Expected %d cells with flag %#x. Got %d\n%s", test.count, test.flag, count, game.board.String2D())
+		}
+	}
+
+	home1 := 1 | CellFlagHome
+	home2 := 2 | CellFlagHome
+	shield2 := 2 | CellFlagShield
+
+	// shielded cells can't be captured
+	game.board = GameBoard{
+		{home1, shield2, 1, 0, 0, 0},
+		{1, 2, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, home2},
+	}
+	game.rowCount, game.colCount = 6, 6
+	captured = game.captureCells(1)
+	if !slices.Equal(captured, []int{7}) || game.board[0][1] != shield2 {
+		t.Errorf("Expected only the unshielded cell to be captured. Got %v\n%s", captured, game.board.String2D())
+	}
+
+	// or bitten
+	game.board = GameBoard{
+		{home1, 2, 2, 0, 0, 0},
+		{1, shield2, 2, 0, 0, 0},
+		{0, 2, 0, 0, 0, 0},
+		{0, 2, 0, 0, 0, 0},
+		{0, 2, 0, 0, 0, 0},
+		{0, home2, 0, 0, 0, 0},
+	}
+	if game.isLegalBite(1, game.board.getIndex1D(1, 1), biteSmall) {
+		t.Errorf("Expected a bite on a shielded cell to be illegal\n%s", game.board.String2D())
+	}
+	game.updateScores()
+	game.turn = 0
+	game.bites[0] = biteLarge.CalcBiteCost()
+	if err = game.placeBite(p1, 1, biteLarge); err != nil {
+		t.Fatalf("placeBite failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.board[1][1] != shield2 || game.board[0][1] != 0 || game.board[1][2] != 0 {
+		t.Errorf("Expected only the shielded cell to survive the bite\n%s", game.board.String2D())
+	}
+
+	// multiplier cells count double
+	game.board = GameBoard{
+		{home1, 1 | CellFlagMultiplier, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, home2},
+	}
+	game.isOver = false
+	game.updateScores()
+	if game.scores[0] != 3 || game.scores[1] != 1 {
+		t.Errorf("Expected scores of 3 and 1. Got %v", game.scores[:2])
+	}
+
+	// an owned spore cell keeps its cells when the home cell is gone
+	game.board = GameBoard{
+		{0, 1, 1, 1 | CellFlagSpore, 1, 0},
+		{0, 0, 0, 0, 0, 0},
+		{1, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, home2},
+	}
+	orphaned := game.handleOrphanedCells()
+	if !slices.Equal(orphaned, []int{12}) {
+		t.Errorf("Expected only the cell without a path to the spore cell to be removed. Got %v\n%s", orphaned, game.board.String2D())
+	}
+
+	// String2D shows every flag
+	board := GameBoard{
+		{CellFlagShield, CellFlagMultiplier, CellFlagSpore},
+	}
+	if s := board.String2D(); !strings.Contains(s, ".S") || !strings.Contains(s, ".M") || !strings.Contains(s, ".P") {
+		t.Errorf("Expected the special cells in String2D. Got %q", s)
+	}
+}
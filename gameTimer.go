@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	gameTimerNone    = iota
+	gameTimerPerTurn // every turn has the same time limit
+	gameTimerClock   // every player has a total amount of time, plus an increment after each turn
+	gameTimerMax     // For input validation. Not a timer mode.
+)
+
+// What happens to a player who runs out of time
+const (
+	gameTimeoutSkip    = iota // the turn is skipped
+	gameTimeoutForfeit        // the player forfeits the game
+	gameTimeoutMax            // For input validation. Not a timeout action.
+)
+
+const gbDefaultTimerMode = gameTimerNone
+const gbDefaultTimeoutAction = gameTimeoutSkip
+const gbDefaultTurnTime = 60 * time.Second
+const gbDefaultClockTime = 10 * time.Minute
+const gbDefaultClockIncrement = 5 * time.Second
+const gbMinTurnTime = 5 * time.Second
+const gbMaxTurnTime = 1 * time.Hour
+const gbMaxClockTime = 4 * time.Hour
+const gbMaxClockIncrement = 10 * time.Minute
+
+// validateTimerOptions checks the time control options passed to createGame.
+// Times that the timer mode doesn't use are not checked.
+func validateTimerOptions(mode int, turnTime, clockTime, clockIncrement time.Duration, timeoutAction int) error {
+	if mode < 0 || mode >= gameTimerMax {
+		return errors.New("Invalid timer parameter")
+	}
+	if timeoutAction < 0 || timeoutAction >= gameTimeoutMax {
+		return errors.New("Invalid timeout_action parameter")
+	}
+	if mode == gameTimerPerTurn && (turnTime < gbMinTurnTime || turnTime > gbMaxTurnTime) {
+		return errors.New("Invalid turn_time parameter")
+	}
+	if mode == gameTimerClock && (clockTime < gbMinTurnTime || clockTime > gbMaxClockTime) {
+		return errors.New("Invalid clock_time parameter")
+	}
+	if mode == gameTimerClock && (clockIncrement < 0 || clockIncrement > gbMaxClockIncrement) {
+		return errors.New("Invalid clock_increment parameter")
+	}
+	return nil
+}
+
+// resetTimers fills every player's clock and starts timing the current turn
+func (game *Game) resetTimers() {
+	for i := 0; i < game.playerCount; i++ {
+		game.clocks[i] = game.clockTime
+	}
+	game.turnStarted = time.Now()
+}
+
+// chargeTurnTime takes the time spent on the current turn off the player's clock
+// and adds the increment. Only used with gameTimerClock.
+func (game *Game) chargeTurnTime(now time.Time) {
+	if game.timerMode != gameTimerClock || game.turn < 0 || game.turnStarted.IsZero() {
+		return
+	}
+	game.clocks[game.turn] = max(game.clocks[game.turn]-now.Sub(game.turnStarted), 0) + game.clockIncrement
+}
+
+// timeLeft returns how much time playerIndex has for their current or next turn.
+// With simultaneous turns, every player has the time left in the round.
+func (game *Game) timeLeft(playerIndex int, now time.Time) time.Duration {
+	var left time.Duration
+	switch game.timerMode {
+	case gameTimerPerTurn:
+		left = game.turnTime
+	case gameTimerClock:
+		left = game.clocks[playerIndex]
+	default:
+		return 0
+	}
+	if (playerIndex == game.turn || game.simultaneous) && !game.isOver {
+		left -= now.Sub(game.turnStarted)
+	}
+	return max(left, 0)
+}
+
+// timesLeft returns timeLeft in milliseconds for every player, or nil if the game has no timer
+func (game *Game) timesLeft(now time.Time) []int64 {
+	if game.timerMode == gameTimerNone {
+		return nil
+	}
+	times := make([]int64, game.playerCount)
+	for i := range times {
+		times[i] = game.timeLeft(i, now).Milliseconds()
+	}
+	return times
+}
+
+// expireTurn skips the turn of the current player or makes them forfeit, depending on
+// game.timeoutAction, if they are out of time. With simultaneous turns, this happens to
+// every player who hasn't submitted a move when the round runs out of time.
+// Returns true if the player ran out of time.
+func (game *Game) expireTurn(now time.Time) bool {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	if game.timerMode == gameTimerNone || game.isOver || game.turn < 0 {
+		return false
+	}
+	if game.timeLeft(game.turn, now) > 0 {
+		return false
+	}
+
+	switch {
+	case game.simultaneous:
+		game.expireRound()
+	case game.timeoutAction == gameTimeoutForfeit:
+		game.forfeitPlayer(game.turn, true)
+	default:
+		game.skipCurrentTurn(true)
+	}
+	// running out of time can't be taken back
+	game.clearUndo()
+	return true
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTurnTimer(t *testing.T) {
+	var err error
+
+	lobbyName := "TestTurnTimer"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	// invalid options
+	for _, opts := range []map[string]any{
+		{"timer": gameTimerMax},
+		{"timer": gameTimerPerTurn, "turn_time": 1},
+		{"timer": gameTimerClock, "clock_time": 0},
+		{"timer": gameTimerClock, "clock_increment": -1},
+		{"timer": gameTimerPerTurn, "timeout_action": gameTimeoutMax},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	// no timer
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.turnStarted = time.Now().Add(-24 * time.Hour)
+	if game.timesLeft(time.Now()) != nil || game.expireTurn(time.Now()) {
+		t.Error("Expected no time limit without a timer")
+	}
+
+	// a turn that runs out of time is skipped
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"timer":     gameTimerPerTurn,
+		"turn_time": 30,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	now := time.Now()
+	game.turnStarted = now.Add(-20 * time.Second)
+	timesLeft := game.timesLeft(now)
+	if len(timesLeft) != 2 || timesLeft[0] != 10000 || timesLeft[1] != 30000 {
+		t.Errorf("Expected 10s left for player 1 and 30s for player 2. Got %v", timesLeft)
+	}
+	if game.expireTurn(now) {
+		t.Error("Expected the turn to not expire with time left")
+	}
+	if !game.expireTurn(now.Add(10 * time.Second)) {
+		t.Fatal("Expected the turn to expire")
+	}
+	lastEvent := game.history[len(game.history)-1]
+	if game.turn != 1 || lastEvent.Type != gameEventSkipTurn || !lastEvent.Timeout || lastEvent.Player != 0 {
+		t.Errorf("Expected player 1's turn to be skipped. turn=%d last event=%+v", game.turn, lastEvent)
+	}
+	if err = game.requestUndo(p1); err == nil {
+		t.Error("Expected a timeout to not be undoable")
+	}
+	if left := game.timeLeft(1, time.Now()); left < 29*time.Second {
+		t.Errorf("Expected the timer to restart for player 2. Got %s", left)
+	}
+
+	// a player who runs out of time forfeits
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"timer":          gameTimerPerTurn,
+		"turn_time":      30,
+		"timeout_action": gameTimeoutForfeit,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if !game.expireTurn(time.Now().Add(time.Minute)) {
+		t.Fatal("Expected the turn to expire")
+	}
+	lastEvent = game.history[len(game.history)-1]
+	if !game.isOver || game.scores[0] != 0 || lastEvent.Type != gameEventForfeitGame || !lastEvent.Timeout {
+		t.Errorf("Expected player 1 to forfeit. isOver=%t scores=%v last event=%+v", game.isOver, game.scores, lastEvent)
+	}
+	if game.expireTurn(time.Now().Add(time.Hour)) {
+		t.Error("Expected no timeout after the game is over")
+	}
+}
+
+func TestChessClock(t *testing.T) {
+	var err error
+
+	lobbyName := "TestChessClock"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"timer":           gameTimerClock,
+		"clock_time":      60,
+		"clock_increment": 5,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game.clocks[0] != time.Minute || game.clocks[1] != time.Minute {
+		t.Fatalf("Expected a minute on every clock. Got %v", game.clocks)
+	}
+
+	// player 1 takes about 20 seconds and gets the 5 second increment
+	game.turnStarted = time.Now().Add(-20 * time.Second)
+	if err = game.skipTurn(p1); err != nil {
+		t.Fatalf("skipTurn failed: %v", err)
+	}
+	if game.clocks[0] < 44*time.Second || game.clocks[0] > 46*time.Second || game.clocks[1] != time.Minute {
+		t.Errorf("Expected about 45s left for player 1 and 60s for player 2. Got %v", game.clocks)
+	}
+
+	// undoing the move gives the time back
+	if err = game.requestUndo(p1); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	if err = game.respondUndo(p2, true); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+	if game.turn != 0 || game.clocks[0] != time.Minute {
+		t.Errorf("Expected the undo to restore player 1's clock. turn=%d clocks=%v", game.turn, game.clocks)
+	}
+
+	// player 1 uses the rest of their clock
+	game.turnStarted = time.Now().Add(-59 * time.Second)
+	if game.expireTurn(time.Now()) {
+		t.Error("Expected the clock to not run out yet")
+	}
+	game.turnStarted = time.Now().Add(-61 * time.Second)
+	if !game.expireTurn(time.Now()) {
+		t.Fatal("Expected the clock to run out")
+	}
+	if game.turn != 1 || game.clocks[0] != game.clockIncrement {
+		t.Errorf("Expected player 1 to be out of time except for the increment. turn=%d clocks=%v", game.turn, game.clocks)
+	}
+
+	// the clocks are reset with the game
+	game.resetGame()
+	if game.clocks[0] != time.Minute || game.turn != 0 {
+		t.Errorf("Expected full clocks after a reset. Got %v", game.clocks)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// gameSnapshot holds the parts of a Game that change during a move
+type gameSnapshot struct {
+	board             GameBoard
+	turn              int
+	round             int
+	scores            [maxPlayers]int
+	bites             [maxPlayers]int
+	rerolls           [maxPlayers]int
+	newCellsForBites  [maxPlayers]int
+	nextPiece         Piece
+	pieceQueue        []Piece
+	pieceBag          []Piece
+	heldPieces        [maxPlayers]Piece
+	holdUsed          [maxPlayers]bool
+	draftPieces       []Piece
+	isOver            bool
+	winners           []int
+	winLossDrawRecord [maxPlayers]WinLossDraw
+	clocks            [maxPlayers]time.Duration
+}
+
+// UndoRequest tracks a player's request to undo their last move. Every other
+// player that was still in the game before that move must accept it.
+type UndoRequest struct {
+	Requester int              `json:"requester"` // index into Game.players
+	Accepted  [maxPlayers]bool `json:"-"`
+	Required  [maxPlayers]bool `json:"-"`
+	Pending   []int            `json:"pending"` // players that have not answered yet
+}
+
+// takeSnapshot returns a deep copy of the game state that a move can change
+func (game *Game) takeSnapshot() *gameSnapshot {
+	return &gameSnapshot{
+		board:             game.board.clone(),
+		turn:              game.turn,
+		round:             game.round,
+		scores:            game.scores,
+		bites:             game.bites,
+		rerolls:           game.rerolls,
+		newCellsForBites:  game.newCellsForBites,
+		nextPiece:         game.nextPiece,
+		pieceQueue:        slices.Clone(game.pieceQueue),
+		pieceBag:          slices.Clone(game.pieceBag),
+		heldPieces:        game.heldPieces,
+		holdUsed:          game.holdUsed,
+		draftPieces:       slices.Clone(game.draftPieces),
+		isOver:            game.isOver,
+		winners:           slices.Clone(game.winners),
+		winLossDrawRecord: game.winLossDrawRecord,
+		clocks:            game.clocks,
+	}
+}
+
+// restoreSnapshot sets the game state back to snapshot s
+func (game *Game) restoreSnapshot(s *gameSnapshot) {
+	game.board = s.board
+	game.turn = s.turn
+	game.round = s.round
+	game.scores = s.scores
+	game.bites = s.bites
+	game.rerolls = s.rerolls
+	game.newCellsForBites = s.newCellsForBites
+	game.nextPiece = s.nextPiece
+	game.pieceQueue = s.pieceQueue
+	game.pieceBag = s.pieceBag
+	game.heldPieces = s.heldPieces
+	game.holdUsed = s.holdUsed
+	game.draftPieces = s.draftPieces
+	game.isOver = s.isOver
+	game.winners = s.winners
+	game.winLossDrawRecord = s.winLossDrawRecord
+	game.clocks = s.clocks
+	game.turnStarted = time.Now() // the turn starts over
+}
+
+// saveUndoSnapshot records the state before a move so that it can be undone.
+// Any pending undo request is for an older move, so it gets dropped.
+func (game *Game) saveUndoSnapshot() {
+	game.undoSnapshot = game.takeSnapshot()
+	game.undoRequest = nil
+}
+
+// clearUndo prevents the last move from being undone
+func (game *Game) clearUndo() {
+	game.undoSnapshot = nil
+	game.undoRequest = nil
+}
+
+// getPlayerIndex returns the index of whoami in game.players or -1 if not found
+func (game *Game) getPlayerIndex(whoami Player) int {
+	for i := 0; i < game.playerCount; i++ {
+		if game.players[i].id == whoami.id {
+			return i
+		}
+	}
+	return -1
+}
+
+// requestUndo starts a request to undo the last move. Only the player who made
+// the last move may request it. If no other player needs to agree, the move is
+// undone right away.
+func (game *Game) requestUndo(whoami Player) error {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	playerIndex := game.getPlayerIndex(whoami)
+	if playerIndex < 0 {
+		return errors.New("Invalid undo: player not in game")
+	}
+	if game.undoSnapshot == nil {
+		return errors.New("Invalid undo: there is no move to undo")
+	}
+	if game.undoSnapshot.turn != playerIndex {
+		return errors.New("Invalid undo: only the player who made the last move can undo it")
+	}
+	if game.undoRequest != nil {
+		return errors.New("Invalid undo: an undo has already been requested")
+	}
+
+	request := &UndoRequest{Requester: playerIndex}
+	for i := 0; i < game.playerCount; i++ {
+		if i != playerIndex && game.undoSnapshot.scores[i] > 0 {
+			request.Required[i] = true
+		}
+	}
+	game.undoRequest = request
+	game.updateUndoRequest()
+	return nil
+}
+
+// respondUndo accepts or declines a pending undo request on behalf of whoami
+func (game *Game) respondUndo(whoami Player, accept bool) error {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	playerIndex := game.getPlayerIndex(whoami)
+	if playerIndex < 0 {
+		return errors.New("Invalid undo response: player not in game")
+	}
+	if game.undoRequest == nil {
+		return errors.New("Invalid undo response: no undo was requested")
+	}
+	if !game.undoRequest.Required[playerIndex] {
+		return errors.New("Invalid undo response: player is not part of the undo request")
+	}
+
+	if !accept {
+		game.undoRequest = nil
+		return nil
+	}
+	game.undoRequest.Accepted[playerIndex] = true
+	game.updateUndoRequest()
+	return nil
+}
+
+// updateUndoRequest refreshes the list of pending players and undoes the last move
+// once everyone has accepted
+func (game *Game) updateUndoRequest() {
+	request := game.undoRequest
+	request.Pending = request.Pending[:0]
+	for i := 0; i < game.playerCount; i++ {
+		if request.Required[i] && !request.Accepted[i] {
+			request.Pending = append(request.Pending, i)
+		}
+	}
+	if len(request.Pending) > 0 {
+		return
+	}
+
+	before := game.playerResources(request.Requester)
+	game.restoreSnapshot(game.undoSnapshot)
+	game.lastBoardUpdate = nil
+	game.clearUndo()
+	game.addEvent(GameEvent{
+		Type:   gameEventUndo,
+		Player: request.Requester,
+		Index:  -1,
+		Before: before,
+		Board:  game.board.clone(),
+		After:  game.playerResources(request.Requester),
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGameUndo(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	// create a game with 3 players
+	lobbyName := "TestGameUndo"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+	p3 := joinLobbyWrapper(t, lobbyName, "p3", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":                 boardSize,
+		"has_bonus_bite_cells": false,
+		"bonus_reroll_cells":   0,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+
+	// nothing to undo yet
+	if err = game.requestUndo(p1); err == nil {
+		t.Error("Expected requestUndo to fail before any move was made")
+	}
+
+	// put a bonus bite cell next to player 1's home and capture it with a single square
+	homeIndex := game.board.getIndex1D(boardSize/gbStartOffsetDivisor, boardSize/gbStartOffsetDivisor)
+	r, c := game.board.getIndex2D(homeIndex + 1)
+	game.board[r][c] = CellFlagBonusBite
+	game.nextPiece = Piece{PieceMask(0b1000000).generateRotations(), 1}
+
+	boardBefore := game.board.String2D()
+	nextPieceBefore := game.nextPiece
+	scoresBefore, bitesBefore, rerollsBefore := game.scores, game.bites, game.rerolls
+	newCellsForBitesBefore := game.newCellsForBites
+
+	err = game.placePiece(p1, homeIndex+1, game.nextPiece.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.bites[0] == bitesBefore[0] {
+		t.Fatalf("Expected player 1 to be awarded bites for the bonus cell")
+	}
+
+	// only player 1 can request the undo
+	if err = game.requestUndo(p2); err == nil {
+		t.Error("Expected requestUndo to fail for a player that did not make the last move")
+	}
+	if err = game.respondUndo(p2, true); err == nil {
+		t.Error("Expected respondUndo to fail without a pending request")
+	}
+
+	// player 3 declines
+	if err = game.requestUndo(p1); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	if err = game.requestUndo(p1); err == nil {
+		t.Error("Expected a second requestUndo to fail while one is pending")
+	}
+	if err = game.respondUndo(p1, true); err == nil {
+		t.Error("Expected respondUndo to fail for the requester")
+	}
+	if err = game.respondUndo(p2, true); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+	if game.undoRequest == nil || len(game.undoRequest.Pending) != 1 || game.undoRequest.Pending[0] != 2 {
+		t.Fatalf("Expected undo request to be waiting on player 3. Got: %+v", game.undoRequest)
+	}
+	if err = game.respondUndo(p3, false); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+	if game.undoRequest != nil {
+		t.Fatalf("Expected undo request to be cleared after a decline. Got: %+v", game.undoRequest)
+	}
+	if game.turn != 1 || game.board.String2D() == boardBefore {
+		t.Fatalf("Expected declined undo to leave the game unchanged")
+	}
+
+	// everyone accepts
+	if err = game.requestUndo(p1); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	if err = game.respondUndo(p3, true); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+	if err = game.respondUndo(p2, true); err != nil {
+		t.Fatalf("respondUndo failed: %v", err)
+	}
+
+	if game.board.String2D() != boardBefore {
+		t.Errorf("Board was not restored.\nExpected:\n%s\nGot:\n%s", boardBefore, game.board.String2D())
+	}
+	if game.turn != 0 {
+		t.Errorf("Expected turn to be restored to 0. Got: %d", game.turn)
+	}
+	if game.nextPiece != nextPieceBefore {
+		t.Errorf("Expected nextPiece to be restored to %v. Got: %v", nextPieceBefore, game.nextPiece)
+	}
+	if game.scores != scoresBefore || game.bites != bitesBefore || game.rerolls != rerollsBefore ||
+		game.newCellsForBites != newCellsForBitesBefore {
+		t.Errorf("Resources were not restored. scores=%v bites=%v rerolls=%v newCellsForBites=%v",
+			game.scores, game.bites, game.rerolls, game.newCellsForBites)
+	}
+	if last := game.history[len(game.history)-1]; last.Type != gameEventUndo || last.Player != 0 {
+		t.Errorf("Expected an undo event by player 0 at the end of the history. Got: %+v", last)
+	}
+
+	// only one level of undo
+	if err = game.requestUndo(p1); err == nil {
+		t.Error("Expected requestUndo to fail after the move was already undone")
+	}
+}
+
+func TestGameUndoAfterRerollOrHold(t *testing.T) {
+	var err error
+
+	lobbyName := "TestGameUndoAfterRerollOrHold"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, action := range []string{"reroll", "hold"} {
+		game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 10, "allow_hold": true})
+		if err != nil {
+			t.Fatalf("createGame failed: %v", err)
+		}
+		game.nextPiece = Piece{PieceMask(0b1000000).generateRotations(), 1}
+		homeIndex := game.board.getIndex1D(10/gbStartOffsetDivisor, 10/gbStartOffsetDivisor)
+		if err = game.placePiece(p1, homeIndex+1, game.nextPiece.Masks[0]); err != nil {
+			t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+		}
+
+		// the next player acting ends the chance to undo
+		if action == "reroll" {
+			err = game.reroll(p2)
+		} else {
+			err = game.holdPiece(p2)
+		}
+		if err != nil {
+			t.Fatalf("%s failed: %v", action, err)
+		}
+		if err = game.requestUndo(p1); err == nil {
+			t.Errorf("Expected requestUndo to fail after player 2 used %s", action)
+		}
+	}
+
+	// a pending request is dropped too
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 10})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.nextPiece = Piece{PieceMask(0b1000000).generateRotations(), 1}
+	homeIndex := game.board.getIndex1D(10/gbStartOffsetDivisor, 10/gbStartOffsetDivisor)
+	if err = game.placePiece(p1, homeIndex+1, game.nextPiece.Masks[0]); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if err = game.requestUndo(p1); err != nil {
+		t.Fatalf("requestUndo failed: %v", err)
+	}
+	rerolls := game.rerolls[1]
+	if err = game.reroll(p2); err != nil {
+		t.Fatalf("reroll failed: %v", err)
+	}
+	if err = game.respondUndo(p2, true); err == nil {
+		t.Error("Expected respondUndo to fail after the reroll")
+	}
+	if game.turn != 1 || game.rerolls[1] != rerolls-1 {
+		t.Errorf("Expected the reroll to stand. turn=%d rerolls=%d", game.turn, game.rerolls[1])
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// Win conditions, in addition to being the last player (or team) with cells on the board.
+// 0 turns a condition off.
+const gbDefaultWinTerritory = 0 // percent of the cells that aren't walls
+const gbDefaultWinScore = 0
+const gbDefaultMaxRounds = 0
+const gbMaxRounds = 500
+
+// How a tie for the highest score is broken when the game ends without an elimination
+const (
+	gameTieBreakDraw      = iota // the tied players draw
+	gameTieBreakResources        // most bites left wins, then most rerolls left, then a draw
+	gameTieBreakMax              // For input validation. Not a tie break rule.
+)
+
+const gbDefaultTieBreak = gameTieBreakDraw
+
+// validateWinConditions checks the win condition options passed to createGame.
+// startScore and startTerritory are the leading score and share of the board when the
+// game starts, see leadingShare. A win condition the leader already meets is rejected,
+// the game would be over before the first move.
+func validateWinConditions(winTerritory, winScore, maxRounds, tieBreak, startScore, startTerritory int) error {
+	if winTerritory < 0 || winTerritory > 100 || (winTerritory > 0 && winTerritory <= startTerritory) {
+		return errors.New("Invalid win_territory parameter")
+	}
+	if winScore < 0 || winScore > gbMaxSize*gbMaxSize || (winScore > 0 && winScore <= startScore) {
+		return errors.New("Invalid win_score parameter")
+	}
+	if maxRounds < 0 || maxRounds > gbMaxRounds {
+		return errors.New("Invalid max_rounds parameter")
+	}
+	if tieBreak < 0 || tieBreak >= gameTieBreakMax {
+		return errors.New("Invalid tie_break parameter")
+	}
+	return nil
+}
+
+// teamResources returns the bites and rerolls left for the team of playerIndex
+func (game *Game) teamResources(playerIndex int) (bites, rerolls int) {
+	for i := 0; i < game.playerCount; i++ {
+		if game.teamOf(i) == game.teamOf(playerIndex) {
+			bites += game.bites[i]
+			rerolls += game.rerolls[i]
+		}
+	}
+	return bites, rerolls
+}
+
+// leadingPlayers returns the players (or team) with the highest score. A tie is broken
+// by game.tieBreak. Players that are still tied draw.
+func (game *Game) leadingPlayers() []int {
+	ranking := game.rankPlayers()
+	var leaders []int
+	for _, i := range ranking {
+		if game.teamScore(i) != game.teamScore(ranking[0]) {
+			break
+		}
+		leaders = append(leaders, i)
+	}
+
+	if game.tieBreak == gameTieBreakResources {
+		compareResources := func(a, b int) int {
+			aBites, aRerolls := game.teamResources(a)
+			bBites, bRerolls := game.teamResources(b)
+			if aBites != bBites {
+				return aBites - bBites
+			}
+			return aRerolls - bRerolls
+		}
+		best := slices.MaxFunc(leaders, compareResources)
+		leaders = slices.DeleteFunc(leaders, func(i int) bool {
+			return compareResources(i, best) != 0
+		})
+	}
+	return leaders
+}
+
+// playableScore returns the score of a player who owns every cell that isn't a wall.
+// Multiplier cells count more here too, see cellValue. This is intended: win_territory is
+// a share of this score rather than of the cells, so a multiplier cell counts for as much
+// towards the territory goal as it does towards the score.
+func (game *Game) playableScore() int {
+	playable := 0
+	for r := 0; r < len(game.board); r++ {
+		for c := 0; c < len(game.board[0]); c++ {
+			if game.board[r][c]&CellFlagWall == 0 {
+				playable += cellValue(game.board[r][c])
+			}
+		}
+	}
+	return playable
+}
+
+// leadingShare returns the highest team score and the percent of the playable score it
+// makes up, rounded down
+func (game *Game) leadingShare() (score, territory int) {
+	for i := 0; i < game.playerCount; i++ {
+		score = max(score, game.teamScore(i))
+	}
+	if playable := game.playableScore(); playable > 0 {
+		territory = score * 100 / playable
+	}
+	return score, territory
+}
+
+// winConditionMet returns true if a player (or team) owns enough of the board or has
+// reached the target score
+func (game *Game) winConditionMet() bool {
+	if game.winTerritory == 0 && game.winScore == 0 {
+		return false
+	}
+	score, _ := game.leadingShare()
+	if game.winTerritory > 0 && score*100 >= game.winTerritory*game.playableScore() {
+		return true
+	}
+	return game.winScore > 0 && score >= game.winScore
+}
+
+// endWithLeaders ends the game. The leading players win, or draw if a tie can't be broken.
+// Sets turn to -1 and records eventType.
+func (game *Game) endWithLeaders(eventType string) {
+	game.winners = game.leadingPlayers()
+	game.isOver = true
+	game.addResult(game.winners)
+	game.addEvent(GameEvent{
+		Type:   eventType,
+		Player: -1,
+		Index:  -1,
+	})
+	game.turn = -1
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWinConditions(t *testing.T) {
+	var err error
+
+	lobbyName := "TestWinConditions"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	for _, opts := range []map[string]any{
+		{"win_territory": -1},
+		{"win_territory": 101},
+		{"win_score": -1},
+		{"size": 6, "win_score": 1},     // each player starts with 1 cell
+		{"size": 6, "win_territory": 2}, // 1 of 36 cells
+		{"max_rounds": gbMaxRounds + 1},
+		{"tie_break": gameTieBreakMax},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	wall := CellFlagWall
+	home1 := 1 | CellFlagHome
+	home2 := 2 | CellFlagHome
+
+	// territory, walls aren't counted
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 6, "win_territory": 50})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = GameBoard{
+		{home1, 1, 1, 1, 1, 1},
+		{1, 1, 0, 0, 0, 0},
+		{wall, wall, wall, wall, wall, wall},
+		{wall, wall, wall, wall, wall, wall},
+		{wall, wall, wall, wall, wall, wall},
+		{0, 0, 0, 0, 0, home2},
+	}
+	game.updateScores()
+	game.advanceTurn()
+	if game.isOver {
+		t.Errorf("Expected the game to continue with 8 of 18 cells. scores=%v\n%s", game.scores, game.board.String2D())
+	}
+	game.board[1][2] = 1
+	game.updateScores()
+	game.advanceTurn()
+	if !game.isOver || game.turn != -1 || !slices.Equal(game.winners, []int{0}) || game.winLossDrawRecord[0].W != 1 {
+		t.Errorf("Expected Player 1 to win with 9 of 18 cells. isOver=%t turn=%d winners=%v record=%v",
+			game.isOver, game.turn, game.winners, game.winLossDrawRecord)
+	}
+	if last := game.history[len(game.history)-1]; last.Type != gameEventWinCondition {
+		t.Errorf("Expected a win condition event at the end of the history. Got %+v", last)
+	}
+
+	// a multiplier cell counts double in the playable score too
+	game.board[1][3] = CellFlagMultiplier
+	if playable := game.playableScore(); playable != 18+gbMultiplierFactor-1 {
+		t.Errorf("Expected the multiplier cell to count %d times. Got a playable score of %d", gbMultiplierFactor, playable)
+	}
+
+	// target score
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{"size": 6, "win_score": 3})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(6, 6)
+	game.board[0][0] = home1
+	game.board[5][5] = home2
+	game.board[5][4] = 2
+	game.updateScores()
+	game.advanceTurn()
+	if game.isOver {
+		t.Errorf("Expected the game to continue below the target score. scores=%v", game.scores)
+	}
+	game.board[5][3] = 2
+	game.updateScores()
+	game.advanceTurn()
+	if !game.isOver || !slices.Equal(game.winners, []int{1}) {
+		t.Errorf("Expected Player 2 to win at the target score. isOver=%t winners=%v", game.isOver, game.winners)
+	}
+
+	// round limit, ending in a tie
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{"size": 6, "max_rounds": 2})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(6, 6)
+	game.board[0][0] = home1
+	game.board[5][5] = home2
+	game.updateScores()
+	game.turn = 0
+	for range 3 {
+		game.advanceTurn()
+	}
+	if game.isOver || game.round != 1 || game.turn != 1 {
+		t.Errorf("Expected round 1 to be under way. isOver=%t round=%d turn=%d", game.isOver, game.round, game.turn)
+	}
+	game.advanceTurn()
+	if !game.isOver || game.turn != -1 || !slices.Equal(game.winners, []int{0, 1}) {
+		t.Errorf("Expected a draw after 2 rounds. isOver=%t turn=%d winners=%v", game.isOver, game.turn, game.winners)
+	}
+	if game.winLossDrawRecord[0].D != 1 || game.winLossDrawRecord[1].D != 1 {
+		t.Errorf("Expected a draw in the record. Got %v", game.winLossDrawRecord)
+	}
+
+	// tie broken by bites, then rerolls
+	game.tieBreak = gameTieBreakResources
+	game.bites[0], game.bites[1] = 1, 1
+	game.rerolls[0], game.rerolls[1] = 0, 2
+	if winners := game.leadingPlayers(); !slices.Equal(winners, []int{1}) {
+		t.Errorf("Expected Player 2 to win with more rerolls left. Got %v", winners)
+	}
+	game.bites[0] = 2
+	if winners := game.leadingPlayers(); !slices.Equal(winners, []int{0}) {
+		t.Errorf("Expected Player 1 to win with more bites left. Got %v", winners)
+	}
+	game.bites[0], game.rerolls[0] = 1, 2
+	if winners := game.leadingPlayers(); len(winners) != 2 {
+		t.Errorf("Expected a draw with equal resources. Got %v", winners)
+	}
+}
+
+func TestForfeitAfterGameEnds(t *testing.T) {
+	var err error
+
+	lobbyName := "TestForfeitAfterGameEnds"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	home1 := 1 | CellFlagHome
+	home2 := 2 | CellFlagHome
+	home3 := 3 | CellFlagHome
+
+	// max rounds, ending in a tie
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 6, "max_rounds": 1})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.turn = 0
+	for range 2 {
+		game.advanceTurn()
+	}
+	if !game.isOver {
+		t.Fatalf("Expected the game to end after 1 round")
+	}
+	record := game.winLossDrawRecord
+	if err = game.forfeitGame(p1); err == nil {
+		t.Error("Expected forfeitGame to fail after the round limit ended the game")
+	}
+	if !game.isOver || game.turn != -1 || len(game.winners) != 2 || game.winLossDrawRecord != record {
+		t.Errorf("Expected the draw to stand. isOver=%t turn=%d winners=%v record=%v",
+			game.isOver, game.turn, game.winners, game.winLossDrawRecord)
+	}
+
+	// target score, with a third player who hasn't lost yet
+	p3 := joinLobbyWrapper(t, lobbyName, "p3", "")
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{"size": 6, "win_score": 3})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(6, 6)
+	game.board[0][0] = home1
+	game.board[0][1] = 1
+	game.board[0][2] = 1
+	game.board[5][5] = home2
+	game.board[5][0] = home3
+	game.updateScores()
+	game.advanceTurn()
+	if !game.isOver || !slices.Equal(game.winners, []int{0}) {
+		t.Fatalf("Expected Player 1 to win at the target score. isOver=%t winners=%v", game.isOver, game.winners)
+	}
+	record = game.winLossDrawRecord
+	if err = game.forfeitGame(p3); err == nil {
+		t.Error("Expected forfeitGame to fail after the target score ended the game")
+	}
+	if !game.isOver || game.turn != -1 || !slices.Equal(game.winners, []int{0}) || game.winLossDrawRecord != record {
+		t.Errorf("Expected Player 1's win to stand. isOver=%t turn=%d winners=%v record=%v",
+			game.isOver, game.turn, game.winners, game.winLossDrawRecord)
+	}
+
+	// a finished game keeps its result when scores are updated again
+	game.board[0][0] = 0
+	game.board[5][0] = 0
+	game.updateScores()
+	if !slices.Equal(game.winners, []int{0}) || game.winLossDrawRecord != record {
+		t.Errorf("Expected updateScores to keep the result. winners=%v record=%v", game.winners, game.winLossDrawRecord)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"slices"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -72,7 +74,7 @@ func TestPieceMaskHas(t *testing.T) {
 	var p PieceMask
 	var tests []test
 
-	p = 0b11100_00000_00000_00000_00000 // horizontal line, length 3
+	p = 0b1110000_0000000_0000000_0000000_0000000_0000000_0000000 // horizontal line, length 3
 	tests = []test{
 		{0, 0, true},
 		{0, 1, true},
@@ -95,7 +97,7 @@ func TestPieceMaskHas(t *testing.T) {
 		}
 	}
 
-	p = 0b10000_10000_10000_00000_00000 // vertical line, length 3
+	p = 0b1000000_1000000_1000000_0000000_0000000_0000000_0000000 // vertical line, length 3
 	tests = []test{
 		{0, 0, true},
 		{0, 1, false},
@@ -124,7 +126,7 @@ func TestGamePieceRotations(t *testing.T) {
 	// sanity check Piece.has()
 	var p PieceMask
 	var r, c int
-	p = PieceMask(0b10000_00100_00000_00000_00000)
+	p = PieceMask(0b1000000_0010000_0000000_0000000_0000000_0000000_0000000)
 	r = 0
 	c = 0
 	if !p.has(r, c) {
@@ -142,22 +144,26 @@ func TestGamePieceRotations(t *testing.T) {
 	}
 
 	// test rotations of a single square
-	// all rotations should equal the original
-	p = PieceMask(0b10000_00000_00000_00000_00000)
+	// all rotations equal the original, so only the first one is kept
+	p = PieceMask(0b1000000_0000000_0000000_0000000_0000000_0000000_0000000)
 	piece := p.generateRotations()
-	for i := 0; i < 4; i++ {
-		if piece[i] != 0b10000_00000_00000_00000_00000 {
-			t.Errorf("%v rotated %d times gave %v. Expected %v", p, i, piece[i], p)
+	for i := 0; i < maxPieceOrientations; i++ {
+		expected := PieceMask(0)
+		if i == 0 {
+			expected = p
+		}
+		if piece[i] != expected {
+			t.Errorf("%v rotated %d times gave %v. Expected %v", p, i, piece[i], expected)
 		}
 	}
 
 	// test rotations of a corner with a long side
-	p = PieceMask(0b11100_10000_00000_00000_00000)
+	p = PieceMask(0b1110000_1000000_0000000_0000000_0000000_0000000_0000000)
 	expected := [4]PieceMask{
-		PieceMask(0b11100_10000_00000_00000_00000),
-		PieceMask(0b11000_01000_01000_00000_00000),
-		PieceMask(0b00100_11100_00000_00000_00000),
-		PieceMask(0b10000_10000_11000_00000_00000),
+		PieceMask(0b1110000_1000000_0000000_0000000_0000000_0000000_0000000),
+		PieceMask(0b1100000_0100000_0100000_0000000_0000000_0000000_0000000),
+		PieceMask(0b0010000_1110000_0000000_0000000_0000000_0000000_0000000),
+		PieceMask(0b1000000_1000000_1100000_0000000_0000000_0000000_0000000),
 	}
 	piece = p.generateRotations()
 	for i := 0; i < 4; i++ {
@@ -165,6 +171,29 @@ func TestGamePieceRotations(t *testing.T) {
 			t.Errorf("%v rotated %d times gave %v. Expected %v", p, i, piece[i], expected[i])
 		}
 	}
+
+	// test mirror images of the same corner
+	expectedMirrors := [4]PieceMask{
+		PieceMask(0b1110000_0010000_0000000_0000000_0000000_0000000_0000000),
+		PieceMask(0b0100000_0100000_1100000_0000000_0000000_0000000_0000000),
+		PieceMask(0b1000000_1110000_0000000_0000000_0000000_0000000_0000000),
+		PieceMask(0b1100000_1000000_1000000_0000000_0000000_0000000_0000000),
+	}
+	piece = p.generateOrientations()
+	for i := 0; i < 4; i++ {
+		if piece[i] != expected[i] || piece[maxPieceRotations+i] != expectedMirrors[i] {
+			t.Errorf("Orientation %d of %v gave %v and %v. Expected %v and %v",
+				i, p, piece[i], piece[maxPieceRotations+i], expected[i], expectedMirrors[i])
+		}
+	}
+
+	// a 2x2 square has a single orientation
+	piece = PieceMask(0b1100000_1100000_0000000_0000000_0000000_0000000_0000000).generateOrientations()
+	for i := 1; i < maxPieceOrientations; i++ {
+		if piece[i] != 0 {
+			t.Errorf("Expected a single orientation for a 2x2 square. Got %v", piece)
+		}
+	}
 }
 
 func TestPieceMaskGetSize(t *testing.T) {
@@ -176,10 +205,10 @@ func TestPieceMaskGetSize(t *testing.T) {
 	var tests []test
 
 	tests = []test{
-		{0b10000_00000_00000_00000_00000, 1, 1}, // 1x1 square
-		{0b11000_11000_00000_00000_00000, 2, 2}, // 2x2 square
-		{0b11100_00000_00000_00000_00000, 1, 3}, // horizontal line, length 3
-		{0b10000_10000_10000_00000_00000, 3, 1}, // vertical line, length 3
+		{0b1000000_0000000_0000000_0000000_0000000_0000000_0000000, 1, 1}, // 1x1 square
+		{0b1100000_1100000_0000000_0000000_0000000_0000000_0000000, 2, 2}, // 2x2 square
+		{0b1110000_0000000_0000000_0000000_0000000_0000000_0000000, 1, 3}, // horizontal line, length 3
+		{0b1000000_1000000_1000000_0000000_0000000_0000000_0000000, 3, 1}, // vertical line, length 3
 	}
 	for _, testCase := range tests {
 		r, c := testCase.p.getSize()
@@ -202,20 +231,20 @@ func TestPieceString2D(t *testing.T) {
 
 	tests = []test{
 		{ // 1x1 square
-			0b10000_00000_00000_00000_00000,
-			"1 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0",
+			0b1000000_0000000_0000000_0000000_0000000_0000000_0000000,
+			"1 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0",
 		},
 		{ // 2x2 square
-			0b11000_11000_00000_00000_00000,
-			"1 1 0 0 0\n1 1 0 0 0\n0 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0",
+			0b1100000_1100000_0000000_0000000_0000000_0000000_0000000,
+			"1 1 0 0 0 0 0\n1 1 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0",
 		},
 		{ // horizontal line, length 3
-			0b11100_00000_00000_00000_00000,
-			"1 1 1 0 0\n0 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0",
+			0b1110000_0000000_0000000_0000000_0000000_0000000_0000000,
+			"1 1 1 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0",
 		},
 		{ // vertical line, length 3
-			0b10000_10000_10000_00000_00000,
-			"1 0 0 0 0\n1 0 0 0 0\n1 0 0 0 0\n0 0 0 0 0\n0 0 0 0 0",
+			0b1000000_1000000_1000000_0000000_0000000_0000000_0000000,
+			"1 0 0 0 0 0 0\n1 0 0 0 0 0 0\n1 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0\n0 0 0 0 0 0 0",
 		},
 	}
 	for _, testCase := range tests {
@@ -247,22 +276,22 @@ func TestGetPieceIndices(t *testing.T) {
 	tests = []test{
 		{ // 1x1 square
 			0,
-			0b10000_00000_00000_00000_00000,
+			0b1000000_0000000_0000000_0000000_0000000_0000000_0000000,
 			[]int{0},
 		},
 		{ // 2x2 square
 			1,
-			0b11000_11000_00000_00000_00000,
+			0b1100000_1100000_0000000_0000000_0000000_0000000_0000000,
 			[]int{1, 2, 13, 14},
 		},
 		{ // horizontal line, length 3
 			14,
-			0b11100_00000_00000_00000_00000,
+			0b1110000_0000000_0000000_0000000_0000000_0000000_0000000,
 			[]int{14, 15, 16},
 		},
 		{ // vertical line, length 3
 			30,
-			0b10000_10000_10000_00000_00000,
+			0b1000000_1000000_1000000_0000000_0000000_0000000_0000000,
 			[]int{30, 42, 54},
 		},
 	}
@@ -298,34 +327,34 @@ func TestIsPieceInBounds(t *testing.T) {
 		expected bool
 	}{
 		// single cell
-		{0, PieceMask(0b00001).shiftUp(), true},
-		{9, PieceMask(0b00001).shiftUp(), true},
-		{42, PieceMask(0b00001).shiftUp(), true},
-		{90, PieceMask(0b00001).shiftUp(), true},
-		{99, PieceMask(0b00001).shiftUp(), true},
+		{0, PieceMask(0b0000100).shiftUp(), true},
+		{9, PieceMask(0b0000100).shiftUp(), true},
+		{42, PieceMask(0b0000100).shiftUp(), true},
+		{90, PieceMask(0b0000100).shiftUp(), true},
+		{99, PieceMask(0b0000100).shiftUp(), true},
 
 		// 2x2
-		{0, PieceMask(0b00011_00011).shiftUp(), true},
-		{8, PieceMask(0b00011_00011).shiftUp(), true},
-		{9, PieceMask(0b00011_00011).shiftUp(), false},
-		{42, PieceMask(0b00011_00011).shiftUp(), true},
-		{80, PieceMask(0b00011_00011).shiftUp(), true},
-		{90, PieceMask(0b00011_00011).shiftUp(), false},
-		{99, PieceMask(0b00011_00011).shiftUp(), false},
+		{0, PieceMask(0b0001100_0001100).shiftUp(), true},
+		{8, PieceMask(0b0001100_0001100).shiftUp(), true},
+		{9, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{42, PieceMask(0b0001100_0001100).shiftUp(), true},
+		{80, PieceMask(0b0001100_0001100).shiftUp(), true},
+		{90, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{99, PieceMask(0b0001100_0001100).shiftUp(), false},
 
 		// tall column
-		{0, PieceMask(0b10000_10000_10000_10000_10000), true},
-		{9, PieceMask(0b10000_10000_00000_00000_00000), true},
-		{42, PieceMask(0b10000_10000_10000_10000_10000), true},
-		{67, PieceMask(0b10000_10000_10000_10000_10000), false},
-		{99, PieceMask(0b10000_10000_10000_10000_10000), false},
+		{0, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
+		{9, PieceMask(0b1000000_1000000_0000000_0000000_0000000_0000000_0000000), true},
+		{42, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
+		{67, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), false},
+		{99, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), false},
 
 		// long row
-		{0, PieceMask(0b11111).shiftUp(), true},
-		{9, PieceMask(0b11111).shiftUp(), false},
-		{42, PieceMask(0b11111).shiftUp(), true},
-		{90, PieceMask(0b11111).shiftUp(), true},
-		{99, PieceMask(0b11111).shiftUp(), false},
+		{0, PieceMask(0b1111100).shiftUp(), true},
+		{9, PieceMask(0b1111100).shiftUp(), false},
+		{42, PieceMask(0b1111100).shiftUp(), true},
+		{90, PieceMask(0b1111100).shiftUp(), true},
+		{99, PieceMask(0b1111100).shiftUp(), false},
 	}
 	for _, test := range tests {
 		res := game.isPieceInBounds(test.index, test.mask)
@@ -370,30 +399,30 @@ func TestIsPieceOnFreeSpace(t *testing.T) {
 		expected bool
 	}{
 		// single cell
-		{0, PieceMask(0b00001).shiftUp(), true},
-		{1, PieceMask(0b00001).shiftUp(), false},
-		{2, PieceMask(0b00001).shiftUp(), false},
-		{3, PieceMask(0b00001).shiftUp(), true},
+		{0, PieceMask(0b0000100).shiftUp(), true},
+		{1, PieceMask(0b0000100).shiftUp(), false},
+		{2, PieceMask(0b0000100).shiftUp(), false},
+		{3, PieceMask(0b0000100).shiftUp(), true},
 
 		// 2x2
-		{0, PieceMask(0b00011_00011).shiftUp(), false},
-		{1, PieceMask(0b00011_00011).shiftUp(), false},
-		{2, PieceMask(0b00011_00011).shiftUp(), false},
-		{3, PieceMask(0b00011_00011).shiftUp(), true},
-		{35, PieceMask(0b00011_00011).shiftUp(), true},
-		{36, PieceMask(0b00011_00011).shiftUp(), false},
-		{37, PieceMask(0b00011_00011).shiftUp(), false},
-		{38, PieceMask(0b00011_00011).shiftUp(), true},
+		{0, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{1, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{2, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{3, PieceMask(0b0001100_0001100).shiftUp(), true},
+		{35, PieceMask(0b0001100_0001100).shiftUp(), true},
+		{36, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{37, PieceMask(0b0001100_0001100).shiftUp(), false},
+		{38, PieceMask(0b0001100_0001100).shiftUp(), true},
 
 		// tall column
-		{0, PieceMask(0b10000_10000_10000_10000_10000), true},
-		{1, PieceMask(0b10000_10000_10000_10000_10000), false},
-		{2, PieceMask(0b10000_10000_10000_10000_10000), false},
-		{3, PieceMask(0b10000_10000_10000_10000_10000), true},
-		{12, PieceMask(0b10000_10000_10000_10000_10000), true},
-		{13, PieceMask(0b10000_10000_10000_10000_10000), false},
-		{14, PieceMask(0b10000_10000_10000_10000_10000), true},
-		{15, PieceMask(0b10000_10000_10000_10000_10000), true},
+		{0, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
+		{1, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), false},
+		{2, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), false},
+		{3, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
+		{12, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
+		{13, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), false},
+		{14, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
+		{15, PieceMask(0b1000000_1000000_1000000_1000000_1000000_0000000_0000000), true},
 	}
 	for _, test := range tests {
 		res := game.isPieceOnFreeSpace(test.index, test.mask)
@@ -450,17 +479,17 @@ func TestIsPieceAdjacentToPlayer(t *testing.T) {
 		expected bool
 	}{
 		// test Player 1 piece bordering Player 1 cells
-		{1, 31, PieceMask(0b10000_11000).shiftUp(), true},
+		{1, 31, PieceMask(0b1000000_1100000).shiftUp(), true},
 		// test Player 2 piece bordering Player 2 cells
-		{2, 16, PieceMask(0b10000_11000).shiftUp(), true},
+		{2, 16, PieceMask(0b1000000_1100000).shiftUp(), true},
 		// test Player 1 piece bordering empty cells
-		{1, 81, PieceMask(0b11000_11000).shiftUp(), false},
+		{1, 81, PieceMask(0b1100000_1100000).shiftUp(), false},
 		// test Player 2 piece bordering empty cells
-		{2, 74, PieceMask(0b11000_11000).shiftUp(), false},
+		{2, 74, PieceMask(0b1100000_1100000).shiftUp(), false},
 		// test Player 1 piece bordering cell with flags (Player 2 home)
-		{1, 69, PieceMask(0b10000_10000_10000).shiftUp(), false},
+		{1, 69, PieceMask(0b1000000_1000000_1000000).shiftUp(), false},
 		// test Player 2 piece bordering cell with flags (Player 1 home)
-		{2, 30, PieceMask(0b11110).shiftUp(), false},
+		{2, 30, PieceMask(0b1111000).shiftUp(), false},
 	}
 
 	for _, test := range tests {
@@ -511,40 +540,40 @@ func TestIsPieceAdjacentToPlayer(t *testing.T) {
 		expected bool
 	}{
 		// test Player 1 piece bordering Player 1 cells
-		{1, 23, PieceMask(0b10000_11000).shiftUp(), true},
+		{1, 23, PieceMask(0b1000000_1100000).shiftUp(), true},
 		// test Player 1 piece bordering Player 2 cells
-		{1, 28, PieceMask(0b10000_11000).shiftUp(), false},
+		{1, 28, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 1 piece bordering Player 3 cells
-		{1, 78, PieceMask(0b10000_11000).shiftUp(), false},
+		{1, 78, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 1 piece bordering Player 4 cells
-		{1, 82, PieceMask(0b10000_11000).shiftUp(), false},
+		{1, 82, PieceMask(0b1000000_1100000).shiftUp(), false},
 
 		// test Player 2 piece bordering Player 1 cells
-		{2, 23, PieceMask(0b10000_11000).shiftUp(), false},
+		{2, 23, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 2 piece bordering Player 2 cells
-		{2, 28, PieceMask(0b10000_11000).shiftUp(), false},
+		{2, 28, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 2 piece bordering Player 3 cells
-		{2, 78, PieceMask(0b10000_11000).shiftUp(), true},
+		{2, 78, PieceMask(0b1000000_1100000).shiftUp(), true},
 		// test Player 2 piece bordering Player 4 cells
-		{2, 82, PieceMask(0b10000_11000).shiftUp(), false},
+		{2, 82, PieceMask(0b1000000_1100000).shiftUp(), false},
 
 		// test Player 3 piece bordering Player 1 cells
-		{3, 23, PieceMask(0b10000_11000).shiftUp(), false},
+		{3, 23, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 3 piece bordering Player 2 cells
-		{3, 28, PieceMask(0b10000_11000).shiftUp(), true},
+		{3, 28, PieceMask(0b1000000_1100000).shiftUp(), true},
 		// test Player 3 piece bordering Player 3 cells
-		{3, 78, PieceMask(0b10000_11000).shiftUp(), false},
+		{3, 78, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 3 piece bordering Player 4 cells
-		{3, 82, PieceMask(0b10000_11000).shiftUp(), false},
+		{3, 82, PieceMask(0b1000000_1100000).shiftUp(), false},
 
 		// test Player 4 piece bordering Player 1 cells
-		{4, 23, PieceMask(0b10000_11000).shiftUp(), false},
+		{4, 23, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 4 piece bordering Player 2 cells
-		{4, 28, PieceMask(0b10000_11000).shiftUp(), false},
+		{4, 28, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 4 piece bordering Player 3 cells
-		{4, 78, PieceMask(0b10000_11000).shiftUp(), false},
+		{4, 78, PieceMask(0b1000000_1100000).shiftUp(), false},
 		// test Player 4 piece bordering Player 4 cells
-		{4, 82, PieceMask(0b10000_11000).shiftUp(), true},
+		{4, 82, PieceMask(0b1000000_1100000).shiftUp(), true},
 	}
 
 	for _, test := range tests {
@@ -624,6 +653,90 @@ func TestIsBiteAdjacentToPlayer(t *testing.T) {
 	}
 }
 
+func TestLegalMoves(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	// create 2 player game
+	lobbyName := "TestLegalMoves"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": boardSize})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+
+	// setup board. Player 1 is boxed into the corner by Player 2
+	board := GameBoard{
+		{1, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{2, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{2, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	board[0][0] |= CellFlagHome
+	board[2][0] |= CellFlagHome
+	game.board = board
+
+	square := Piece{PieceMask(0b1000000).generateRotations(), 1}
+	domino := Piece{PieceMask(0b1100000).generateRotations(), 1}
+
+	tests := []struct {
+		piece              Piece
+		bites              int
+		expectedPlacements []Move
+		expectedBites      []Move
+	}{
+		// one free neighbor and no bites
+		{square, 0, []Move{{1, square.Masks[0]}}, nil},
+		// both orientations of a domino fit next to (0,0), duplicate rotations are skipped
+		{domino, 1,
+			[]Move{{1, domino.Masks[0]}, {1, domino.Masks[1]}},
+			[]Move{{10, biteSmall}},
+		},
+		// large bites are listed after small bites
+		{square, 3,
+			[]Move{{1, square.Masks[0]}},
+			[]Move{{10, biteSmall}, {0, biteLarge}, {10, biteLarge}},
+		},
+	}
+
+	for _, test := range tests {
+		game.nextPiece = test.piece
+		game.bites[0] = test.bites
+		placements, bites := game.legalMoves(0)
+		if !slices.Equal(placements, test.expectedPlacements) {
+			t.Errorf("legalMoves(0) with piece %v returned placements %v instead of %v. Board:\n%s",
+				test.piece.Masks, placements, test.expectedPlacements, board.String2D())
+		}
+		if !slices.Equal(bites, test.expectedBites) {
+			t.Errorf("legalMoves(0) with %d bites returned bites %v instead of %v. Board:\n%s",
+				test.bites, bites, test.expectedBites, board.String2D())
+		}
+		if !game.hasLegalMove(0) {
+			t.Errorf("hasLegalMove(0) returned false. Board:\n%s", board.String2D())
+		}
+	}
+
+	// no room and no bites left
+	board[0][1] = 2
+	game.bites[0] = 0
+	if placements, bites := game.legalMoves(0); len(placements) != 0 || len(bites) != 0 {
+		t.Errorf("Expected no legal moves. Got %v and %v. Board:\n%s", placements, bites, board.String2D())
+	}
+	if game.hasLegalMove(0) {
+		t.Errorf("hasLegalMove(0) returned true. Board:\n%s", board.String2D())
+	}
+	if !game.hasLegalMove(1) {
+		t.Errorf("hasLegalMove(1) returned false. Board:\n%s", board.String2D())
+	}
+}
+
 func TestCreateGame(t *testing.T) {
 	var err error
 
@@ -1146,7 +1259,7 @@ func TestGameCaptureCellFromPiece(t *testing.T) {
 	// test horizontal capture
 	pieceOwner = 1
 	pieceIndex = 13
-	piece = Piece{PieceMask(0b10000).generateRotations(), 1}
+	piece = Piece{PieceMask(0b1000000).generateRotations(), 1}
 	board = GameBoard{
 		{0, 0, 0, 0, 0, 0, 0, 0},
 		{0, 0, 1, 2, 2, 1, 0, 0},
@@ -1208,7 +1321,7 @@ func TestGameCaptureCellFromPiece(t *testing.T) {
 	// test double capture, horizontal then diagonal
 	pieceOwner = 1
 	pieceIndex = 13
-	piece = Piece{PieceMask(0b10000).generateRotations(), 1}
+	piece = Piece{PieceMask(0b1000000).generateRotations(), 1}
 	board = GameBoard{
 		{0, 0, 0, 2, 2, 2, 2, 0},
 		{0, 0, 1, 2, 2, 1, 0, 0},
@@ -1271,7 +1384,7 @@ func TestGameCaptureCellFromPiece(t *testing.T) {
 	// test no capture, not adjacent
 	pieceOwner = 1
 	pieceIndex = 9
-	piece = Piece{PieceMask(0b10000).generateRotations(), 1}
+	piece = Piece{PieceMask(0b1000000).generateRotations(), 1}
 	board = GameBoard{
 		{0, 0, 0, 0, 0, 0, 0, 0},
 		{0, 1, 1, 2, 2, 1, 0, 0},
@@ -1330,7 +1443,7 @@ func TestGameCaptureCellFromPiece(t *testing.T) {
 	// test no capture - blank space in the middle
 	pieceOwner = 1
 	pieceIndex = 17
-	piece = Piece{PieceMask(0b10000).generateRotations(), 1}
+	piece = Piece{PieceMask(0b1000000).generateRotations(), 1}
 	board = GameBoard{
 		{0, 0, 0, 0, 0, 0, 0, 0},
 		{1, 1, 0, 0, 0, 0, 0, 0},
@@ -2450,6 +2563,515 @@ func TestForfeitGame(t *testing.T) {
 	}
 }
 
+func TestStalemate(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	lobbyName := "TestStalemate"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": boardSize})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	square := Piece{PieceMask(0b1000000).generateRotations(), 1}
+
+	// setupBoard boxes Player 1 into the top left corner. Player 2 owns every cell in
+	// columns up to p2Cols and the cells around Player 1. Resources are taken away so
+	// that only placing pieces is possible.
+	setupBoard := func(p2Cols int) {
+		game.board = make(GameBoard, boardSize)
+		for r := range game.board {
+			game.board[r] = make([]Cell, boardSize)
+			for c := range game.board[r] {
+				if c < p2Cols {
+					game.board[r][c] = 2
+				}
+			}
+		}
+		game.board[0][0] = 1 | CellFlagHome
+		game.board[0][1] = 2
+		game.board[1][0] = 2
+		game.board[1][1] = 2
+		game.board[2][0] = 2 | CellFlagHome
+		game.updateScores()
+		game.isOver = false
+		game.turn = 1
+		game.nextPiece = square
+		game.newCellsForBitesThreshold = -1
+		game.bites = [maxPlayers]int{}
+		game.rerolls = [maxPlayers]int{}
+	}
+
+	// Player 1 can't move, so their turn is skipped
+	setupBoard(0)
+	err = game.placePiece(p2, game.board.getIndex1D(3, 0), square.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	lastEvent := game.history[len(game.history)-1]
+	if game.isOver || game.turn != 1 || lastEvent.Type != gameEventSkipTurn || !lastEvent.Forced || lastEvent.Player != 0 {
+		t.Errorf("Expected a forced skip for Player 1. isOver=%t turn=%d last event=%+v\n%s",
+			game.isOver, game.turn, lastEvent, game.board.String2D())
+	}
+	if err = game.skipTurn(p1); err == nil {
+		t.Error("Expected Player 1 to not be able to skip on Player 2's turn")
+	}
+
+	// Player 1 gets to reroll if another piece fits, even when the current one doesn't
+	setupBoard(0)
+	game.board[0][1] = 0
+	game.rerolls[0] = 1
+	game.pieces = []Piece{square, {PieceMask(0b1100000).generateRotations(), 1}}
+	err = game.placePiece(p2, game.board.getIndex1D(3, 0), square.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	game.nextPiece = game.pieces[1]
+	game.board[0][2] = 2
+	if !game.canMove(0) {
+		t.Errorf("Expected Player 1 to be able to reroll. rerolls=%v\n%s", game.rerolls, game.board.String2D())
+	}
+	game.rerolls[0] = 0
+	if game.canMove(0) {
+		t.Errorf("Expected Player 1 to not be able to move without rerolls\n%s", game.board.String2D())
+	}
+
+	// the board fills up and Player 2 wins with the highest score
+	setupBoard(boardSize)
+	game.board[boardSize-1][boardSize-1] = 0
+	game.pieces = []Piece{square}
+	record := game.winLossDrawRecord
+	err = game.placePiece(p2, game.board.getIndex1D(boardSize-1, boardSize-1), square.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	lastEvent = game.history[len(game.history)-1]
+	if !game.isOver || game.turn != -1 || lastEvent.Type != gameEventStalemate {
+		t.Errorf("Expected a stalemate. isOver=%t turn=%d last event=%+v\n%s",
+			game.isOver, game.turn, lastEvent, game.board.String2D())
+	}
+	if !slices.Equal(game.winners, []int{1}) || !slices.Equal(game.rankPlayers(), []int{1, 0}) {
+		t.Errorf("Expected Player 2 to win. winners=%v ranking=%v", game.winners, game.rankPlayers())
+	}
+	record[0].L++
+	record[1].W++
+	if game.winLossDrawRecord != record {
+		t.Errorf("Expected winLossDrawRecord %v. Got %v", record, game.winLossDrawRecord)
+	}
+
+	// the board fills up with both players tied, which is a draw
+	setupBoard(0)
+	for r := 0; r < boardSize; r++ {
+		for c := 0; c < boardSize; c++ {
+			if c < boardSize/2 {
+				game.board[r][c] = 1
+			} else {
+				game.board[r][c] = 2
+			}
+		}
+	}
+	game.board[0][0] |= CellFlagHome
+	game.board[boardSize-1][boardSize-2] |= CellFlagHome
+	game.board[boardSize-1][boardSize-1] = 0
+	game.updateScores()
+	game.isOver = false
+	game.turn = 1
+	record = game.winLossDrawRecord
+	err = game.placePiece(p2, game.board.getIndex1D(boardSize-1, boardSize-1), square.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if !game.isOver || !slices.Equal(game.winners, []int{0, 1}) {
+		t.Errorf("Expected a draw. isOver=%t winners=%v scores=%v\n%s",
+			game.isOver, game.winners, game.scores, game.board.String2D())
+	}
+	record[0].D++
+	record[1].D++
+	if game.winLossDrawRecord != record {
+		t.Errorf("Expected winLossDrawRecord %v. Got %v", record, game.winLossDrawRecord)
+	}
+}
+
+func TestRectangularBoard(t *testing.T) {
+	tests := []struct {
+		rows int
+		cols int
+	}{
+		{10, 16}, // wide
+		{16, 10}, // tall
+	}
+
+	for _, test := range tests {
+		lobbyName := fmt.Sprintf("TestRectangularBoard%dx%d", test.rows, test.cols)
+		_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+		_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+		game, err := createGame(activeLobbies[lobbyName], map[string]any{
+			"rows":                 test.rows,
+			"cols":                 test.cols,
+			"has_bonus_bite_cells": true,
+		})
+		if err != nil {
+			t.Fatalf("createGame failed: %v", err)
+		}
+
+		if len(game.board) != test.rows || len(game.board[0]) != test.cols ||
+			game.rowCount != test.rows || game.colCount != test.cols {
+			t.Fatalf("Game board is the wrong size. Expected %d by %d. Got %d by %d",
+				test.rows, test.cols, len(game.board), len(game.board[0]))
+		}
+		maxR, maxC := test.rows-1, test.cols-1
+		offsetR, offsetC := test.rows/gbStartOffsetDivisor, test.cols/gbStartOffsetDivisor
+		if game.board[offsetR][offsetC] != 1|CellFlagHome || game.board[maxR-offsetR][maxC-offsetC] != 2|CellFlagHome {
+			t.Errorf("Starting positions not found. Board is:\n%s", game.board.String2D())
+		}
+		for _, corner := range [][2]int{{0, 0}, {0, maxC}, {maxR, 0}, {maxR, maxC}} {
+			if game.board[corner[0]][corner[1]]&CellFlagBonusBite == 0 {
+				t.Errorf("Bonus bite cell not found at %v. Board is:\n%s", corner, game.board.String2D())
+			}
+		}
+
+		// capture along the last row and the last column with each capture mode
+		for _, captureMode := range []int{gameModeCaptureFromPiece, gameModeCaptureAnywhereCurrentPlayer} {
+			game.captureMode = captureMode
+			for _, line := range [][4]int{
+				{maxR, maxC - 3, 0, 1}, // row, col, row step, col step
+				{maxR - 3, maxC, 1, 0},
+			} {
+				game.board = newGameBoard(test.rows, test.cols)
+				cells := make([]int, 4)
+				for i := range cells {
+					cells[i] = game.board.getIndex1D(line[0]+i*line[2], line[1]+i*line[3])
+				}
+				for _, index := range cells[:3] {
+					r, c := game.board.getIndex2D(index)
+					game.board[r][c] = 2
+				}
+				r, c := game.board.getIndex2D(cells[0])
+				game.board[r][c] = 1 | CellFlagHome
+
+				var captured []int
+				game.addPieceToBoard(1, cells[3], biteSmall)
+				if captureMode == gameModeCaptureFromPiece {
+					captured = game.captureCellsFromPiece(1, cells[3], biteSmall)
+				} else {
+					captured = game.captureCells(1)
+				}
+				sort.Ints(captured)
+				if !slices.Equal(captured, cells[1:3]) {
+					t.Errorf("Expected captures %v on a %dx%d board with capture mode %d. Got %v. Board is:\n%s",
+						cells[1:3], test.rows, test.cols, captureMode, captured, game.board.String2D())
+				}
+				for _, index := range cells {
+					r, c := game.board.getIndex2D(index)
+					if game.board[r][c]&CellMaskPlayer != 1 {
+						t.Errorf("Expected cell %d to be owned by Player 1. Board is:\n%s", index, game.board.String2D())
+					}
+				}
+			}
+		}
+
+		// resetting keeps the board shape
+		game.resetGame()
+		if len(game.board) != test.rows || len(game.board[0]) != test.cols {
+			t.Errorf("resetGame changed the board size to %d by %d", len(game.board), len(game.board[0]))
+		}
+	}
+}
+
+func TestWalls(t *testing.T) {
+	var boardSize int = 20
+	var err error
+
+	lobbyName := "TestWalls"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p3", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p4", "")
+
+	// invalid options
+	for _, opts := range []map[string]any{
+		{"walls": gameWallsMax},
+		{"walls": gameWallsRandom, "wall_density": gbMaxWallDensity + 0.1},
+	} {
+		if _, err = createGame(activeLobbies[lobbyName], opts); err == nil {
+			t.Errorf("createGame(%v) should have failed", opts)
+		}
+	}
+
+	for _, wallMode := range []int{gameWallsRandom, gameWallsSymmetric} {
+		game, err := createGame(activeLobbies[lobbyName], map[string]any{
+			"size":         boardSize,
+			"walls":        wallMode,
+			"wall_density": 0.2,
+			"seed":         uint64(1),
+		})
+		if err != nil {
+			t.Fatalf("createGame failed: %v", err)
+		}
+		board := game.board
+
+		walls := 0
+		for r := range board {
+			for c := range board[r] {
+				if board[r][c]&CellFlagWall == 0 {
+					continue
+				}
+				walls++
+				if board[r][c] != CellFlagWall {
+					t.Errorf("Expected only the wall flag at board[%d][%d]. Got 0x%04x", r, c, board[r][c])
+				}
+				for hr := max(r-gbWallHomeClearance, 0); hr <= min(r+gbWallHomeClearance, boardSize-1); hr++ {
+					for hc := max(c-gbWallHomeClearance, 0); hc <= min(c+gbWallHomeClearance, boardSize-1); hc++ {
+						if board[hr][hc]&CellFlagHome != 0 {
+							t.Errorf("Wall at board[%d][%d] is too close to the home cell at board[%d][%d]", r, c, hr, hc)
+						}
+					}
+				}
+				if wallMode == gameWallsSymmetric && (board[boardSize-1-r][c]&CellFlagWall == 0 ||
+					board[r][boardSize-1-c]&CellFlagWall == 0 || board[boardSize-1-r][boardSize-1-c]&CellFlagWall == 0) {
+					t.Errorf("Wall at board[%d][%d] is not mirrored", r, c)
+				}
+			}
+		}
+		if walls == 0 {
+			t.Errorf("Expected walls with wall mode %d. Board is:\n%s", wallMode, board.String2D())
+		}
+		if !areHomesConnected(board) {
+			t.Errorf("Expected home cells to be connected. Board is:\n%s", board.String2D())
+		}
+
+		// walls are kept when the game is reset
+		game.resetGame()
+		if !strings.Contains(game.board.String2D(), "#") {
+			t.Errorf("Expected walls after resetGame. Board is:\n%s", game.board.String2D())
+		}
+	}
+
+	// walls separating the home cells are detected
+	board := newGameBoard(10, 10)
+	board[2][2] = 1 | CellFlagHome
+	board[7][7] = 2 | CellFlagHome
+	for r := range board {
+		board[r][5] = CellFlagWall
+	}
+	if areHomesConnected(board) {
+		t.Errorf("Expected home cells to not be connected. Board is:\n%s", board.String2D())
+	}
+	if canPlaceWall(board, 3, 3) || canPlaceWall(board, 7, 7) || !canPlaceWall(board, 5, 0) {
+		t.Errorf("canPlaceWall did not keep walls away from home cells. Board is:\n%s", board.String2D())
+	}
+
+	// pieces can't be placed on walls, bites don't clear them, and captures stop at them
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 10})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	board = GameBoard{
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 1, 1, 2, 0, 1, 0, 0, 0, 0},
+		{0, 1, 1, 2, 2, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 2, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 2, 2, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	board[2][2] |= CellFlagHome
+	board[4][5] |= CellFlagHome
+	board[1][4] = CellFlagWall
+	board[1][5] = 0
+	board[3][3] = CellFlagWall
+	game.board = board
+	game.turn = 0
+	game.bites[0] = biteLarge.CalcBiteCost()
+
+	if game.isPieceOnFreeSpace(board.getIndex1D(1, 4), biteSmall) {
+		t.Errorf("Expected a wall to not be free space. Board is:\n%s", board.String2D())
+	}
+	if hadCapture, _ := game.scanForCapture(1, board.getIndex1D(1, 2), directionRight); hadCapture {
+		t.Errorf("Expected capture scan to stop at a wall. Board is:\n%s", board.String2D())
+	}
+	err = game.placePiece(p1, board.getIndex1D(1, 4), biteSmall)
+	if err == nil || err.Error() != "Invalid update: piece overlaps occupied space" {
+		t.Errorf("Expected placing a piece on a wall to fail. Got %v", err)
+	}
+	err = game.placeBite(p1, board.getIndex1D(2, 3), biteLarge)
+	if err != nil {
+		t.Fatalf("placeBite failed: %v\n%s", err, board.String2D())
+	}
+	if game.board[3][3] != CellFlagWall || game.board[2][3] != 0 || game.board[2][4] != 0 {
+		t.Errorf("Expected the bite to clear cells around the wall. Board is:\n%s", game.board.String2D())
+	}
+}
+
+func TestGameHistory(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	// create game
+	lobbyName := "TestGameHistory"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	p2 := joinLobbyWrapper(t, lobbyName, "p2", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":                 boardSize,
+		"has_bonus_bite_cells": false,
+		"bonus_reroll_cells":   0,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+
+	if len(game.history) != 1 || game.history[0].Type != gameEventStartGame {
+		t.Fatalf("Expected a single start_game event after createGame. Got: %+v", game.history)
+	}
+	if game.history[0].Board.String2D() != game.board.String2D() {
+		t.Errorf("start_game event board does not match the game board.\nExpected:\n%s\nGot:\n%s",
+			game.board.String2D(), game.history[0].Board.String2D())
+	}
+
+	// player 1 places a single square next to their home cell
+	game.nextPiece = Piece{PieceMask(0b1000000).generateRotations(), 1}
+	homeIndex := game.board.getIndex1D(boardSize/gbStartOffsetDivisor, boardSize/gbStartOffsetDivisor)
+	err = game.placePiece(p1, homeIndex+1, game.nextPiece.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+
+	// player 2 rerolls and then skips
+	err = game.reroll(p2)
+	if err != nil {
+		t.Fatalf("reroll failed: %v", err)
+	}
+	err = game.skipTurn(p2)
+	if err != nil {
+		t.Fatalf("skipTurn failed: %v", err)
+	}
+
+	// player 2 forfeits, then the game gets reset
+	err = game.forfeitGame(p2)
+	if err != nil {
+		t.Fatalf("forfeitGame failed: %v", err)
+	}
+	game.resetGame()
+
+	expected := []struct {
+		eventType string
+		player    int
+		index     int
+		before    GameResources
+		after     GameResources
+	}{
+		{gameEventStartGame, -1, -1, GameResources{}, GameResources{}},
+		{gameEventPlacePiece, 0, homeIndex + 1,
+			GameResources{Score: 1, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls},
+			GameResources{Score: 2, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls, NewCellsForBites: 1},
+		},
+		{gameEventReroll, 1, -1,
+			GameResources{Score: 1, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls},
+			GameResources{Score: 1, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls - 1},
+		},
+		{gameEventSkipTurn, 1, -1,
+			GameResources{Score: 1, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls - 1},
+			GameResources{Score: 1, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls - 1},
+		},
+		{gameEventForfeitGame, 1, -1,
+			GameResources{Score: 1, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls - 1},
+			GameResources{Score: 0, Bites: gbDefaultStartBites, Rerolls: gbDefaultStartRerolls - 1},
+		},
+		{gameEventResetGame, -1, -1, GameResources{}, GameResources{}},
+	}
+	if len(game.history) != len(expected) {
+		t.Fatalf("Expected %d events. Got %d: %+v", len(expected), len(game.history), game.history)
+	}
+	for i, e := range expected {
+		event := game.history[i]
+		if event.Type != e.eventType || event.Player != e.player || event.Index != e.index {
+			t.Errorf("Event %d: expected type=%s player=%d index=%d. Got type=%s player=%d index=%d.",
+				i, e.eventType, e.player, e.index, event.Type, event.Player, event.Index)
+		}
+		if event.Before != e.before || event.After != e.after {
+			t.Errorf("Event %d (%s): expected resources %+v -> %+v. Got %+v -> %+v.",
+				i, event.Type, e.before, e.after, event.Before, event.After)
+		}
+	}
+
+	// the forfeit removed player 2's home cell
+	forfeit := game.history[4]
+	if len(forfeit.Orphaned) != 1 {
+		t.Errorf("Expected the forfeit event to remove 1 cell. Got: %v", forfeit.Orphaned)
+	}
+}
+
+func TestGameSeed(t *testing.T) {
+	var seed uint64 = 12345
+	opts := map[string]any{
+		"size":                      20,
+		"randomize_start_positions": true,
+		"bonus_reroll_cells":        10,
+		"seed":                      seed,
+	}
+
+	// create two games with the same seed
+	lobbyName := "TestGameSeed"
+	joinLobbyWrapper(t, lobbyName, "p1", "")
+	joinLobbyWrapper(t, lobbyName, "p2", "")
+	joinLobbyWrapper(t, lobbyName, "p3", "")
+	game1, err := createGame(activeLobbies[lobbyName], opts)
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game2, err := createGame(activeLobbies[lobbyName], opts)
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+
+	if game1.seed != seed || game2.seed != seed {
+		t.Fatalf("Expected seed %d. Got %d and %d", seed, game1.seed, game2.seed)
+	}
+	if !strings.Contains(game1.String(), fmt.Sprintf("seed: %d\n", seed)) {
+		t.Errorf("Expected the seed in game.String(). Got:\n%s", game1.String())
+	}
+
+	// the games should stay identical through several pieces and a reset
+	initialBoard := game1.board.String2D()
+	for i := 0; i < 3; i++ {
+		if game1.board.String2D() != game2.board.String2D() {
+			t.Fatalf("Boards differ for the same seed.\nGame 1:\n%s\nGame 2:\n%s",
+				game1.board.String2D(), game2.board.String2D())
+		}
+		for j := 0; j < 20; j++ {
+			if game1.nextPiece != game2.nextPiece {
+				t.Fatalf("nextPiece differs for the same seed: %v and %v", game1.nextPiece, game2.nextPiece)
+			}
+			game1.setNextPiece()
+			game2.setNextPiece()
+		}
+		game1.resetGame()
+		game2.resetGame()
+	}
+
+	// a different seed should give a different game
+	opts["seed"] = seed + 1
+	game3, err := createGame(activeLobbies[lobbyName], opts)
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game4, err := createGame(activeLobbies[lobbyName], opts)
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game3.board.String2D() == initialBoard {
+		t.Errorf("Expected different boards for different seeds.\n%s", game3.board.String2D())
+	}
+	if game3.board.String2D() != game4.board.String2D() {
+		t.Errorf("Boards differ for the same seed.\nGame 3:\n%s\nGame 4:\n%s",
+			game3.board.String2D(), game4.board.String2D())
+	}
+}
+
 func BenchmarkGameHandleOrphanedCells(b *testing.B) {
 	var boardSize int = 128
 	var updates []int
@@ -2602,3 +3224,241 @@ func BenchmarkGameHandleOrphanedCells(b *testing.B) {
 		}
 	}
 }
+
+func TestTeams(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	lobbyName := "TestTeams"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p3", "")
+
+	// teams need four players
+	if _, err = createGame(activeLobbies[lobbyName], map[string]any{"teams": true}); err == nil {
+		t.Error("Expected createGame to fail with teams and three players")
+	}
+	_ = joinLobbyWrapper(t, lobbyName, "p4", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":  boardSize,
+		"teams": true,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game.teamOf(0) != game.teamOf(2) || game.teamOf(1) != game.teamOf(3) || game.teamOf(0) == game.teamOf(1) {
+		t.Errorf("Expected players 1 and 3 against players 2 and 4. Got teams %d %d %d %d",
+			game.teamOf(0), game.teamOf(1), game.teamOf(2), game.teamOf(3))
+	}
+	if game.teamSharedPaths {
+		t.Error("Expected teamSharedPaths to be off by default")
+	}
+	square := maskAt(0, 0)
+
+	// Player 1 places at (0,1). The line of Player 2's cells to the right ends at
+	// Player 3's home and gets captured. Player 3's other home below is not captured
+	// even though Player 1 owns the cell past it.
+	game.board = make(GameBoard, boardSize)
+	for r := range game.board {
+		game.board[r] = make([]Cell, boardSize)
+	}
+	game.board[0][0] = 1 | CellFlagHome
+	game.board[1][0] = 1
+	game.board[2][0] = 1
+	game.board[2][1] = 1
+	game.board[1][1] = 3 | CellFlagHome
+	game.board[0][2] = 2
+	game.board[0][3] = 2
+	game.board[1][3] = 2 | CellFlagHome
+	game.board[0][4] = 3 | CellFlagHome
+	game.board[9][9] = 4 | CellFlagHome
+	game.updateScores()
+	game.turn = 0
+	game.nextPiece = Piece{square.generateRotations(), 1}
+
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 1), square); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.board[0][2] != 1 || game.board[0][3] != 1 {
+		t.Errorf("Expected Player 2's cells to be captured up to Player 3's home\n%s", game.board.String2D())
+	}
+	if game.board[1][1] != 3|CellFlagHome || game.board[0][4] != 3|CellFlagHome {
+		t.Errorf("Expected Player 3's cells to not be captured by a teammate\n%s", game.board.String2D())
+	}
+
+	// Player 1's cell at (0,2) only reaches home through Player 3's cell
+	game.board = make(GameBoard, boardSize)
+	for r := range game.board {
+		game.board[r] = make([]Cell, boardSize)
+	}
+	game.board[0][0] = 1 | CellFlagHome
+	game.board[0][1] = 3
+	game.board[0][2] = 1
+	game.board[1][1] = 3 | CellFlagHome
+	for _, sharedPaths := range []bool{true, false} {
+		game.teamSharedPaths = sharedPaths
+		orphaned := game.handleOrphanedCells()
+		if sharedPaths == (len(orphaned) != 0) {
+			t.Errorf("teamSharedPaths=%t: unexpected orphaned cells %v\n%s", sharedPaths, orphaned, game.board.String2D())
+		}
+	}
+
+	// a team wins once the other team has no cells left, even if one teammate is out
+	game.winLossDrawRecord = [maxPlayers]WinLossDraw{}
+	game.board = make(GameBoard, boardSize)
+	for r := range game.board {
+		game.board[r] = make([]Cell, boardSize)
+	}
+	game.board[0][0] = 3 | CellFlagHome
+	game.updateScores()
+	if !game.isOver || !slices.Equal(game.winners, []int{0, 2}) {
+		t.Errorf("Expected a win for players 1 and 3. isOver=%t winners=%v", game.isOver, game.winners)
+	}
+	expectedRecord := [maxPlayers]WinLossDraw{{W: 1}, {L: 1}, {W: 1}, {L: 1}}
+	if game.winLossDrawRecord != expectedRecord {
+		t.Errorf("Expected records %v. Got %v", expectedRecord, game.winLossDrawRecord)
+	}
+
+	// a stalemate goes to the team with the most cells
+	game.winLossDrawRecord = [maxPlayers]WinLossDraw{}
+	game.isOver = false
+	game.scores = [maxPlayers]int{1, 5, 1, 1}
+	game.endStalemate()
+	if !slices.Equal(game.winners, []int{1, 3}) || game.winLossDrawRecord[3].W != 1 || game.winLossDrawRecord[0].L != 1 {
+		t.Errorf("Expected a win for players 2 and 4. winners=%v records=%v", game.winners, game.winLossDrawRecord)
+	}
+
+	// tied teams draw
+	game.winLossDrawRecord = [maxPlayers]WinLossDraw{}
+	game.isOver = false
+	game.scores = [maxPlayers]int{3, 2, 1, 2}
+	game.endStalemate()
+	if len(game.winners) != 4 || game.winLossDrawRecord[0].D != 1 || game.winLossDrawRecord[1].D != 1 {
+		t.Errorf("Expected a draw between the teams. winners=%v records=%v", game.winners, game.winLossDrawRecord)
+	}
+}
+
+func TestManyPlayers(t *testing.T) {
+	lobbyName := "TestManyPlayers"
+	for i := 0; i < maxPlayers; i++ {
+		_ = joinLobbyWrapper(t, lobbyName, "", "")
+	}
+
+	// more than gbCornerPlayers players need a larger board
+	_, err := createGame(activeLobbies[lobbyName], map[string]any{"size": gbMinSizeManyPlayers - 1})
+	if err == nil {
+		t.Errorf("Expected createGame to fail for %d players on a small board", maxPlayers)
+	}
+
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":  gbMinSizeManyPlayers,
+		"teams": true,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if game.playerCount != maxPlayers {
+		t.Fatalf("Expected %d players. Got %d", maxPlayers, game.playerCount)
+	}
+
+	// every player has their own home cell
+	homes := map[Cell]int{}
+	for r := range game.board {
+		for c := range game.board[r] {
+			if game.board[r][c]&CellFlagHome != 0 {
+				homes[game.board[r][c]&CellMaskPlayer] = game.board.getIndex1D(r, c)
+			}
+		}
+	}
+	if len(homes) != maxPlayers {
+		t.Errorf("Expected %d home cells. Got %v\n%s", maxPlayers, homes, game.board.String2D())
+	}
+	for i := 0; i < maxPlayers; i++ {
+		if game.scores[i] != 1 {
+			t.Errorf("Expected a score of 1 for player %d. Got %v", i+1, game.scores)
+		}
+	}
+
+	// turns go through every player
+	for i := 0; i < maxPlayers; i++ {
+		if game.turn != i {
+			t.Fatalf("Expected turn %d. Got %d", i, game.turn)
+		}
+		if err = game.skipTurn(game.players[i]); err != nil {
+			t.Fatalf("skipTurn failed for player %d: %v", i+1, err)
+		}
+	}
+	if game.turn != 0 {
+		t.Errorf("Expected the turn to wrap around to player 1. Got %d", game.turn)
+	}
+
+	// the last player's default name is reserved
+	if !lobbyMemberReservedNameRegex.MatchString(fmt.Sprintf("Player %d", maxPlayers)) {
+		t.Errorf("Expected Player %d to be a reserved name", maxPlayers)
+	}
+}
+
+func TestMirrorPieces(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	lBlock := Piece{PieceMask(0b1000000_1000000_1100000).generateRotations(), 8}
+	reverseLBlock := Piece{PieceMask(0b1110000_0010000_0000000).generateRotations(), 8}
+	square := Piece{PieceMask(0b1100000_1100000).generateRotations(), 100}
+	flippedL := PieceMask(0b0100000_0100000_1100000_0000000_0000000_0000000_0000000)
+
+	lobbyName := "TestMirrorPieces"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+
+	// mirror images are merged into a single piece
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":          boardSize,
+		"pieces":        []Piece{lBlock, reverseLBlock, square},
+		"mirror_pieces": true,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	if len(game.pieces) != 2 {
+		t.Fatalf("Expected the l blocks to be merged into one piece. Got %d pieces", len(game.pieces))
+	}
+	if !game.pieces[0].has(reverseLBlock.Masks[0]) || !game.pieces[0].has(flippedL) || game.pieces[0].Weight != 16 {
+		t.Errorf("Expected an l block with both mirror images and weight 16. Got %v", game.pieces[0])
+	}
+	if game.pieces[1].Masks[maxPieceRotations] != 0 {
+		t.Errorf("Expected the square to have no mirror image. Got %v", game.pieces[1])
+	}
+
+	// a flipped piece can be placed
+	game.board = newGameBoard(boardSize, boardSize)
+	game.board[0][2] = 1 | CellFlagHome
+	game.board[9][9] = 2 | CellFlagHome
+	game.updateScores()
+	game.turn = 0
+	game.nextPiece = game.pieces[0]
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 0), flippedL); err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	if game.board[2][0] != 1 || game.board[2][1] != 1 {
+		t.Errorf("Expected the flipped l block on the board\n%s", game.board.String2D())
+	}
+
+	// without the option, mirror images are different pieces
+	game, err = createGame(activeLobbies[lobbyName], map[string]any{
+		"size":   boardSize,
+		"pieces": []Piece{lBlock, reverseLBlock, square},
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	game.board = newGameBoard(boardSize, boardSize)
+	game.board[0][2] = 1 | CellFlagHome
+	game.board[9][9] = 2 | CellFlagHome
+	game.updateScores()
+	game.turn = 0
+	game.nextPiece = lBlock
+	if err = game.placePiece(p1, game.board.getIndex1D(0, 0), flippedL); err == nil {
+		t.Errorf("Expected a flipped piece to be rejected without mirror_pieces\n%s", game.board.String2D())
+	}
+}
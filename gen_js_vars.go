@@ -29,40 +29,71 @@ func main() {
 	fmt.Fprintf(f, "const cellFlagHome = 0x%04x;\n", CellFlagHome)
 	fmt.Fprintf(f, "const cellFlagBonusBite = 0x%04x;\n", CellFlagBonusBite)
 	fmt.Fprintf(f, "const cellFlagBonusReroll = 0x%04x;\n", CellFlagBonusReroll)
+	fmt.Fprintf(f, "const cellFlagWall = 0x%04x;\n", CellFlagWall)
+	fmt.Fprintf(f, "const cellFlagHidden = 0x%04x;\n", CellFlagHidden)
+	fmt.Fprintf(f, "const cellFlagShield = 0x%04x;\n", CellFlagShield)
+	fmt.Fprintf(f, "const cellFlagMultiplier = 0x%04x;\n", CellFlagMultiplier)
+	fmt.Fprintf(f, "const cellFlagSpore = 0x%04x;\n", CellFlagSpore)
 	fmt.Fprintf(f, "const cellMaskPlayer = 0x%04x;\n", CellMaskPlayer)
 	fmt.Fprintf(f, "const cellMaskFlags = 0x%04x;\n", CellMaskFlags)
 	fmt.Fprintln(f)
 	fmt.Fprintf(f, "const pieceMaskMaxLength = %d;\n", pieceMaskMaxLength)
+	fmt.Fprintf(f, "const legacyPieceMaskLength = %d;\n", legacyPieceMaskLength)
 	fmt.Fprintf(f, "const pieceMaskSectionMask = %s;\n", pieceMaskSectionMask)
 	fmt.Fprintf(f, "const pieceMaskFirstRowMask = %s;\n", pieceMaskFirstRowMask)
 	fmt.Fprintf(f, "const pieceMaskFirstColumnMask = %s;\n", pieceMaskFirstColumnMask)
 	fmt.Fprintf(f, "const maxPieceRotations = %d;\n", maxPieceRotations)
 	fmt.Fprintln(f)
-	fmt.Fprintf(f, "const biteNameToMask = {\n")
-	fmt.Fprintf(f, "  \"noBite\": %d,\n", biteNone)
-	fmt.Fprintf(f, "  \"smallBite\": %s,\n", biteSmall)
-	fmt.Fprintf(f, "  \"largeBite\": %s\n", biteLarge)
-	fmt.Fprintln(f, "};")
 	fmt.Fprintf(f, "const biteMaskToName = {\n")
-	fmt.Fprintf(f, "  %d: \"noBite\",\n", biteNone)
-	fmt.Fprintf(f, "  %s: \"smallBite\",\n", biteSmall)
-	fmt.Fprintf(f, "  %s: \"largeBite\"\n", biteLarge)
-	fmt.Fprintln(f, "};")
-	fmt.Fprintf(f, "const biteNameToCost = {\n")
-	fmt.Fprintf(f, "  \"noBite\": %d,\n", biteNone.CalcBiteCost())
-	fmt.Fprintf(f, "  \"smallBite\": %d,\n", biteSmall.CalcBiteCost())
-	fmt.Fprintf(f, "  \"largeBite\": %d\n", biteLarge.CalcBiteCost())
+	fmt.Fprintf(f, "  %s: \"Bite\",\n", biteSmall)
+	fmt.Fprintf(f, "  %s: \"Large Bite\"\n", biteLarge)
 	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gbMaxBiteShapes = %d;\n", gbMaxBiteShapes)
 	fmt.Fprintln(f)
 	fmt.Fprintf(f, "const gbDefaultSize = %d;\n", gbDefaultSize)
+	fmt.Fprintf(f, "const gbDefaultRows = %d;\n", gbDefaultRows)
+	fmt.Fprintf(f, "const gbDefaultCols = %d;\n", gbDefaultCols)
 	fmt.Fprintf(f, "const gbMinSize = %d;\n", max(gbMinSize, 10))
 	fmt.Fprintf(f, "const gbMaxSize = %d;\n", min(gbMaxSize, 50))
 	fmt.Fprintf(f, "const gbDefaultRandomizeStartPos = %t;\n", gbDefaultRandomizeStartPos)
 	fmt.Fprintf(f, "const gbDefaultStartBites = %d;\n", gbDefaultStartBites)
 	fmt.Fprintf(f, "const gbDefaultStartRerolls = %d;\n", gbDefaultStartRerolls)
-	fmt.Fprintf(f, "const gbDefaultHasBonusBiteCells = %t;\n", gbDefaultHasBonusBiteCells)
+	fmt.Fprintf(f, "const gbDefaultBonusBiteCells = %d;\n", gbDefaultBonusBiteCells)
 	fmt.Fprintf(f, "const gbDefaultBonusRerollCells = %d;\n", gbDefaultBonusRerollCells)
+	fmt.Fprintf(f, "const gbDefaultBonusBiteAward = %d;\n", gbDefaultBonusBiteAward)
+	fmt.Fprintf(f, "const gbDefaultBonusRerollAward = %d;\n", gbDefaultBonusRerollAward)
+	fmt.Fprintf(f, "const gbDefaultBonusHomeClearance = %d;\n", gbDefaultBonusHomeClearance)
+	fmt.Fprintf(f, "const gbDefaultShieldCells = %d;\n", gbDefaultShieldCells)
+	fmt.Fprintf(f, "const gbDefaultMultiplierCells = %d;\n", gbDefaultMultiplierCells)
+	fmt.Fprintf(f, "const gbDefaultSporeCells = %d;\n", gbDefaultSporeCells)
 	fmt.Fprintf(f, "const gbDefaultNewBiteFreqFactor = %f;\n", gbDefaultNewBiteFreqFactor)
+	fmt.Fprintf(f, "const gbDefaultWallDensity = %f;\n", gbDefaultWallDensity)
+	fmt.Fprintf(f, "const gbMaxWallDensity = %f;\n", gbMaxWallDensity)
+	fmt.Fprintf(f, "const gbDefaultTurnTime = %d;\n", int(gbDefaultTurnTime.Seconds()))
+	fmt.Fprintf(f, "const gbMinTurnTime = %d;\n", int(gbMinTurnTime.Seconds()))
+	fmt.Fprintf(f, "const gbMaxTurnTime = %d;\n", int(gbMaxTurnTime.Seconds()))
+	fmt.Fprintf(f, "const gbDefaultClockTime = %d;\n", int(gbDefaultClockTime.Seconds()))
+	fmt.Fprintf(f, "const gbMaxClockTime = %d;\n", int(gbMaxClockTime.Seconds()))
+	fmt.Fprintf(f, "const gbDefaultClockIncrement = %d;\n", int(gbDefaultClockIncrement.Seconds()))
+	fmt.Fprintf(f, "const gbMaxClockIncrement = %d;\n", int(gbMaxClockIncrement.Seconds()))
+	fmt.Fprintf(f, "const gbDefaultFogOfWar = %d;\n", gbDefaultFogOfWar)
+	fmt.Fprintf(f, "const gbMaxFogOfWar = %d;\n", gbMaxFogOfWar)
+	fmt.Fprintf(f, "const gbDefaultPieceQueueLength = %d;\n", gbDefaultPieceQueueLength)
+	fmt.Fprintf(f, "const gbMaxPieceQueueLength = %d;\n", gbMaxPieceQueueLength)
+	fmt.Fprintf(f, "const gbDefaultAllowHold = %t;\n", gbDefaultAllowHold)
+	fmt.Fprintf(f, "const gbDefaultDraftSize = %d;\n", gbDefaultDraftSize)
+	fmt.Fprintf(f, "const gbMaxDraftSize = %d;\n", gbMaxDraftSize)
+	fmt.Fprintf(f, "const gbDefaultCaptureDiagonals = %t;\n", gbDefaultCaptureDiagonals)
+	fmt.Fprintf(f, "const gbDefaultCaptureMinLength = %d;\n", gbDefaultCaptureMinLength)
+	fmt.Fprintf(f, "const gbDefaultCaptureMaxLength = %d;\n", gbDefaultCaptureMaxLength)
+	fmt.Fprintf(f, "const gbMaxCaptureLength = %d;\n", gbMaxCaptureLength)
+	fmt.Fprintf(f, "const gbDefaultCaptureChains = %t;\n", gbDefaultCaptureChains)
+	fmt.Fprintf(f, "const gbDefaultCaptureHomeCells = %t;\n", gbDefaultCaptureHomeCells)
+	fmt.Fprintf(f, "const gbDefaultCaptureThroughHomeCells = %t;\n", gbDefaultCaptureThroughHomeCells)
+	fmt.Fprintf(f, "const gbDefaultWinTerritory = %d;\n", gbDefaultWinTerritory)
+	fmt.Fprintf(f, "const gbDefaultWinScore = %d;\n", gbDefaultWinScore)
+	fmt.Fprintf(f, "const gbDefaultMaxRounds = %d;\n", gbDefaultMaxRounds)
+	fmt.Fprintf(f, "const gbMaxRounds = %d;\n", gbMaxRounds)
 
 	fmt.Fprintln(f, "const gbDefaultPieces = [")
 	for i, piece := range gbDefaultPieces {
@@ -72,6 +103,14 @@ func main() {
 		fmt.Fprintf(f, `  {"mask": %v, "weight": %f}`, piece.Masks[0], piece.Weight)
 	}
 	fmt.Fprintln(f, "\n];")
+	fmt.Fprintln(f, "const gbDefaultBites = [")
+	for i, bite := range gbDefaultBites {
+		if i > 0 {
+			fmt.Fprint(f, ",\n")
+		}
+		fmt.Fprintf(f, `  {"mask": %v, "cost": %d}`, bite.Mask, bite.Cost)
+	}
+	fmt.Fprintln(f, "\n];")
 
 	fmt.Fprintln(f)
 	fmt.Fprintf(f, "const gameCaptureModes = {\n")
@@ -79,6 +118,42 @@ func main() {
 	fmt.Fprintf(f, "  \"Whole board current player only\": %d,\n", gameModeCaptureAnywhereCurrentPlayer)
 	fmt.Fprintf(f, "  \"Whole board all players\": %d\n", gameModeCaptureAnywhereAllPlayers)
 	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gameWallModes = {\n")
+	fmt.Fprintf(f, "  \"No walls\": %d,\n", gameWallsNone)
+	fmt.Fprintf(f, "  \"Random\": %d,\n", gameWallsRandom)
+	fmt.Fprintf(f, "  \"Symmetric\": %d\n", gameWallsSymmetric)
+	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gameTimerModes = {\n")
+	fmt.Fprintf(f, "  \"No timer\": %d,\n", gameTimerNone)
+	fmt.Fprintf(f, "  \"Time limit per turn\": %d,\n", gameTimerPerTurn)
+	fmt.Fprintf(f, "  \"Chess clock\": %d\n", gameTimerClock)
+	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gameTimeoutActions = {\n")
+	fmt.Fprintf(f, "  \"Skip turn\": %d,\n", gameTimeoutSkip)
+	fmt.Fprintf(f, "  \"Forfeit\": %d\n", gameTimeoutForfeit)
+	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gamePieceDistributions = {\n")
+	fmt.Fprintf(f, "  \"Random\": %d,\n", gamePiecesRandom)
+	fmt.Fprintf(f, "  \"Bag\": %d\n", gamePiecesBag)
+	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gameTieBreaks = {\n")
+	fmt.Fprintf(f, "  \"Draw\": %d,\n", gameTieBreakDraw)
+	fmt.Fprintf(f, "  \"Most bites, then rerolls left\": %d\n", gameTieBreakResources)
+	fmt.Fprintln(f, "};")
+	fmt.Fprintf(f, "const gameBonusPlacements = {\n")
+	fmt.Fprintf(f, "  \"Bites in corners, others random\": %d,\n", gameBonusCorners)
+	fmt.Fprintf(f, "  \"Random\": %d,\n", gameBonusRandom)
+	fmt.Fprintf(f, "  \"Mirrored\": %d\n", gameBonusSymmetric)
+	fmt.Fprintln(f, "};")
+	fmt.Fprintln(f)
+	fmt.Fprintf(f, "const botLevels = [")
+	for i, level := range botLevels {
+		if i > 0 {
+			fmt.Fprint(f, ", ")
+		}
+		fmt.Fprintf(f, "%q", level)
+	}
+	fmt.Fprintln(f, "];")
 	fmt.Fprintln(f)
 
 	f.Close()
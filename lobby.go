@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -17,9 +18,29 @@ const lobbyMemberIdleTimeout time.Duration = time.Duration(15) * time.Minute
 // Lobby represents a group of players waiting to start a game.
 // Once gameId is set, the lobby is closed.
 type Lobby struct {
-	player [maxPlayers]Player
-	name   string
-	gameId uuid.UUID
+	player    [maxPlayers]Player
+	name      string
+	gameId    uuid.UUID
+	playerCap int // most members allowed, maxPlayers if 0. See setLobbyPlayerCap.
+}
+
+// playerLimit returns the most members allowed in the lobby
+func (l *Lobby) playerLimit() int {
+	if l.playerCap == 0 {
+		return maxPlayers
+	}
+	return l.playerCap
+}
+
+// memberCount returns the number of members in the lobby
+func (l *Lobby) memberCount() int {
+	count := 0
+	for i := 0; i < len(l.player); i++ {
+		if !l.player[i].lastSeen.IsZero() {
+			count++
+		}
+	}
+	return count
 }
 
 func (l *Lobby) String() string {
@@ -44,12 +65,20 @@ var activeLobbies = map[string]*Lobby{}
 var lobbyMutex sync.Mutex
 
 var lobbyAllowedNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
-var lobbyMemberReservedNameRegex = regexp.MustCompile(`(?i)^player\s*[1234]$`)
+var lobbyMemberReservedNameRegex = regexp.MustCompile(fmt.Sprintf(`(?i)^player\s*[1-%d]$`, maxPlayers))
 
 var reservedColors []*RGB = []*RGB{
 	{[3]uint8{0x11, 0x11, 0x11}}, // common.css body background-color
 }
 
+// randomizeMemberColor sets c to a random color that is not a near duplicate of avoid or
+// the reserved colors. Falls back to a smaller tolerance if the lobby is too crowded.
+func randomizeMemberColor(c *RGB, avoid []*RGB) {
+	if c.RandomizeAvoidingDuplicates(defaultRGBTolerance, avoid, reservedColors) != nil {
+		c.RandomizeAvoidingDuplicates(fullLobbyRGBTolerance, avoid, reservedColors)
+	}
+}
+
 // joinLobby adds player `pname` to lobby `lname`. Lobby is created if it doesn't exist.
 // If `pcolor` is an empty string, a random color will be generated.
 // Errors if lobby is full, or if the player or color name is in use by another member.
@@ -75,7 +104,7 @@ func joinLobby(lname string, pname string, pcolor string) (Player, error) {
 				break
 			}
 		}
-		if freeSlot < 0 {
+		if freeSlot < 0 || lobby.memberCount() >= lobby.playerLimit() {
 			return Player{}, errors.New("Lobby is full")
 		}
 	} else {
@@ -120,7 +149,7 @@ func joinLobby(lname string, pname string, pcolor string) (Player, error) {
 		avoidRGBPlayer = append(avoidRGBPlayer, &lobby.player[i].Color)
 	}
 	if pcolor == "" {
-		pcolorRGB.RandomizeAvoidingDuplicates(defaultRGBTolerance, avoidRGBPlayer, reservedColors)
+		randomizeMemberColor(&pcolorRGB, avoidRGBPlayer)
 	}
 
 	// create user
@@ -129,6 +158,97 @@ func joinLobby(lname string, pname string, pcolor string) (Player, error) {
 	return lobby.player[freeSlot], nil
 }
 
+// addBotToLobby adds a bot with the given level to an existing lobby `lname`.
+// The bot is named after its slot and gets a random color.
+func addBotToLobby(lname string, level string) (Player, error) {
+	if !slices.Contains(botLevels, level) {
+		return Player{}, errors.New("Invalid bot level: " + level)
+	}
+
+	lobbyMutex.Lock()
+	defer lobbyMutex.Unlock()
+
+	lobby, ok := activeLobbies[lname]
+	if !ok {
+		return Player{}, errors.New("Lobby does not exist")
+	}
+	if lobby.gameId != uuid.Nil {
+		return Player{}, errors.New("Game has already started")
+	}
+
+	freeSlot := -1
+	avoidRGBPlayer := make([]*RGB, 0, maxPlayers)
+	for i := 0; i < len(lobby.player); i++ {
+		if lobby.player[i].lastSeen.IsZero() {
+			if freeSlot < 0 {
+				freeSlot = i
+			}
+			continue
+		}
+		avoidRGBPlayer = append(avoidRGBPlayer, &lobby.player[i].Color)
+	}
+	if freeSlot < 0 || lobby.memberCount() >= lobby.playerLimit() {
+		return Player{}, errors.New("Lobby is full")
+	}
+
+	bname := fmt.Sprintf("Bot %d", freeSlot+1)
+	for i := 0; i < len(lobby.player); i++ {
+		if lobby.player[i].Name == bname {
+			return Player{}, errors.New("Duplicate player name: " + bname)
+		}
+	}
+
+	bot := newBot(bname, level)
+	randomizeMemberColor(&bot.Color, avoidRGBPlayer)
+	lobby.player[freeSlot] = bot
+	return bot, nil
+}
+
+// removeBotFromLobby removes the bot named `bname` from lobby `lname`
+func removeBotFromLobby(lname string, bname string) error {
+	lobbyMutex.Lock()
+	defer lobbyMutex.Unlock()
+
+	lobby, ok := activeLobbies[lname]
+	if !ok {
+		return errors.New("Lobby does not exist")
+	}
+	if lobby.gameId != uuid.Nil {
+		return errors.New("Game has already started")
+	}
+	for i := 0; i < len(lobby.player); i++ {
+		if lobby.player[i].Name == bname && lobby.player[i].isBot() {
+			lobby.player[i] = Player{}
+			return nil
+		}
+	}
+	return errors.New("Bot not found: " + bname)
+}
+
+// setLobbyPlayerCap sets the most members allowed in lobby `lname`.
+// Errors if `count` is out of range or lower than the number of members in the lobby.
+func setLobbyPlayerCap(lname string, count int) error {
+	if count < 2 || count > maxPlayers {
+		return fmt.Errorf("Player cap must be between 2 and %d", maxPlayers)
+	}
+
+	lobbyMutex.Lock()
+	defer lobbyMutex.Unlock()
+
+	lobby, ok := activeLobbies[lname]
+	if !ok {
+		return errors.New("Lobby does not exist")
+	}
+	if lobby.gameId != uuid.Nil {
+		return errors.New("Game has already started")
+	}
+	if count < lobby.memberCount() {
+		return fmt.Errorf("Lobby already has %d members", lobby.memberCount())
+	}
+	lobby.playerCap = count
+	return nil
+}
+
 // leaveLobby removes player with uuid `id` from lobby `lname`
 func leaveLobby(lname string, id uuid.UUID) error {
 	lobbyMutex.Lock()
@@ -155,7 +275,8 @@ func cleanUpLobbies(serverlog *log.Logger, debug bool) {
 	for k := range activeLobbies {
 		player_count := 0
 		for i, u := range activeLobbies[k].player {
-			if u.lastSeen.IsZero() {
+			if u.lastSeen.IsZero() || u.isBot() {
+				// bots don't count towards keeping a lobby open
 				continue
 			}
 			if time.Since(u.lastSeen) > lobbyMemberIdleTimeout {
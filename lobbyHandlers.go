@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,8 +14,9 @@ import (
 )
 
 type LobbyInfo struct {
-	Members []Player `json:"members"`
-	GameId  *string  `json:"game"` // nil or UUID of the game
+	Members   []Player `json:"members"`
+	GameId    *string  `json:"game"`       // nil or UUID of the game
+	PlayerCap int      `json:"player_cap"` // most members allowed
 }
 
 // getLobbyPlayerFromReq gets the player based on cookies and optionally updates the
@@ -123,6 +125,64 @@ func lobbyLeaveHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// lobbyAddBotHandler adds a bot to the requestor's lobby.
+// The bot level is given by the "level" URL arg.
+func lobbyAddBotHandler(w http.ResponseWriter, r *http.Request) {
+	lobbyName, _, err := getLobbyPlayerFromReq(r, true)
+	if err != nil {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	level := r.URL.Query().Get("level")
+	bot, err := addBotToLobby(lobbyName, level)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if debug {
+		serverlog.Printf("lobbyAddBotHandler: added %v to lobby %s", &bot, lobbyName)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lobbyRemoveBotHandler removes a bot from the requestor's lobby.
+// The bot is given by the "name" URL arg.
+func lobbyRemoveBotHandler(w http.ResponseWriter, r *http.Request) {
+	lobbyName, _, err := getLobbyPlayerFromReq(r, true)
+	if err != nil {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err = removeBotFromLobby(lobbyName, r.URL.Query().Get("name")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lobbySetPlayerCapHandler sets the most members allowed in the requestor's lobby.
+// The cap is given by the "count" URL arg.
+func lobbySetPlayerCapHandler(w http.ResponseWriter, r *http.Request) {
+	lobbyName, _, err := getLobbyPlayerFromReq(r, true)
+	if err != nil {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil {
+		http.Error(w, "Invalid count", http.StatusBadRequest)
+		return
+	}
+	if err = setLobbyPlayerCap(lobbyName, count); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // lobbyListHandler returns the list of lobbies as json
 func lobbyListHandler(w http.ResponseWriter, r *http.Request) {
 	lobbyMutex.Lock()
@@ -173,6 +233,7 @@ func lobbyInfoHandler(w http.ResponseWriter, r *http.Request) {
 				gameIdStr := activeLobbies[k].gameId.String()
 				info.GameId = &gameIdStr
 			}
+			info.PlayerCap = activeLobbies[k].playerLimit()
 			for _, u := range activeLobbies[k].player {
 				if !u.lastSeen.IsZero() {
 					info.Members = append(info.Members, u)
@@ -226,7 +226,7 @@ func TestLobbyInfoHandler(t *testing.T) {
 	t.Log("lobbyInfoHandler response body was:", string(body))
 
 	// verify the response body
-	expected := `{"members":[{"name":"p1","color":"#ff0000"},{"name":"p3","color":"#0000ff"}],"game":null}`
+	expected := `{"members":[{"name":"p1","color":"#ff0000"},{"name":"p3","color":"#0000ff"}],"game":null,"player_cap":8}`
 	var expectedMap, resultMap map[string]interface{}
 	if err := json.Unmarshal([]byte(expected), &expectedMap); err != nil {
 		t.Fatalf("Failed to unmarshal expected: %v", err)
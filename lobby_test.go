@@ -185,3 +185,47 @@ func TestLobbyOperations(t *testing.T) {
 		t.Fatal("joinLobby - expected 'Game has already started' error. Got", err)
 	}
 }
+
+// TestLobbyPlayerCap tests limiting the number of lobby members and game players
+func TestLobbyPlayerCap(t *testing.T) {
+	var err error
+
+	lobbyName := "TestLobbyPlayerCap"
+	_ = joinLobbyWrapper(t, lobbyName, "p1", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p2", "")
+	_ = joinLobbyWrapper(t, lobbyName, "p3", "")
+
+	for _, count := range []int{1, maxPlayers + 1, 2} {
+		if err = setLobbyPlayerCap(lobbyName, count); err == nil {
+			t.Errorf("setLobbyPlayerCap(%d) should have failed", count)
+		}
+	}
+	if err = setLobbyPlayerCap(lobbyName, 3); err != nil {
+		t.Fatalf("setLobbyPlayerCap failed: %v", err)
+	}
+	if _, err = joinLobby(lobbyName, "p4", ""); err == nil || err.Error() != "Lobby is full" {
+		t.Errorf("Expected 'Lobby is full' joining past the cap. Got %v", err)
+	}
+	if _, err = addBotToLobby(lobbyName, botLevels[0]); err == nil || err.Error() != "Lobby is full" {
+		t.Errorf("Expected 'Lobby is full' adding a bot past the cap. Got %v", err)
+	}
+
+	// games default to the lobby's cap and may lower it
+	if _, err = createGame(activeLobbies[lobbyName], nil); err != nil {
+		t.Errorf("createGame failed: %v", err)
+	}
+	for _, cap := range []int{1, 2, maxPlayers + 1} {
+		if _, err = createGame(activeLobbies[lobbyName], map[string]any{"max_players": cap}); err == nil {
+			t.Errorf("createGame with max_players %d should have failed", cap)
+		}
+	}
+
+	// the cap is saved with the lobby
+	loaded, err := lobbyFromState(activeLobbies[lobbyName].toState())
+	if err != nil {
+		t.Fatalf("lobbyFromState failed: %v", err)
+	}
+	if loaded.playerLimit() != 3 {
+		t.Errorf("Expected a player cap of 3 after loading. Got %d", loaded.playerLimit())
+	}
+}
@@ -1,9 +1,10 @@
 package main
 
-//go:generate go run game.go lobby.go player.go gen_js_vars.go
+//go:generate go run game.go gameBites.go gameBonus.go gameCapture.go gameDraft.go gameFog.go gameHold.go gameMap.go gamePieceQueue.go gameSimultaneous.go gameSpecialCells.go gameTimer.go gameUndo.go gameWin.go lobby.go player.go gen_js_vars.go
 //go:generate go run gen_html_from_markdown.go
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
@@ -12,10 +13,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/stephenkowalewski/fungus-wars/internal/logging"
@@ -37,6 +40,8 @@ var staticContentHeaders = server_flags.Header{
 var debug bool
 var printVersion bool
 
+const shutdownTimeout = 5 * time.Second
+
 // These get set at build time with -ldflags
 var (
 	Version   = "dev"
@@ -81,6 +86,8 @@ func clearCookies(w http.ResponseWriter) {
 func main() {
 	var listen, redirectListen, redirectTarget, redirectExclude string
 	var certFile, keyFile string
+	var stateDir string
+	var mapsDir string
 	var accesslogger = server_flags.Logfile{Logger: &accesslog, Name: "stdout"}
 	var serverlogger = server_flags.Logfile{Logger: &serverlog, Name: "stderr"}
 
@@ -92,6 +99,8 @@ func main() {
 	flag.StringVar(&redirectTarget, "http-redirect-target", "https://[[HOST]][[PATH]]", "Where to redirect clients to. [[HOST]] and [[PATH]] are replaced with the request Host header (no port) and URL Path, respectively.")
 	flag.StringVar(&redirectExclude, "http-redirect-exclude", `^/\.well-known/acme-challenge/`, "Don't redirect paths matching this regex.")
 	flag.StringVar(&docroot, "docroot", "./static", "directory to serve static assets from")
+	flag.StringVar(&mapsDir, "maps-dir", "", "Directory of map files (*.json) that games can be created from. Disabled if empty.")
+	flag.StringVar(&stateDir, "state-dir", "", "Directory to save active games and lobbies to. They are reloaded from here at startup. Disabled if empty.")
 	flag.Var(&accesslogger, "accesslog", "log file for http requests")
 	flag.Var(&serverlogger, "serverlog", "log file for server messages")
 	flag.Var(&globalHeaders, "header", "Custom HTTP response header. May be specified more than once.")
@@ -164,6 +173,15 @@ func main() {
 	http.Handle("/lobby/leave", // leave lobby
 		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
 			http.HandlerFunc(lobbyLeaveHandler))))
+	http.Handle("/lobby/add-bot", // add a computer player to the lobby
+		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
+			http.HandlerFunc(lobbyAddBotHandler))))
+	http.Handle("/lobby/remove-bot", // remove a computer player from the lobby
+		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
+			http.HandlerFunc(lobbyRemoveBotHandler))))
+	http.Handle("/lobby/set-player-cap", // set the most members allowed in the lobby
+		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
+			http.HandlerFunc(lobbySetPlayerCapHandler))))
 	http.Handle("/lobby/list", // get list of active lobbies
 		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
 			http.HandlerFunc(lobbyListHandler))))
@@ -183,6 +201,9 @@ func main() {
 	http.Handle("/game/create", // creates a game
 		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
 			http.HandlerFunc(createGameHandler))))
+	http.Handle("/game/maps", // get the list of maps games can be created from
+		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
+			http.HandlerFunc(mapListHandler))))
 	http.Handle("/game/join", // joins a game
 		logging.AccessLogHandler(accesslog, handleGlobalheaders(false,
 			http.HandlerFunc(joinGameHandler))))
@@ -264,8 +285,24 @@ func main() {
 		}()
 	}
 
+	if mapsDir != "" {
+		names, err := loadGameMaps(mapsDir)
+		if err != nil {
+			serverlog.Fatalf("Failed to load maps from %s: %v", mapsDir, err)
+		}
+		serverlog.Printf("Loaded %d maps from %s: %s", len(names), mapsDir, strings.Join(names, ", "))
+	}
+
+	if stateDir != "" {
+		if err := loadState(stateDir, serverlog); err != nil {
+			serverlog.Fatalf("Failed to load state from %s: %v", stateDir, err)
+		}
+		go saveStateBackgroundTask(stateDir, serverlog)
+	}
+
 	go cleanUpLobbiesBackgroundTask(serverlog, debug)
 	go cleanUpGamesBackgroundTask(serverlog, debug)
+	go gameExpireTurnsBackgroundTask()
 
 	ln, err := net.Listen("tcp", listen)
 	if err != nil {
@@ -274,13 +311,36 @@ func main() {
 	// turns listen strings like "localhost:0" into something like "127.0.0.1:42189"
 	listenAddr := ln.Addr().String()
 
+	// stop the server on SIGINT or SIGTERM
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		serverlog.Printf("Received %v: shutting down\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			serverlog.Println("Shutdown failed:", err)
+		}
+	}()
+
 	if certFile == "" {
 		serverlog.Println("Starting HTTP server on " + listenAddr)
-		serverlog.Fatal(s.Serve(ln))
+		err = s.Serve(ln)
 	} else {
 		serverlog.Println("Starting HTTPS server on " + listenAddr)
 		tlsListener := tls.NewListener(ln, s.TLSConfig)
-		serverlog.Fatal(s.Serve(tlsListener))
+		err = s.Serve(tlsListener)
+	}
+	if err != http.ErrServerClosed {
+		serverlog.Fatal(err)
 	}
 
+	// save the games and lobbies one last time
+	if stateDir != "" {
+		serverlog.Println("Saving state to " + stateDir)
+		if err := saveState(stateDir); err != nil {
+			serverlog.Fatal("saveState() failed: ", err)
+		}
+	}
 }
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"testing"
@@ -205,3 +206,70 @@ ReloadComplete:
 		t.Fatalf("expected CommonName=%q, got %q", generatedCertCN, serverCert.Subject.CommonName)
 	}
 }
+
+func TestServerSavesStateOnSIGTERM(t *testing.T) {
+	stateDir := t.TempDir()
+
+	// Start the server with a random port
+	cmd := exec.Command(testBinaryPath,
+		"--listen=127.0.0.1:0",
+		"--docroot=./static",
+		"--state-dir="+stateDir,
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to capture stdout: %v", err)
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+	}()
+
+	// Read startup lines until we learn the chosen port
+	var addr string
+	scanner := bufio.NewScanner(stdout)
+	for addr == "" && scanner.Scan() {
+		line := scanner.Text()
+		t.Log("server output: ", line)
+		if strings.Contains(line, "Starting HTTP server on") {
+			fields := strings.Fields(line)
+			addr = fields[len(fields)-1]
+		}
+	}
+	if addr == "" {
+		t.Fatalf("could not determine server bind address from logs")
+	}
+	go func() {
+		for scanner.Scan() {
+		}
+	}()
+
+	// create a lobby, then stop the server well before the next periodic save
+	resp, err := http.Get("http://" + addr + "/lobby/join?lobby=sigterm_lobby")
+	if err != nil {
+		t.Fatalf("server did not respond: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("server did not exit cleanly: %v", err)
+		}
+	case <-time.After(2 * shutdownTimeout):
+		t.Fatalf("timeout waiting for the server to exit")
+	}
+
+	if _, err := os.Stat(filepath.Join(stateDir, stateLobbiesDir, "sigterm_lobby.json")); err != nil {
+		t.Errorf("Expected the lobby to be saved on shutdown: %v", err)
+	}
+}
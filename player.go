@@ -11,7 +11,16 @@ import (
 	"github.com/google/uuid"
 )
 
-const maxPlayers int = 4
+const maxPlayers int = 8 // sizes lobbies and per-player game state, must be a single digit
+
+// Bot levels. Players with a bot level have their turns played by the server.
+const (
+	botLevelRandom    = "random"    // plays a random legal move
+	botLevelGreedy    = "greedy"    // plays the move that gains the most cells
+	botLevelLookahead = "lookahead" // searches a few moves ahead with depth-limited minimax
+)
+
+var botLevels = []string{botLevelRandom, botLevelGreedy, botLevelLookahead}
 
 // Player represents an active user in a Lobby.
 // Check for the zero value of lastSeen to see if a
@@ -19,6 +28,7 @@ const maxPlayers int = 4
 type Player struct {
 	Name     string `json:"name"`
 	Color    RGB    `json:"color"`
+	Bot      string `json:"bot,omitempty"` // bot level, empty for human players
 	id       uuid.UUID
 	lastSeen time.Time
 }
@@ -26,11 +36,18 @@ type Player struct {
 func (m *Player) String() string {
 	if m.lastSeen.IsZero() {
 		return "<empty>"
+	} else if m.isBot() {
+		return fmt.Sprintf("%-8s %s %s %s (%s bot)", m.Name, &m.Color, m.lastSeen, m.id, m.Bot)
 	} else {
 		return fmt.Sprintf("%-8s %s %s %s", m.Name, &m.Color, m.lastSeen, m.id)
 	}
 }
 
+// isBot returns true if the server plays this player's turns
+func (m *Player) isBot() bool {
+	return m.Bot != ""
+}
+
 func newPlayer(name string) Player {
 	return Player{
 		Name:     name,
@@ -39,6 +56,12 @@ func newPlayer(name string) Player {
 	}
 }
 
+func newBot(name string, level string) Player {
+	bot := newPlayer(name)
+	bot.Bot = level
+	return bot
+}
+
 type RGB struct {
 	rgb [3]uint8
 }
@@ -93,6 +116,10 @@ func (m *RGB) Parse(s string) error {
 
 const defaultRGBTolerance int = 24
 
+// fullLobbyRGBTolerance is a tolerance at which RandomizeAvoidingDuplicates always finds a
+// color while avoiding maxPlayers colors (every other lobby member and a reserved color)
+const fullLobbyRGBTolerance int = (256/maxPlayers - 1) / 2
+
 // IsNearDuplicate returns true if to RGB have the same rgb values
 // within a given tolerance.
 func (m *RGB) IsNearDuplicate(n *RGB, tolerance int) bool {
@@ -151,6 +151,18 @@ func TestRGB(t *testing.T) {
 			t.Errorf("RandomizeAvoidingDuplicates should have return a 'No available colors' error but didn't. err=%v", err)
 		}
 	}
+
+	// a full lobby always leaves a color at fullLobbyRGBTolerance
+	fullLobby := make([]*RGB, maxPlayers)
+	for i := range fullLobby {
+		v := uint8(i * 256 / maxPlayers)
+		fullLobby[i] = &RGB{[3]uint8{v, v, v}}
+	}
+	err = toRandomize.RandomizeAvoidingDuplicates(fullLobbyRGBTolerance, fullLobby)
+	if err != nil {
+		t.Errorf("RandomizeAvoidingDuplicates returned unexpected error for a full lobby: %v", err)
+	}
+
 }
 
 func BenchmarkRandomizeAvoidingDuplicates(b *testing.B) {
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Games and lobbies are saved as one JSON file each under these
+// subdirectories of the state directory.
+const stateGamesDir = "games"
+const stateLobbiesDir = "lobbies"
+const stateSaveInterval = 1 * time.Minute
+
+// playerState is the saved form of a Player
+type playerState struct {
+	Name     string    `json:"name"`
+	Color    RGB       `json:"color"`
+	Bot      string    `json:"bot,omitempty"`
+	Id       uuid.UUID `json:"id"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// lobbyState is the saved form of a Lobby
+type lobbyState struct {
+	Name      string        `json:"name"`
+	GameId    uuid.UUID     `json:"game_id"`
+	Players   []playerState `json:"players"`
+	PlayerCap int           `json:"player_cap"`
+}
+
+// gameState is the saved form of a Game. Connections and undo state are not saved.
+type gameState struct {
+	Uuid                      uuid.UUID       `json:"uuid"`
+	FromLobby                 string          `json:"from_lobby"`
+	Created                   time.Time       `json:"created"`
+	Players                   []playerState   `json:"players"`
+	Turn                      int             `json:"turn"`
+	Round                     int             `json:"round"`
+	Scores                    []int           `json:"scores"`
+	Bites                     []int           `json:"bites"`
+	Rerolls                   []int           `json:"rerolls"`
+	NewCellsForBites          []int           `json:"new_cells_for_bites"`
+	WinLossDrawRecord         []WinLossDraw   `json:"win_loss_draw_record"`
+	NewCellsForBitesThreshold int             `json:"new_cells_for_bites_threshold"`
+	StartBites                int             `json:"start_bites"`
+	StartRerolls              int             `json:"start_rerolls"`
+	BonusBiteCells            int             `json:"bonus_bite_cells"`
+	BonusRerollCells          int             `json:"bonus_reroll_cells"`
+	BonusBiteAward            int             `json:"bonus_bite_award"`
+	BonusRerollAward          int             `json:"bonus_reroll_award"`
+	BonusPlacement            int             `json:"bonus_placement"`
+	BonusHomeClearance        int             `json:"bonus_home_clearance"`
+	ShieldCells               int             `json:"shield_cells"`
+	MultiplierCells           int             `json:"multiplier_cells"`
+	SporeCells                int             `json:"spore_cells"`
+	Board                     GameBoard       `json:"board"`
+	PieceMaskLength           int             `json:"piece_mask_length"` // always pieceMaskMaxLength
+	Pieces                    []Piece         `json:"pieces"`
+	MirrorPieces              bool            `json:"mirror_pieces"`
+	BiteShapes                []Bite          `json:"bite_shapes"`
+	NextPiece                 Piece           `json:"next_piece"`
+	CaptureMode               int             `json:"capture_mode"`
+	CaptureDiagonals          bool            `json:"capture_diagonals"`
+	CaptureMinLength          int             `json:"capture_min_length"`
+	CaptureMaxLength          int             `json:"capture_max_length"`
+	CaptureChains             bool            `json:"capture_chains"`
+	CaptureHomeCells          bool            `json:"capture_home_cells"`
+	CaptureThroughHomeCells   bool            `json:"capture_through_home_cells"`
+	WinTerritory              int             `json:"win_territory"`
+	WinScore                  int             `json:"win_score"`
+	MaxRounds                 int             `json:"max_rounds"`
+	TieBreak                  int             `json:"tie_break"`
+	RandomizeStartPos         bool            `json:"randomize_start_pos"`
+	WallMode                  int             `json:"wall_mode"`
+	WallDensity               float64         `json:"wall_density"`
+	Map                       *GameMap        `json:"map,omitempty"`
+	Teams                     bool            `json:"teams"`
+	TeamSharedPaths           bool            `json:"team_shared_paths"`
+	TimerMode                 int             `json:"timer_mode"`
+	TurnTime                  time.Duration   `json:"turn_time"`
+	ClockTime                 time.Duration   `json:"clock_time"`
+	ClockIncrement            time.Duration   `json:"clock_increment"`
+	TimeoutAction             int             `json:"timeout_action"`
+	Clocks                    []time.Duration `json:"clocks"`
+	TurnElapsed               time.Duration   `json:"turn_elapsed"` // time spent on the current turn when saved
+	FogOfWar                  int             `json:"fog_of_war"`
+	Simultaneous              bool            `json:"simultaneous"`
+	PieceDistribution         int             `json:"piece_distribution"`
+	PieceQueueLength          int             `json:"piece_queue_length"`
+	PieceQueue                []Piece         `json:"piece_queue"`
+	PieceBag                  []Piece         `json:"piece_bag"`
+	AllowHold                 bool            `json:"allow_hold"`
+	HeldPieces                []Piece         `json:"held_pieces,omitempty"`
+	HoldUsed                  []bool          `json:"hold_used,omitempty"`
+	DraftSize                 int             `json:"draft_size"`
+	DraftPieces               []Piece         `json:"draft_pieces"`
+	PlayerDraftPieces         [][]Piece       `json:"player_draft_pieces,omitempty"`
+	RoundMoves                []*RoundMove    `json:"round_moves,omitempty"`
+	PlayerPieces              []Piece         `json:"player_pieces,omitempty"`
+	IsOver                    bool            `json:"is_over"`
+	Winners                   []int           `json:"winners"`
+	History                   []GameEvent     `json:"history"`
+	Seed                      uint64          `json:"seed"`
+	RngState                  []byte          `json:"rng_state"`
+}
+
+func (p *Player) toState() playerState {
+	return playerState{
+		Name:     p.Name,
+		Color:    p.Color,
+		Bot:      p.Bot,
+		Id:       p.id,
+		LastSeen: p.lastSeen,
+	}
+}
+
+func playerFromState(s playerState) Player {
+	return Player{
+		Name:     s.Name,
+		Color:    s.Color,
+		Bot:      s.Bot,
+		id:       s.Id,
+		lastSeen: s.LastSeen,
+	}
+}
+
+func (l *Lobby) toState() lobbyState {
+	s := lobbyState{
+		Name:      l.name,
+		GameId:    l.gameId,
+		PlayerCap: l.playerCap,
+	}
+	for i := range l.player {
+		s.Players = append(s.Players, l.player[i].toState())
+	}
+	return s
+}
+
+func lobbyFromState(s lobbyState) (*Lobby, error) {
+	if !lobbyAllowedNameRegex.MatchString(s.Name) {
+		return nil, errors.New("Invalid lobby name: " + s.Name)
+	}
+	if len(s.Players) > maxPlayers {
+		return nil, fmt.Errorf("Lobby %s has too many players: %d", s.Name, len(s.Players))
+	}
+	if s.PlayerCap < 0 || s.PlayerCap > maxPlayers {
+		return nil, fmt.Errorf("Lobby %s has an invalid player cap: %d", s.Name, s.PlayerCap)
+	}
+	lobby := &Lobby{
+		name:      s.Name,
+		gameId:    s.GameId,
+		playerCap: s.PlayerCap,
+	}
+	for i := range s.Players {
+		lobby.player[i] = playerFromState(s.Players[i])
+	}
+	return lobby, nil
+}
+
+// toState returns the saved form of game. The caller must hold game.mu.
+func (game *Game) toState() (gameState, error) {
+	rngState, err := game.rngSource.MarshalBinary()
+	if err != nil {
+		return gameState{}, err
+	}
+	s := gameState{
+		Uuid:                      game.uuid,
+		FromLobby:                 game.fromLobby,
+		Created:                   game.created,
+		Turn:                      game.turn,
+		Round:                     game.round,
+		Scores:                    game.scores[:game.playerCount],
+		Bites:                     game.bites[:game.playerCount],
+		Rerolls:                   game.rerolls[:game.playerCount],
+		NewCellsForBites:          game.newCellsForBites[:game.playerCount],
+		WinLossDrawRecord:         game.winLossDrawRecord[:game.playerCount],
+		NewCellsForBitesThreshold: game.newCellsForBitesThreshold,
+		StartBites:                game.startBites,
+		StartRerolls:              game.startRerolls,
+		BonusBiteCells:            game.bonusBiteCells,
+		BonusRerollCells:          game.bonusRerollCells,
+		BonusBiteAward:            game.bonusBiteAward,
+		BonusRerollAward:          game.bonusRerollAward,
+		BonusPlacement:            game.bonusPlacement,
+		BonusHomeClearance:        game.bonusHomeClearance,
+		ShieldCells:               game.shieldCells,
+		MultiplierCells:           game.multiplierCells,
+		SporeCells:                game.sporeCells,
+		Board:                     game.board,
+		PieceMaskLength:           pieceMaskMaxLength,
+		Pieces:                    game.pieces,
+		MirrorPieces:              game.mirrorPieces,
+		BiteShapes:                game.biteShapes,
+		NextPiece:                 game.nextPiece,
+		CaptureMode:               game.captureMode,
+		CaptureDiagonals:          game.captureDiagonals,
+		CaptureMinLength:          game.captureMinLength,
+		CaptureMaxLength:          game.captureMaxLength,
+		CaptureChains:             game.captureChains,
+		CaptureHomeCells:          game.captureHomeCells,
+		CaptureThroughHomeCells:   game.captureThroughHomeCells,
+		WinTerritory:              game.winTerritory,
+		WinScore:                  game.winScore,
+		MaxRounds:                 game.maxRounds,
+		TieBreak:                  game.tieBreak,
+		RandomizeStartPos:         game.randomizeStartPos,
+		WallMode:                  game.wallMode,
+		WallDensity:               game.wallDensity,
+		Map:                       game.gameMap,
+		Teams:                     game.teams,
+		TeamSharedPaths:           game.teamSharedPaths,
+		TimerMode:                 game.timerMode,
+		TurnTime:                  game.turnTime,
+		ClockTime:                 game.clockTime,
+		ClockIncrement:            game.clockIncrement,
+		TimeoutAction:             game.timeoutAction,
+		Clocks:                    game.clocks[:game.playerCount],
+		TurnElapsed:               time.Since(game.turnStarted),
+		FogOfWar:                  game.fogOfWar,
+		Simultaneous:              game.simultaneous,
+		PieceDistribution:         game.pieceDistribution,
+		PieceQueueLength:          game.pieceQueueLength,
+		PieceQueue:                game.pieceQueue,
+		PieceBag:                  game.pieceBag,
+		AllowHold:                 game.allowHold,
+		DraftSize:                 game.draftSize,
+		DraftPieces:               game.draftPieces,
+		IsOver:                    game.isOver,
+		Winners:                   game.winners,
+		History:                   game.history,
+		Seed:                      game.seed,
+		RngState:                  rngState,
+	}
+	for i := 0; i < game.playerCount; i++ {
+		s.Players = append(s.Players, game.players[i].toState())
+	}
+	if game.allowHold {
+		s.HeldPieces = game.heldPieces[:game.playerCount]
+		s.HoldUsed = game.holdUsed[:game.playerCount]
+	}
+	if game.simultaneous {
+		s.RoundMoves = game.roundMoves[:game.playerCount]
+		s.PlayerPieces = game.playerPieces[:game.playerCount]
+		s.PlayerDraftPieces = game.playerDraftPieces[:game.playerCount]
+	}
+	return s, nil
+}
+
+func gameFromState(s gameState) (*Game, error) {
+	playerCount := len(s.Players)
+	if playerCount < 2 || playerCount > maxPlayers {
+		return nil, fmt.Errorf("Invalid player count: %d", playerCount)
+	}
+	for _, l := range [][]int{s.Scores, s.Bites, s.Rerolls, s.NewCellsForBites} {
+		if len(l) != playerCount {
+			return nil, errors.New("Per-player values do not match the player count")
+		}
+	}
+	if len(s.WinLossDrawRecord) != playerCount {
+		return nil, errors.New("Per-player values do not match the player count")
+	}
+	if len(s.Board) <= gbMinSize || len(s.Board) > gbMaxSize ||
+		len(s.Board[0]) <= gbMinSize || len(s.Board[0]) > gbMaxSize {
+		return nil, errors.New("Board size out of bounds")
+	}
+	for i := range s.Board {
+		if len(s.Board[i]) != len(s.Board[0]) {
+			return nil, errors.New("Board rows have different lengths")
+		}
+	}
+	if len(s.Pieces) == 0 {
+		return nil, errors.New("Game has no pieces")
+	}
+	if len(s.BiteShapes) == 0 {
+		return nil, errors.New("Game has no bites")
+	}
+	if len(s.BiteShapes) > gbMaxBiteShapes {
+		return nil, errors.New("Game has too many bites")
+	}
+	if s.PieceMaskLength != pieceMaskMaxLength {
+		return nil, fmt.Errorf("Invalid piece mask length: %d", s.PieceMaskLength)
+	}
+	if s.CaptureMode < 0 || s.CaptureMode >= gameModeCaptureMax {
+		return nil, fmt.Errorf("Invalid capture mode: %d", s.CaptureMode)
+	}
+	if err := validateBonusOptions(s.BonusBiteCells, s.BonusRerollCells, s.BonusBiteAward, s.BonusRerollAward, s.BonusPlacement, s.BonusHomeClearance); err != nil {
+		return nil, err
+	}
+	if err := validateSpecialCells(s.ShieldCells, s.MultiplierCells, s.SporeCells); err != nil {
+		return nil, err
+	}
+	if err := validateCaptureLengths(s.CaptureMinLength, s.CaptureMaxLength); err != nil {
+		return nil, err
+	}
+	// the board at the start of the game isn't saved, so only the ranges are checked
+	if err := validateWinConditions(s.WinTerritory, s.WinScore, s.MaxRounds, s.TieBreak, 0, 0); err != nil {
+		return nil, err
+	}
+	if s.WallMode < 0 || s.WallMode >= gameWallsMax {
+		return nil, fmt.Errorf("Invalid wall mode: %d", s.WallMode)
+	}
+	if s.Map != nil {
+		if err := s.Map.parse(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateTimerOptions(s.TimerMode, s.TurnTime, s.ClockTime, s.ClockIncrement, s.TimeoutAction); err != nil {
+		return nil, err
+	}
+	if err := validateFogOfWar(s.FogOfWar); err != nil {
+		return nil, err
+	}
+	if err := validatePieceOptions(s.PieceDistribution, s.PieceQueueLength); err != nil {
+		return nil, err
+	}
+	if s.Simultaneous && (s.TimerMode != gameTimerPerTurn || len(s.RoundMoves) != playerCount || len(s.PlayerPieces) != playerCount) {
+		return nil, errors.New("Invalid simultaneous turns state")
+	}
+	if err := validateDraftSize(s.DraftSize); err != nil {
+		return nil, err
+	}
+	if s.Simultaneous && s.DraftSize > 1 && len(s.PlayerDraftPieces) != playerCount {
+		return nil, errors.New("Per-player values do not match the player count")
+	}
+	if s.AllowHold && (len(s.HeldPieces) != playerCount || len(s.HoldUsed) != playerCount) {
+		return nil, errors.New("Per-player values do not match the player count")
+	}
+	if s.TimerMode != gameTimerNone && len(s.Clocks) != playerCount {
+		return nil, errors.New("Per-player values do not match the player count")
+	}
+	if s.Teams && playerCount%gbTeamCount != 0 {
+		return nil, fmt.Errorf("Invalid team game with %d players", playerCount)
+	}
+	if s.Turn < -1 || s.Turn >= playerCount {
+		return nil, fmt.Errorf("Invalid turn: %d", s.Turn)
+	}
+	for _, w := range s.Winners {
+		if w < 0 || w >= playerCount {
+			return nil, fmt.Errorf("Invalid winner: %d", w)
+		}
+	}
+
+	rngSource, rng := newGameRand(s.Seed)
+	if err := rngSource.UnmarshalBinary(s.RngState); err != nil {
+		return nil, fmt.Errorf("Invalid rng state: %v", err)
+	}
+
+	game := &Game{
+		playerCount:               playerCount,
+		turn:                      s.Turn,
+		round:                     s.Round,
+		newCellsForBitesThreshold: s.NewCellsForBitesThreshold,
+		startBites:                s.StartBites,
+		startRerolls:              s.StartRerolls,
+		bonusBiteCells:            s.BonusBiteCells,
+		bonusRerollCells:          s.BonusRerollCells,
+		bonusBiteAward:            s.BonusBiteAward,
+		bonusRerollAward:          s.BonusRerollAward,
+		bonusPlacement:            s.BonusPlacement,
+		bonusHomeClearance:        s.BonusHomeClearance,
+		shieldCells:               s.ShieldCells,
+		multiplierCells:           s.MultiplierCells,
+		sporeCells:                s.SporeCells,
+		board:                     s.Board,
+		rowCount:                  len(s.Board),
+		colCount:                  len(s.Board[0]),
+		pieces:                    s.Pieces,
+		mirrorPieces:              s.MirrorPieces,
+		biteShapes:                s.BiteShapes,
+		nextPiece:                 s.NextPiece,
+		captureMode:               s.CaptureMode,
+		captureDiagonals:          s.CaptureDiagonals,
+		captureMinLength:          s.CaptureMinLength,
+		captureMaxLength:          s.CaptureMaxLength,
+		captureChains:             s.CaptureChains,
+		captureHomeCells:          s.CaptureHomeCells,
+		captureThroughHomeCells:   s.CaptureThroughHomeCells,
+		winTerritory:              s.WinTerritory,
+		winScore:                  s.WinScore,
+		maxRounds:                 s.MaxRounds,
+		tieBreak:                  s.TieBreak,
+		randomizeStartPos:         s.RandomizeStartPos,
+		wallMode:                  s.WallMode,
+		wallDensity:               s.WallDensity,
+		gameMap:                   s.Map,
+		teams:                     s.Teams,
+		teamSharedPaths:           s.TeamSharedPaths,
+		timerMode:                 s.TimerMode,
+		turnTime:                  s.TurnTime,
+		clockTime:                 s.ClockTime,
+		clockIncrement:            s.ClockIncrement,
+		timeoutAction:             s.TimeoutAction,
+		turnStarted:               time.Now().Add(-s.TurnElapsed), // the clock stops while the server is down
+		fogOfWar:                  s.FogOfWar,
+		simultaneous:              s.Simultaneous,
+		pieceDistribution:         s.PieceDistribution,
+		pieceQueueLength:          s.PieceQueueLength,
+		pieceQueue:                s.PieceQueue,
+		pieceBag:                  s.PieceBag,
+		allowHold:                 s.AllowHold,
+		draftSize:                 s.DraftSize,
+		draftPieces:               s.DraftPieces,
+		created:                   s.Created,
+		fromLobby:                 s.FromLobby,
+		isOver:                    s.IsOver,
+		winners:                   s.Winners,
+		history:                   s.History,
+		historyBroadcast:          len(s.History),
+		seed:                      s.Seed,
+		rng:                       rng,
+		rngSource:                 rngSource,
+		uuid:                      s.Uuid,
+	}
+	for i := 0; i < playerCount; i++ {
+		game.players[i] = playerFromState(s.Players[i])
+		game.scores[i] = s.Scores[i]
+		game.bites[i] = s.Bites[i]
+		game.rerolls[i] = s.Rerolls[i]
+		game.newCellsForBites[i] = s.NewCellsForBites[i]
+		game.winLossDrawRecord[i] = s.WinLossDrawRecord[i]
+	}
+	copy(game.clocks[:], s.Clocks)
+	copy(game.heldPieces[:], s.HeldPieces)
+	copy(game.holdUsed[:], s.HoldUsed)
+	copy(game.playerDraftPieces[:], s.PlayerDraftPieces)
+	copy(game.roundMoves[:], s.RoundMoves)
+	copy(game.playerPieces[:], s.PlayerPieces)
+	return game, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to name, so
+// that a crash never leaves a partially written file behind.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-"+filepath.Base(name)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after a successful rename
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// removeStaleStateFiles deletes JSON files in dir that are not in keep
+func removeStaleStateFiles(dir string, keep map[string]bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || keep[e.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveState writes all active games and lobbies to stateDir. Files for games and
+// lobbies that no longer exist are removed.
+func saveState(stateDir string) error {
+	gamesDir := filepath.Join(stateDir, stateGamesDir)
+	lobbiesDir := filepath.Join(stateDir, stateLobbiesDir)
+	for _, dir := range []string{gamesDir, lobbiesDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	// games
+	activeGameMutex.Lock()
+	games := make([]*Game, 0, len(activeGames))
+	for _, game := range activeGames {
+		games = append(games, game)
+	}
+	activeGameMutex.Unlock()
+
+	keep := map[string]bool{}
+	for _, game := range games {
+		game.mu.Lock()
+		s, err := game.toState()
+		var data []byte
+		if err == nil {
+			data, err = json.Marshal(s)
+		}
+		game.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("Failed to save game %v: %v", s.Uuid, err)
+		}
+		name := s.Uuid.String() + ".json"
+		if err = writeFileAtomic(filepath.Join(gamesDir, name), data); err != nil {
+			return err
+		}
+		keep[name] = true
+	}
+	if err := removeStaleStateFiles(gamesDir, keep); err != nil {
+		return err
+	}
+
+	// lobbies
+	lobbyMutex.Lock()
+	defer lobbyMutex.Unlock()
+	keep = map[string]bool{}
+	for _, lobby := range activeLobbies {
+		data, err := json.Marshal(lobby.toState())
+		if err != nil {
+			return fmt.Errorf("Failed to save lobby %s: %v", lobby.name, err)
+		}
+		name := lobby.name + ".json"
+		if err = writeFileAtomic(filepath.Join(lobbiesDir, name), data); err != nil {
+			return err
+		}
+		keep[name] = true
+	}
+	return removeStaleStateFiles(lobbiesDir, keep)
+}
+
+// readStateFiles calls load with the contents of each JSON file in dir
+func readStateFiles(dir string, serverlog *log.Logger, load func(data []byte) error) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err == nil {
+			err = load(data)
+		}
+		if err != nil {
+			serverlog.Printf("loadState(): skipping %s: %v\n", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// loadState adds the games and lobbies saved in stateDir to activeGames and activeLobbies.
+// Files that can't be loaded are logged and skipped.
+func loadState(stateDir string, serverlog *log.Logger) error {
+	activeGameMutex.Lock()
+	err := readStateFiles(filepath.Join(stateDir, stateGamesDir), serverlog, func(data []byte) error {
+		var s gameState
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		game, err := gameFromState(s)
+		if err != nil {
+			return err
+		}
+		activeGames[game.uuid] = game
+		return nil
+	})
+	gameCount := len(activeGames)
+	activeGameMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	lobbyMutex.Lock()
+	err = readStateFiles(filepath.Join(stateDir, stateLobbiesDir), serverlog, func(data []byte) error {
+		var s lobbyState
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		lobby, err := lobbyFromState(s)
+		if err != nil {
+			return err
+		}
+		activeLobbies[lobby.name] = lobby
+		return nil
+	})
+	lobbyCount := len(activeLobbies)
+	lobbyMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	serverlog.Printf("Loaded %d games and %d lobbies from %s\n", gameCount, lobbyCount, stateDir)
+	return nil
+}
+
+// saveStateBackgroundTask saves the state periodically. main saves it once more on shutdown.
+func saveStateBackgroundTask(stateDir string, serverlog *log.Logger) {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := saveState(stateDir); err != nil {
+			serverlog.Println("saveState() failed:", err)
+		}
+	}
+}
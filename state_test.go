@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stephenkowalewski/fungus-wars/internal/server_flags"
+)
+
+// samePlayer compares players, ignoring the monotonic clock reading in lastSeen
+func samePlayer(a, b *Player) bool {
+	return a.id == b.id && a.Name == b.Name && a.Color == b.Color && a.lastSeen.Equal(b.lastSeen)
+}
+
+func TestSaveAndLoadState(t *testing.T) {
+	var boardSize int = 10
+	var err error
+
+	if !testing.Verbose() {
+		serverLogFile := server_flags.Logfile{Logger: &serverlog}
+		serverLogFile.Set(os.DevNull)
+	}
+
+	// create a game and make a move
+	lobbyName := "TestSaveAndLoadState"
+	p1 := joinLobbyWrapper(t, lobbyName, "p1", "#ff0000")
+	joinLobbyWrapper(t, lobbyName, "p2", "#00ff00")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{
+		"size":                      boardSize,
+		"randomize_start_positions": true,
+		"starting_bites":            7,
+	})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	activeLobbies[lobbyName].gameId = game.uuid
+
+	homeIndex := -1
+	for i := 0; i < boardSize*boardSize; i++ {
+		r, c := game.board.getIndex2D(i)
+		if game.board[r][c] == 1|CellFlagHome {
+			homeIndex = i
+		}
+	}
+	game.nextPiece = Piece{PieceMask(0b1000000).generateRotations(), 1}
+	neighbor := homeIndex + 1
+	if homeIndex%boardSize == boardSize-1 {
+		neighbor = homeIndex - 1
+	}
+	err = game.placePiece(p1, neighbor, game.nextPiece.Masks[0])
+	if err != nil {
+		t.Fatalf("placePiece failed: %v\n%s", err, game.board.String2D())
+	}
+	game.winLossDrawRecord[1] = WinLossDraw{W: 1, L: 2, D: 3}
+
+	// save, then remove the game and lobby
+	stateDir := t.TempDir()
+	if err = saveState(stateDir); err != nil {
+		t.Fatalf("saveState failed: %v", err)
+	}
+	for _, name := range []string{
+		filepath.Join(stateDir, stateGamesDir, game.uuid.String()+".json"),
+		filepath.Join(stateDir, stateLobbiesDir, lobbyName+".json"),
+	} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected state file %s: %v", name, err)
+		}
+	}
+	activeGameMutex.Lock()
+	delete(activeGames, game.uuid)
+	activeGameMutex.Unlock()
+	lobby := activeLobbies[lobbyName]
+	delete(activeLobbies, lobbyName)
+
+	// load and compare
+	if err = loadState(stateDir, serverlog); err != nil {
+		t.Fatalf("loadState failed: %v", err)
+	}
+	loaded, ok := activeGames[game.uuid]
+	if !ok {
+		t.Fatalf("Game %v was not loaded", game.uuid)
+	}
+	if loaded.board.String2D() != game.board.String2D() {
+		t.Errorf("Board was not restored.\nExpected:\n%s\nGot:\n%s", game.board.String2D(), loaded.board.String2D())
+	}
+	if loaded.playerCount != game.playerCount {
+		t.Errorf("Expected %d players. Got %d", game.playerCount, loaded.playerCount)
+	}
+	for i := range game.players {
+		if !samePlayer(&loaded.players[i], &game.players[i]) {
+			t.Errorf("Player %d was not restored. Expected %v. Got %v", i, &game.players[i], &loaded.players[i])
+		}
+	}
+	if loaded.turn != game.turn || loaded.scores != game.scores || loaded.bites != game.bites ||
+		loaded.rerolls != game.rerolls || loaded.newCellsForBites != game.newCellsForBites {
+		t.Errorf("Game state was not restored.\nExpected:\n%v\nGot:\n%v", game, loaded)
+	}
+	if loaded.winLossDrawRecord != game.winLossDrawRecord {
+		t.Errorf("Expected winLossDrawRecord %v. Got %v", game.winLossDrawRecord, loaded.winLossDrawRecord)
+	}
+	if loaded.nextPiece != game.nextPiece || !slices.Equal(loaded.pieces, game.pieces) {
+		t.Errorf("Pieces were not restored")
+	}
+	if loaded.startBites != 7 || !loaded.randomizeStartPos || loaded.seed != game.seed {
+		t.Errorf("Options were not restored.\nExpected:\n%v\nGot:\n%v", game, loaded)
+	}
+	if len(loaded.history) != len(game.history) || loaded.historyBroadcast != len(game.history) {
+		t.Errorf("Expected %d history events. Got %d", len(game.history), len(loaded.history))
+	}
+
+	// the random source continues where it left off
+	for i := 0; i < 20; i++ {
+		game.setNextPiece()
+		loaded.setNextPiece()
+		if loaded.nextPiece != game.nextPiece {
+			t.Fatalf("nextPiece differs after loading: %v and %v", game.nextPiece, loaded.nextPiece)
+		}
+	}
+
+	loadedLobby, ok := activeLobbies[lobbyName]
+	if !ok {
+		t.Fatalf("Lobby %s was not loaded", lobbyName)
+	}
+	if loadedLobby.gameId != lobby.gameId {
+		t.Errorf("Expected lobby gameId %v. Got %v", lobby.gameId, loadedLobby.gameId)
+	}
+	for i := range lobby.player {
+		if !samePlayer(&loadedLobby.player[i], &lobby.player[i]) {
+			t.Errorf("Lobby player %d was not restored. Expected %v. Got %v", i, &lobby.player[i], &loadedLobby.player[i])
+		}
+	}
+
+	// removed games are removed from the state directory
+	activeGameMutex.Lock()
+	delete(activeGames, game.uuid)
+	activeGameMutex.Unlock()
+	if err = saveState(stateDir); err != nil {
+		t.Fatalf("saveState failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, stateGamesDir, game.uuid.String()+".json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the state file of a removed game to be deleted. err=%v", err)
+	}
+}
+
+func TestLoadStatePieceMaskLength(t *testing.T) {
+	var err error
+
+	lobbyName := "TestLoadStatePieceMaskLength"
+	joinLobbyWrapper(t, lobbyName, "p1", "")
+	joinLobbyWrapper(t, lobbyName, "p2", "")
+	game, err := createGame(activeLobbies[lobbyName], map[string]any{"size": 10})
+	if err != nil {
+		t.Fatalf("createGame failed: %v", err)
+	}
+	s, err := game.toState()
+	if err != nil {
+		t.Fatalf("toState failed: %v", err)
+	}
+
+	// masks of any other size can't be loaded
+	for _, length := range []int{0, legacyPieceMaskLength, pieceMaskMaxLength + 1} {
+		s.PieceMaskLength = length
+		if _, err = gameFromState(s); err == nil {
+			t.Errorf("Expected gameFromState to fail with a piece mask length of %d", length)
+		}
+	}
+}
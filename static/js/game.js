@@ -20,6 +20,8 @@ var boardAnimationRate = 350; // ms between each update
 
 // game piece related
 var nextPiece = null;
+var offeredPieces = []; // pieces the player may choose from with a piece draft, see offered_pieces
+var selectedOffer = 0; // index into offeredPieces
 var currentRotation = -1;
 var lastPreviewIndex = -1;
 var currentPreviewPieceMask = -1;
@@ -27,9 +29,11 @@ const previewPiece = 0;
 const previewBite = 1;
 var lastPreviewType = previewPiece;
 var bite = 0; // bit mask, 0 = no bite selected
+var biteShapes = []; // the bites of this game and their costs, from the game info
 
 // game piece preview related
 const previewMinGridSize = 4;
+const upcomingPieceColor = "#888";
 
 // vars for this player
 var playerNumber = null;
@@ -40,53 +44,75 @@ var playerRerolls = null;
 
 // player info
 var playerInfo = [];
+var playerTeams = null; // team of each player, null without team play
+var timeLeft = null; // milliseconds each player has left, null without a timer
+var timeLeftReceived = 0; // when timeLeft was received, from Date.now()
+var timeLeftIntervalId = null;
 var currentTurn = -1; // index into playerInfo
+var simultaneous = false; // every player moves at once each round
+var myTurn = false; // true if the player can move, with simultaneous turns until they submit a move
+var pendingMove = null; // move submitted this round with simultaneous turns (see RoundMove in gameSimultaneous.go)
+
+// every event in the game so far (see GameEvent in game.go)
+var gameHistory = [];
 
 // elements set in initVars()
 var gbElem = null;
 var buttonPanelElem = null;
 var nextTurnPreviewElem = null;
 var rotatePieceBtn = null;
+var flipPieceBtn = null;
 var skipTurnBtn = null;
-var smallBiteBtn = null;
-var largeBiteBtn = null;
+var biteButtonsElem = null;
 var rerollBtn = null;
+var holdPieceBtn = null;
+var undoMoveBtn = null;
+
+// pending undo request from the last game_info (see UndoRequest in gameUndo.go)
+var undoRequest = null;
 
 // get 1D offset mask for a piece, taking into account the board size
 function pieceGetGameBoardOffsets(pmask) {
 	const result = [];
-	let lastOffset = 0;
 	for (let r = 0; r < pieceMaskMaxLength; r++) {
-		for (let c = 0; c < pieceMaskMaxLength; c++) {
+		// squares past the last column can never be on the board
+		for (let c = 0; c < Math.min(pieceMaskMaxLength, boardCols); c++) {
 			if ( pieceHas(pmask, r, c) ) {
+				const offset = r * boardCols + c;
+				while ( result.length < offset ) {
+					result.push(0);
+				}
 				result.push(1);
-				lastOffset = result.length;
-			} else {
-				result.push(0);
-			}
-		}
-		// fill the rest of the board's row
-		if ( r < pieceMaskMaxLength - 1 ) {
-			for (let n = pieceMaskMaxLength; n < boardCols; n++) {
-				result.push(0);
 			}
 		}
 	}
-	result.length = lastOffset;
 	return result;
 }
 
 function pieceSelectNextRotation() {
-	if ( currentTurn !== playerIndex ) {
+	if ( !myTurn ) {
 		console.log("Skipped pieceSelectNextRotation(), not player's turn");
 		return;
 	}
-	currentRotation = ( currentRotation + 1 ) % maxPieceRotations;
+	pieceSelectOrientation(pieceNextRotation(nextPiece.masks, currentRotation), rotatePieceBtn);
+}
+
+function pieceSelectFlip() {
+	if ( !myTurn || !pieceCanFlip(nextPiece.masks) ) {
+		console.log("Skipped pieceSelectFlip(), not player's turn or piece can't be flipped");
+		return;
+	}
+	pieceSelectOrientation(pieceFlipped(nextPiece.masks, currentRotation), flipPieceBtn);
+}
+
+// updates globals: currentRotation
+function pieceSelectOrientation(rotation, btn) {
+	currentRotation = rotation;
 
 	// update local up next preview
 	updateNextTurnPreview(
 		nextTurnPreviewElem,
-		currentTurn,
+		simultaneous ? playerIndex : currentTurn,
 		nextPiece.masks[currentRotation],
 		playerInfo
 	);
@@ -102,9 +128,8 @@ function pieceSelectNextRotation() {
 	gbPreviewSendPiece(lastPreviewIndex, nextPiece.masks[currentRotation]);
 
 	// send button notification
-	buttonDisplayNotification(rotatePieceBtn);
-	sendButtonNotificationUpdate(rotatePieceBtn);
-
+	buttonDisplayNotification(btn);
+	sendButtonNotificationUpdate(btn);
 }
 
 function initVars() {
@@ -113,10 +138,27 @@ function initVars() {
 	nextTurnPreviewElem = document.getElementById('next-turn-preview');
 
 	rotatePieceBtn = document.getElementById("rotatePiece");
+	flipPieceBtn = document.getElementById("flipPiece");
 	skipTurnBtn = document.getElementById("skipTurn");
-	smallBiteBtn = document.getElementById("smallBite");
-	largeBiteBtn = document.getElementById("largeBite");
+	biteButtonsElem = document.getElementById("bite-buttons");
 	rerollBtn = document.getElementById("reroll");
+	holdPieceBtn = document.getElementById("holdPiece");
+	undoMoveBtn = document.getElementById("undoMove");
+}
+
+// game.html has info for the first four players. Add a copy of the first player's info
+// for every other seat, up to maxPlayers.
+function addPlayerInfoElements() {
+	const listElem = document.getElementById("player-list");
+	const template = document.getElementById("player1-info");
+	for (let i = listElem.getElementsByClassName("player-info").length; i < maxPlayers; i++) {
+		const pInfoElem = template.cloneNode(true);
+		for (const elem of [pInfoElem, ...pInfoElem.querySelectorAll("[id]")]) {
+			elem.id = elem.id.replace(/^player1-/, `player${i+1}-`);
+		}
+		pInfoElem.querySelector(".player-name").innerText = `Player ${i+1}`;
+		listElem.appendChild(pInfoElem);
+	}
 }
 
 function setHandlers() {
@@ -158,6 +200,8 @@ function initializeGameBoard(gbElem, cols, rows = -1) {
 	// set CSS column rules
 	gbElem.style.gridTemplateColumns = `repeat(${String(cols)}, 1fr)`;
 	gbElem.style.gridTemplateRows = `repeat(${String(rows)}, 1fr)`;
+	gbElem.style.aspectRatio = `${cols} / ${rows}`;
+	gbElem.style.maxWidth = `calc(80vh * ${cols} / ${rows})`;
 
 	// draw board
 	gbElem.innerHTML = "";
@@ -177,15 +221,27 @@ function updateGameBoard(gbElem) {
 	}
 }
 
+// Show the move the player submitted this round on top of the board until the round is resolved
+function gbShowPendingMove(gbElem) {
+	if ( pendingMove === null || pendingMove.index < 0 ) {
+		return;
+	}
+	if ( pendingMove.action === "place_bite" ) {
+		gbPreviewUpdateBoardPlacedBite(gbElem, pendingMove.index, pendingMove.mask);
+	} else {
+		gbPreviewUpdateBoardPlacedPiece(gbElem, pendingMove.index, pendingMove.mask, playerClass);
+	}
+	gbPreviewUpdateBoardPlacedPiece(gbElem, pendingMove.index, pendingMove.mask, "pending-move");
+}
+
 function updateGameBoardCell(elem, cell) {
 	const owner = cell & cellMaskPlayer;
-	switch (owner) {
-		case 1: case 2: case 3: case 4:
-			elem.className = `cell player${owner}`;
-			break;
-		default:
-			elem.className = 'cell';
-			break;
+	if ( cell & cellFlagHidden ) {
+		elem.className = 'cell hidden';
+	} else if ( owner >= 1 && owner <= maxPlayers ) {
+		elem.className = `cell player${owner}`;
+	} else {
+		elem.className = ( cell & cellFlagWall ) ? 'cell wall' : 'cell';
 	}
 
 	const textElem = document.createElement("span");
@@ -197,6 +253,12 @@ function updateGameBoardCell(elem, cell) {
 		textElem.innerText = "▴";
 	} else if ( cell & cellFlagBonusReroll ) {
 		textElem.innerText = "🎲";
+	} else if ( cell & cellFlagShield ) {
+		textElem.innerText = "🛡";
+	} else if ( cell & cellFlagMultiplier ) {
+		textElem.innerText = "×2";
+	} else if ( cell & cellFlagSpore ) {
+		textElem.innerText = "✺";
 	} else {
 		textElem.innerText = "";
 	}
@@ -228,6 +290,59 @@ function updateNextTurnPreview(previewContainer, turn, nextPieceMask, playerList
 	currentPreviewPieceMask = nextPieceMask;
 }
 
+// Show the pieces offered this turn with a piece draft. The selected piece is the one placed.
+function updateOfferedPieces() {
+	const container = document.getElementById("offered-pieces");
+	container.innerHTML = "";
+	document.getElementById("offered-pieces-label").hidden = offeredPieces.length < 2;
+	if ( offeredPieces.length < 2 ) {
+		return;
+	}
+	const color = playerInfo[playerIndex]?.color ?? "#000";
+	for (let i = 0; i < offeredPieces.length; i++) {
+		const previewGrid = document.createElement("div");
+		previewGrid.classList.add("offered-piece");
+		if ( i === selectedOffer ) {
+			previewGrid.classList.add("selected");
+		}
+		previewGrid.addEventListener("click", () => selectOfferedPiece(i));
+		container.appendChild(previewGrid);
+		drawPiecePreview(previewGrid, offeredPieces[i].masks[0], color, previewMinGridSize);
+	}
+}
+
+// Choose which of the offered pieces to place
+// updates globals: selectedOffer, nextPiece, currentRotation
+function selectOfferedPiece(i) {
+	if ( !myTurn || i < 0 || i >= offeredPieces.length ) {
+		return;
+	}
+	selectedOffer = i;
+	nextPiece = offeredPieces[i];
+	currentRotation = 0;
+	updateOfferedPieces();
+	updateNextTurnPreview(
+		nextTurnPreviewElem,
+		simultaneous ? playerIndex : currentTurn,
+		nextPiece.masks[currentRotation],
+		playerInfo
+	);
+	gbPreviewUpdateBoard(gbElem, lastPreviewIndex, nextPiece.masks[currentRotation]);
+	gbPreviewSendPiece(lastPreviewIndex, nextPiece.masks[currentRotation]);
+}
+
+// Show the pieces that come after the next piece, in order
+function updateUpcomingPieces(pieces) {
+	const container = document.getElementById("upcoming-pieces");
+	container.innerHTML = "";
+	document.getElementById("upcoming-pieces-label").hidden = !pieces?.length;
+	for (const piece of pieces ?? []) {
+		const previewGrid = document.createElement("div");
+		previewGrid.classList.add("upcoming-piece");
+		container.appendChild(previewGrid);
+		drawPiecePreview(previewGrid, piece.masks[0], upcomingPieceColor, previewMinGridSize);
+	}
+}
 
 // Show a preview of a piece on the game board with top left of the piece at index.
 // Unsets className on all other cells.
@@ -308,7 +423,9 @@ function gbPreviewUpdateBoardPlacedBite(gbElem, index, biteMask) {
 	for (let stop = Math.min(i+pieceOffsets.length,cells.length); i < stop; i++) {
 		if ( pieceOffsets[i-index] !== 0 ) {
 			if ( i % boardCols > (i-index) % boardCols ) { // prevent line wrap
-				cells[i].classList.remove("player1", "player2", "player3", "player4");
+				for (let p=1; p<=maxPlayers; p++) {
+					cells[i].classList.remove(`player${p}`);
+				}
 			}
 		}
 	}
@@ -337,6 +454,44 @@ function updateBites(bites) {
 	}
 }
 
+// formats milliseconds as m:ss
+function formatTimeLeft(ms) {
+	const seconds = Math.max(0, Math.ceil(ms / 1000));
+	return `${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, "0")}`;
+}
+
+// Show the time left for each player. The player whose turn it is counts down
+// locally from the last game_info message.
+function updateTimeLeft() {
+	for ( let i=0; i<maxPlayers; i++ ) {
+		const rowElem = document.getElementById(`player${i+1}-time-row`);
+		if ( rowElem === null ) {
+			continue;
+		}
+		if ( timeLeft === null || i >= timeLeft.length ) {
+			rowElem.hidden = true;
+			continue;
+		}
+		rowElem.hidden = false;
+		let ms = timeLeft[i];
+		if ( i === currentTurn || simultaneous ) {
+			ms -= Date.now() - timeLeftReceived;
+		}
+		document.getElementById(`player${i+1}-time`).innerText = formatTimeLeft(ms);
+	}
+}
+
+// show the current round when the game has a round limit
+function updateRoundInfo(round, maxRounds) {
+	const roundInfoElem = document.getElementById("round-info");
+	roundInfoElem.hidden = !maxRounds;
+	if ( !maxRounds ) {
+		return;
+	}
+	document.getElementById("round").innerText = Math.min(round + 1, maxRounds);
+	document.getElementById("max-rounds").innerText = maxRounds;
+}
+
 function updateRerolls(rerolls) {
 	for ( let i=0; i<rerolls.length; i++ ) {
 		const playerRerollsElem = document.getElementById(`player${i+1}-rerolls`);
@@ -384,13 +539,42 @@ function sendReroll() {
 	socket.send(JSON.stringify(gameUpdate));
 }
 
+function sendHoldPiece() {
+	const gameUpdate = {
+		type: "game_update",
+		payload: {
+			action: "hold_piece",
+		}
+	};
+	console.log(gameUpdate);
+	socket.send(JSON.stringify(gameUpdate));
+}
+
+// Show the piece each player is holding. heldPieces is null if holding isn't allowed.
+function updateHeldPieces(heldPieces) {
+	for ( let i=0; i<maxPlayers; i++ ) {
+		const rowElem = document.getElementById(`player${i+1}-held-row`);
+		if ( rowElem === null ) {
+			continue;
+		}
+		if ( heldPieces === null || i >= heldPieces.length ) {
+			rowElem.hidden = true;
+			continue;
+		}
+		rowElem.hidden = false;
+		const color = playerInfo[i]?.color ?? "#000";
+		drawPiecePreview(document.getElementById(`player${i+1}-held`), heldPieces[i].masks[0], color, previewMinGridSize);
+	}
+}
+
 // Show an indicator on the info of the player whose turn it is.
 // Remove the indicator from all other players.
 // Passing an invalid turn like -1 unselects all.
-function updatePlayerTurnIndicator(turn) {
+// With simultaneous turns, waiting[i] is true for every player that still has to submit a move.
+function updatePlayerTurnIndicator(turn, waiting=null) {
 	for (let i=0; i<playerInfo.length; i++) {
 		const pInfoElem = document.getElementById(`player${i+1}-info`);
-		if (i === turn) {
+		if (waiting ? waiting[i] : i === turn) {
 			pInfoElem.classList.add("player-turn");
 		} else {
 			pInfoElem.classList.remove("player-turn");
@@ -398,50 +582,67 @@ function updatePlayerTurnIndicator(turn) {
 	}
 }
 
-function advanceBite() {
-	const biteNames = Object.keys(biteNameToMask);
-	for (let i = 0; i < biteNames.length; i++) {
-		if (biteNameToMask[biteNames[i]] === bite) {
-			let nextKey = biteNames[(i + 1) % biteNames.length];
-			bite = biteNameToMask[nextKey];
-			activateBiteButton(nextKey);
-			updateBiteCostPreview(nextKey);
-			return;
-		}
+// creates a button for each bite of the game, unless the bites haven't changed
+function updateBiteButtons(shapes) {
+	if ( JSON.stringify(shapes) === JSON.stringify(biteShapes) ) {
+		return;
+	}
+	biteShapes = shapes;
+	biteButtonsElem.replaceChildren();
+	for (let i = 0; i < biteShapes.length; i++) {
+		const btn = document.createElement("button");
+		btn.id = `bite${i}`;
+		btn.title = `cost: ${biteShapes[i].cost}, shortcut key: b`;
+		btn.classList.add("sendState");
+		btn.dataset.mask = biteShapes[i].mask;
+		btn.innerText = biteMaskToName[biteShapes[i].mask] ?? `Bite ${i+1}`;
+		btn.onclick = () => toggleBite(btn);
+		biteButtonsElem.appendChild(btn);
 	}
 }
 
+// returns the cost of bite mask, 0 for no bite
+function biteCostOf(mask) {
+	return biteShapes.find((b) => b.mask === mask)?.cost ?? 0;
+}
+
+// selects the next bite, or no bite after the last one
+function advanceBite() {
+	const masks = [0, ...biteShapes.map((b) => b.mask)];
+	const i = masks.indexOf(bite);
+	bite = masks[(i + 1) % masks.length];
+	activateBiteButton(bite);
+	updateBiteCostPreview(bite);
+}
+
 // toggles between no bite and the bite defined by elem
 function toggleBite(elem) {
-	const bmask = biteNameToMask[elem.id];
+	const bmask = Number(elem.dataset.mask);
 	if ( bite === bmask ) {
 		// clicking the already selected bite toggles it off
 		bite = 0;
-		activateBiteButton("noBite");
-		updateBiteCostPreview("noBite");
 	} else {
 		bite = bmask;
-		activateBiteButton(elem.id);
-		updateBiteCostPreview(elem.id);
 	}
+	activateBiteButton(bite);
+	updateBiteCostPreview(bite);
 }
 
-// marks a bite button as being selected
-function activateBiteButton(buttonId) {
-	const inactive = ["smallBite","largeBite"].filter(v => v !== buttonId);
-	for (let id of inactive) {
-		document.getElementById(id).classList.remove("active");
-	}
-	const active = ["smallBite","largeBite"].find(v => v === buttonId);
-	if ( active !== undefined ) {
-		document.getElementById(buttonId).classList.add("active");
+// marks the button of bite mask as being selected
+function activateBiteButton(mask) {
+	for (const btn of biteButtonsElem.children) {
+		if ( Number(btn.dataset.mask) === mask ) {
+			btn.classList.add("active");
+		} else {
+			btn.classList.remove("active");
+		}
 	}
 }
 
-function updateBiteCostPreview(biteName) {
+function updateBiteCostPreview(mask) {
 	const biteChangeElem = document.getElementById(`player${playerNumber}-bite-change-indicator`);
-	const cost = biteNameToCost[biteName];
-	if ( biteChangeElem === null || cost === undefined ) {
+	const cost = biteCostOf(mask);
+	if ( biteChangeElem === null ) {
 		console.log("updateBiteCostPreview() is missing expected data");
 		return;
 	}
@@ -507,6 +708,50 @@ function restartGame() {
 	socket.send(JSON.stringify(update));
 }
 
+function requestUndo() {
+	clearMessages();
+	sendUndoAction("request_undo");
+}
+
+// action is one of: request_undo, accept_undo, decline_undo
+function sendUndoAction(action) {
+	const update = {
+		type: "game_update",
+		payload: {
+			action: action,
+		}
+	};
+	//console.log(update);
+	socket.send(JSON.stringify(update));
+}
+
+// Show the pending undo request. Players who still need to answer get
+// accept and decline buttons. The requester is told if it was declined.
+// updates globals: undoRequest
+function updateUndoRequest(request) {
+	const undoElem = document.getElementById("undo_request");
+	if ( request === null ) {
+		if ( undoRequest?.requester === playerIndex && currentTurn !== undoRequest.requester ) {
+			displayWarning("Your undo request was declined");
+		}
+		undoRequest = null;
+		undoElem.innerHTML = "";
+		return;
+	}
+
+	undoRequest = request;
+	const requesterName = playerInfo[request.requester]?.name ?? "undefined";
+	if ( request.pending.includes(playerIndex) ) {
+		undoElem.innerHTML =
+			`<p>${requesterName} wants to undo their last move.</p>` +
+			`<button onclick="sendUndoAction('accept_undo')">Accept</button>` +
+			`<button onclick="sendUndoAction('decline_undo')">Decline</button>`;
+	} else {
+		const waitingFor = request.pending.map(i => playerInfo[i]?.name ?? "undefined").join(", ");
+		undoElem.innerHTML = `<p>Undo requested by ${requesterName}. Waiting for: ${waitingFor}</p>`;
+	}
+}
+
 function forfeitGame() {
 	clearMessages();
 	const update = {
@@ -551,7 +796,7 @@ function gbPlacePiece(gbElem, index) {
 		};
 		console.log(boardUpdate);
 		socket.send(JSON.stringify(boardUpdate));
-		updateBiteCostPreview("noBite");
+		updateBiteCostPreview(0);
 	}
 }
 
@@ -574,7 +819,7 @@ function gbHoverHandler(event) {
 	if (!event.target.classList.contains('cell')) {
 		return;
 	}
-	if ( currentTurn !== playerIndex ) {
+	if ( !myTurn ) {
 		return;
 	}
 
@@ -591,7 +836,7 @@ function gbTouchStartHandler(event) {
 	if (!event.target.classList.contains('cell')) {
 		return;
 	}
-	if ( currentTurn !== playerIndex ) {
+	if ( !myTurn ) {
 		return;
 	}
 
@@ -601,7 +846,7 @@ function gbTouchStartHandler(event) {
 }
 
 function gbTouchMoveHandler(event) {
-	if ( currentTurn !== playerIndex ) {
+	if ( !myTurn ) {
 		return;
 	}
 
@@ -624,7 +869,7 @@ function gbTouchMoveHandler(event) {
 
 function gbTouchEndHandler(event) {
 	event.preventDefault();
-	if ( currentTurn !== playerIndex ) {
+	if ( !myTurn ) {
 		return;
 	}
 
@@ -708,7 +953,7 @@ function gbPreviewSendClear() {
 }
 
 function gbCancelPreviewHandler(event) {
-	if ( currentTurn !== playerIndex ) {
+	if ( !myTurn ) {
 		return;
 	}
 
@@ -746,7 +991,7 @@ function getPreviewIndexForArrowKey(key) {
 function gameKeydownHandler(event) {
 	//console.log(`gameKeydownHandler ${event.code}: "${event.key}"`);
 
-  if ( currentTurn === playerIndex ) {
+  if ( myTurn ) {
 		switch (event.key) {
 			case "ArrowUp": // move piece preview
 			case "ArrowDown":
@@ -773,6 +1018,11 @@ function gameKeydownHandler(event) {
 					pieceSelectNextRotation();
 				}
 				break;
+			case "f": // flip piece
+				if ( bite === 0 ) {
+					pieceSelectFlip();
+				}
+				break;
 			case "b": // bite
 				advanceBite();
 				gbClearHoverClasses(gbElem);
@@ -789,6 +1039,17 @@ function gameKeydownHandler(event) {
 				sendButtonNotificationUpdate(rerollBtn);
 				sendReroll();
 				break;
+			case "1": // choose an offered piece
+			case "2":
+			case "3":
+			case "4":
+				selectOfferedPiece(Number(event.key) - 1);
+				break;
+			case "h": // hold
+				buttonDisplayNotification(holdPieceBtn);
+				sendButtonNotificationUpdate(holdPieceBtn);
+				sendHoldPiece();
+				break;
 			case "s": // skip turn
 				buttonDisplayNotification(skipTurnBtn);
 				sendButtonNotificationUpdate(skipTurnBtn);
@@ -892,6 +1153,8 @@ function gameWsDispatch(socket, event) {
 			gameWsHandleMsgButtonInfo(socket, data);
 		} else if (data.type === 'game_info') {
 			gameWsHandleMsgGameInfo(socket, data);
+		} else if (data.type === 'game_history') {
+			gameWsHandleMsgGameHistory(socket, data);
 		} else if (data.type === 'error') {
 			displayError(data.payload.message);
 		} else if (data.type === 'player_info') {
@@ -906,7 +1169,7 @@ function gameWsDispatch(socket, event) {
 	}
 }
 
-// updates globals: playerIndex, playerNumber, playerClass, playerInfo
+// updates globals: playerIndex, playerNumber, playerClass, playerInfo, playerTeams
 function gameWsHandleMsgPlayerInfo(_socket, data) {
 	console.log(data);
 	if (
@@ -925,6 +1188,7 @@ function gameWsHandleMsgPlayerInfo(_socket, data) {
 	playerNumber = playerIndex + 1;
 	playerClass = `player${playerNumber}`;
 	playerInfo = data.payload.players;
+	playerTeams = data.payload.teams ?? null;
 
 
 	// handle player list
@@ -939,7 +1203,10 @@ function gameWsHandleMsgPlayerInfo(_socket, data) {
 
 	let i=0;
 	for (; i<data.payload.players.length; i++) {
-		const name = data.payload.players[i].name;
+		let name = data.payload.players[i].name;
+		if ( playerTeams ) {
+			name += ` (Team ${playerTeams[i] + 1})`;
+		}
 		const color = data.payload.players[i].color;
 		const playerId = `player${i+1}`;
 		document.getElementById(`${playerId}-name`).innerText = name;
@@ -972,13 +1239,13 @@ function gameWsHandleMsgButtonInfo(_socket, data) {
 
 	if ( data.payload.inactive?.length !== undefined ) {
 		for (let i = 0; i < data.payload.inactive.length; i++) {
-			document.getElementById(data.payload.inactive[i]).classList.remove("active");
+			document.getElementById(data.payload.inactive[i])?.classList.remove("active");
 		}
 	}
 
 	if ( data.payload.active?.length !== undefined ) {
 		for (let i = 0; i < data.payload.active.length; i++) {
-			document.getElementById(data.payload.active[i]).classList.add("active");
+			document.getElementById(data.payload.active[i])?.classList.add("active");
 		}
 	}
 
@@ -989,7 +1256,7 @@ function gameWsHandleMsgButtonInfo(_socket, data) {
 	}
 }
 
-// updates globals: board, boardCols, boardRows, currentTurn, currentRotation, nextPiece, playerBites, playerRerolls
+// updates globals: board, boardCols, boardRows, currentTurn, simultaneous, myTurn, pendingMove, currentRotation, nextPiece, playerBites, playerRerolls, undoRequest, timeLeft
 function gameWsHandleMsgGameInfo(_socket, data) {
 	console.log(data);
 	if (
@@ -1005,6 +1272,9 @@ function gameWsHandleMsgGameInfo(_socket, data) {
 		throw new Error(`Missing expected payload for message type ${data.type}`);
 	}
 
+	simultaneous = data.payload.simultaneous ?? false;
+	pendingMove = data.payload.pending_move ?? null;
+
 	updateGameScores(data.payload.scores);
 	updateBites(data.payload.bites);
 	playerBites = data.payload.bites[playerIndex];
@@ -1014,20 +1284,23 @@ function gameWsHandleMsgGameInfo(_socket, data) {
 	if ( data.payload.game_over ) {
 		clearMessages();
 		bite = 0;
-		let winner = "undefined";
-		for ( let i=0; i<data.payload.scores.length; i++ ) {
-			if ( data.payload.scores[i] > 0 ) {
-				winner = playerInfo[i].name;
-				break;
-			}
+		const winnerIndexes = data.payload.winners || [];
+		const winners = winnerIndexes.map((i) => playerInfo[i].name);
+		if ( winners.length === 1 ) {
+			document.getElementById("game_over").innerText = `${winners[0]} wins!`;
+		} else if ( playerTeams && winners.length > 1 && winnerIndexes.every((i) => playerTeams[i] === playerTeams[winnerIndexes[0]]) ) {
+			document.getElementById("game_over").innerText = `Team ${playerTeams[winnerIndexes[0]] + 1} (${winners.join(" and ")}) wins!`;
+		} else if ( winners.length > 1 ) {
+			document.getElementById("game_over").innerText = `Draw between ${winners.join(" and ")}!`;
+		} else {
+			document.getElementById("game_over").innerText = "Game over!";
 		}
-		document.getElementById("game_over").innerText = `${winner} wins!`;
 	} else {
 		document.getElementById("game_over").innerText = "";
 	}
 
-	const cols = data.payload.board.length;
-	const rows = data.payload.board[0].length;
+	const rows = data.payload.board.length;
+	const cols = data.payload.board[0].length;
 	if ( cols !== boardCols || rows !== boardRows ) {
 		boardCols = cols;
 		boardRows = rows;
@@ -1039,39 +1312,93 @@ function gameWsHandleMsgGameInfo(_socket, data) {
 		(async () => {
 			await animateBoardUpdates(data.payload.board_updates_to_animate);
 			updateGameBoard(gbElem, board);
+			gbShowPendingMove(gbElem);
 		})();
 	} else {
 		boardIsAnimating = false;
 		updateGameBoard(gbElem, board);
+		gbShowPendingMove(gbElem);
 	}
 
-	nextPiece = data.payload.next_piece;
+	offeredPieces = data.payload.offered_pieces ?? [data.payload.next_piece];
 	if (currentTurn != data.payload.turn ) {
-		// conditional prevents invalid moves from resetting the selected rotation or bite
+		// conditional prevents invalid moves from resetting the selected rotation, bite or piece
 		currentRotation = 0;
 		bite = 0;
+		selectedOffer = 0;
 		currentTurn = data.payload.turn;
 	}
+	if ( selectedOffer >= offeredPieces.length ) {
+		selectedOffer = 0;
+	}
+	nextPiece = offeredPieces[selectedOffer];
+	if ( !nextPiece.masks[currentRotation] ) {
+		// a reroll or hold may leave fewer orientations than the selected one
+		currentRotation = 0;
+	}
+	updateOfferedPieces();
+
+	// with simultaneous turns, every player that is still in the game moves until they submit a move
+	let waiting = null;
+	if ( simultaneous && data.payload.submitted ) {
+		waiting = data.payload.submitted.map((s, i) => !s && !data.payload.game_over && data.payload.scores[i] > 0);
+		myTurn = waiting[playerIndex] ?? false;
+	} else {
+		myTurn = currentTurn === playerIndex;
+	}
 
 	// enable/disable buttons based on the current turn
-	const disabled = currentTurn !== playerIndex;
+	const disabled = !myTurn;
 	rotatePieceBtn.disabled = disabled;
+	flipPieceBtn.hidden = !data.payload.mirror_pieces;
+	flipPieceBtn.disabled = disabled || !pieceCanFlip(nextPiece.masks);
 	skipTurnBtn.disabled = disabled;
-	smallBiteBtn.disabled = disabled || playerBites < biteNameToCost["smallBite"];
-	largeBiteBtn.disabled = disabled || playerBites < biteNameToCost["largeBite"];
+	updateBiteButtons(data.payload.bite_shapes ?? []);
+	for (const btn of biteButtonsElem.children) {
+		btn.disabled = disabled || playerBites < biteCostOf(Number(btn.dataset.mask));
+	}
 	rerollBtn.disabled = disabled || playerRerolls < 1;
+	holdPieceBtn.hidden = data.payload.held_pieces == null;
+	holdPieceBtn.disabled = disabled || !data.payload.can_hold;
+	updateHeldPieces(data.payload.held_pieces ?? null);
+	undoMoveBtn.disabled = data.payload.undo_player !== playerIndex || data.payload.undo_request !== null;
+	updateUndoRequest(data.payload.undo_request);
+
+	activateBiteButton(bite);
+	updateBiteCostPreview(bite);
 
-	activateBiteButton(biteMaskToName[bite]);
-	updateBiteCostPreview(biteMaskToName[bite]);
+	updatePlayerTurnIndicator(currentTurn, waiting);
 
-	updatePlayerTurnIndicator(currentTurn);
+	timeLeft = data.payload.time_left_ms ?? null;
+	timeLeftReceived = Date.now();
+	updateTimeLeft();
+	if ( timeLeft !== null && timeLeftIntervalId === null ) {
+		timeLeftIntervalId = setInterval(updateTimeLeft, 250);
+	}
 
 	updateNextTurnPreview(
 		nextTurnPreviewElem,
-		currentTurn,
+		simultaneous ? playerIndex : currentTurn,
 		nextPiece.masks[currentRotation],
 		playerInfo
 	);
+	updateUpcomingPieces(data.payload.upcoming_pieces);
+	updateRoundInfo(data.payload.round, data.payload.max_rounds);
+}
+
+// updates globals: gameHistory
+function gameWsHandleMsgGameHistory(_socket, data) {
+	console.log(data);
+	if (
+		!data.payload ||
+		data.payload.offset == null ||
+		!Array.isArray(data.payload.events)
+	) {
+		throw new Error(`Missing expected payload for message type ${data.type}`);
+	}
+
+	gameHistory.length = Math.min(gameHistory.length, data.payload.offset);
+	gameHistory.push(...data.payload.events);
 }
 
 function gameWsHandleMsgBoardUpdatePreview(_socket, data) {
@@ -1126,7 +1453,12 @@ function gameWsHandleMsgBoardUpdatePreview(_socket, data) {
 			return;
 	}
 
-	if ( data.payload.mask !== currentPreviewPieceMask && data.payload.action.match(/_piece$/) ) {
+	// with fog of war, previews may only show part of the piece
+	if (
+		data.payload.mask !== currentPreviewPieceMask &&
+		data.payload.action.match(/_piece$/) &&
+		offeredPieces.some((p) => p.masks.includes(data.payload.mask))
+	) {
 		updateNextTurnPreview(
 			nextTurnPreviewElem,
 			currentTurn,
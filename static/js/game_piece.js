@@ -6,8 +6,9 @@
 
 // Returns the bitmask at position (row, column).
 // Top left is (0,0).
+// Masks are wider than 32 bits, so they are handled with arithmetic instead of bitwise operators.
 function pieceMaskAt(r, c) {
-	return 1 << ((pieceMaskMaxLength-1-r)*pieceMaskMaxLength + (pieceMaskMaxLength - 1 - c))
+	return 2 ** ((pieceMaskMaxLength-1-r)*pieceMaskMaxLength + (pieceMaskMaxLength - 1 - c));
 }
 
 // Check if a given PieceMask has a bit set at (row, column).
@@ -16,7 +17,21 @@ function pieceHas(p, r, c) {
 	if (r >= pieceMaskMaxLength || c >= pieceMaskMaxLength) {
 		return false;
 	}
-	return (p & pieceMaskAt(r, c)) !== 0;
+	return Math.floor(p / pieceMaskAt(r, c)) % 2 === 1;
+}
+
+// Converts a length by length mask to a pieceMaskMaxLength by pieceMaskMaxLength mask,
+// e.g. for custom pieces saved before masks were widened
+function pieceResizeMask(p, length) {
+	let resized = 0;
+	for (let r = 0; r < length; r++) {
+		for (let c = 0; c < length; c++) {
+			if ( Math.floor(p / 2 ** ((length-1-r)*length + (length-1-c))) % 2 === 1 ) {
+				resized += pieceMaskAt(r, c);
+			}
+		}
+	}
+	return resized;
 }
 
 function pieceString2D(pmask) {
@@ -42,21 +57,18 @@ function pieceString2D(pmask) {
 
 // get the size of a piece mask
 function pieceGetSize(pmask) {
-	var r = 0;
-	for (let i = 0; i < pieceMaskMaxLength; i++) {
-		if ( (pmask & (pieceMaskFirstRowMask >>> (pieceMaskMaxLength*i))) !== 0 ) {
-			r = i+1;
-		}
-	}
-
-	var c = 0;
-	for (let i = 0; i < pieceMaskMaxLength; i++) {
-		if ( (pmask & (pieceMaskFirstColumnMask >>> i)) !== 0 ) {
-			c = i+1;
+	var rows = 0;
+	var cols = 0;
+	for (let r = 0; r < pieceMaskMaxLength; r++) {
+		for (let c = 0; c < pieceMaskMaxLength; c++) {
+			if ( pieceHas(pmask, r, c) ) {
+				rows = Math.max(rows, r+1);
+				cols = Math.max(cols, c+1);
+			}
 		}
 	}
 
-	return [r, c];
+	return [rows, cols];
 }
 
 // draws pieceMask in div container
@@ -93,4 +105,38 @@ function drawPiecePreview(container, pieceMask, color, minGridSize) {
 
 }
 
+// Piece masks hold the distinct rotations of a piece, followed by the rotations of its
+// mirror image when the game allows mirrored pieces. Unused masks are 0.
+
+// get the number of distinct rotations of a piece
+function pieceRotationCount(masks) {
+	let count = 0;
+	while (count < maxPieceRotations && masks[count] !== 0) {
+		count++;
+	}
+	return Math.max(count, 1);
+}
+
+// get the index of the mask after rotating the mask at index i clockwise
+function pieceNextRotation(masks, i) {
+	const base = i - (i % maxPieceRotations);
+	const count = pieceRotationCount(masks);
+	return base + ((i - base + 1) % count);
+}
+
+// check if a piece can be flipped
+function pieceCanFlip(masks) {
+	return masks.length > maxPieceRotations && masks[maxPieceRotations] !== 0;
+}
+
+// get the index of the mirror image of the mask at index i
+function pieceFlipped(masks, i) {
+	if (!pieceCanFlip(masks)) {
+		return i;
+	}
+	const rotation = i % maxPieceRotations;
+	const base = i < maxPieceRotations ? maxPieceRotations : 0;
+	return base + ((maxPieceRotations - rotation) % maxPieceRotations) % pieceRotationCount(masks);
+}
+
 // vim: ts=2
@@ -5,30 +5,109 @@
 const piecePreviewColor = "green";
 
 const idToDefaultValue = {
-	"board-size-slider":             gbDefaultSize,
+	"board-rows-slider":             gbDefaultRows,
+	"board-cols-slider":             gbDefaultCols,
 	"rand-start-pos-checkbox":       gbDefaultRandomizeStartPos,
+	"teams-checkbox":                false,
+	"team-shared-paths-checkbox":    false,
 	"starting-bites-slider":         gbDefaultStartBites,
-	"bonus-bite-cells-checkbox":     gbDefaultHasBonusBiteCells,
+	"bonus-bite-cells-slider":       gbDefaultBonusBiteCells,
+	"bonus-bite-award-slider":       gbDefaultBonusBiteAward,
 	"starting-rerolls-slider":       gbDefaultStartRerolls,
 	"bonus-reroll-cells-slider":     gbDefaultBonusRerollCells,
+	"bonus-reroll-award-slider":     gbDefaultBonusRerollAward,
+	"bonus-placement-choice":        "",
+	"bonus-home-clearance-slider":   gbDefaultBonusHomeClearance,
+	"shield-cells-slider":           gbDefaultShieldCells,
+	"multiplier-cells-slider":       gbDefaultMultiplierCells,
+	"spore-cells-slider":            gbDefaultSporeCells,
 	"new-bite-freq-factor-slider":   gbDefaultNewBiteFreqFactor,
 	"capture-mode-choice":           "",
+	"capture-diagonals-checkbox":    gbDefaultCaptureDiagonals,
+	"capture-min-length-slider":     gbDefaultCaptureMinLength,
+	"capture-max-length-slider":     gbDefaultCaptureMaxLength,
+	"capture-chains-checkbox":       gbDefaultCaptureChains,
+	"capture-home-cells-checkbox":   gbDefaultCaptureHomeCells,
+	"capture-past-homes-checkbox":   gbDefaultCaptureThroughHomeCells,
+	"win-territory-slider":          gbDefaultWinTerritory,
+	"win-score-slider":              gbDefaultWinScore,
+	"max-rounds-slider":             gbDefaultMaxRounds,
+	"tie-break-choice":              "",
+	"wall-mode-choice":              "",
+	"wall-density-slider":           gbDefaultWallDensity,
+	"map-choice":                    "",
+	"timer-mode-choice":             "",
+	"turn-time-slider":              gbDefaultTurnTime,
+	"clock-time-slider":             gbDefaultClockTime,
+	"clock-increment-slider":        gbDefaultClockIncrement,
+	"timeout-action-choice":         "",
+	"fog-of-war-slider":             gbDefaultFogOfWar,
+	"simultaneous-checkbox":         false,
+	"piece-distribution-choice":     "",
+	"piece-queue-length-slider":     gbDefaultPieceQueueLength,
+	"allow-hold-checkbox":           gbDefaultAllowHold,
+	"draft-size-slider":             gbDefaultDraftSize,
+	"mirror-pieces-checkbox":        false,
+	"seed-input":                    "",
 	"use-custom-piece-set-checkbox": false,
+	"use-custom-bites-checkbox":     false,
 };
 
 const idToJoinGameArg = {
-	"board-size-slider":           "size",
+	"board-rows-slider":           "rows",
+	"board-cols-slider":           "cols",
 	"rand-start-pos-checkbox":     "randomize_start_positions",
+	"teams-checkbox":              "teams",
+	"team-shared-paths-checkbox":  "team_shared_paths",
 	"starting-bites-slider":       "starting_bites",
-	"bonus-bite-cells-checkbox":   "has_bonus_bite_cells",
+	"bonus-bite-cells-slider":     "bonus_bite_cells",
+	"bonus-bite-award-slider":     "bonus_bite_award",
 	"starting-rerolls-slider":     "starting_rerolls",
 	"bonus-reroll-cells-slider":   "bonus_reroll_cells",
+	"bonus-reroll-award-slider":   "bonus_reroll_award",
+	"bonus-placement-choice":      "bonus_placement",
+	"bonus-home-clearance-slider": "bonus_home_clearance",
+	"shield-cells-slider":         "shield_cells",
+	"multiplier-cells-slider":     "multiplier_cells",
+	"spore-cells-slider":          "spore_cells",
 	"new-bite-freq-factor-slider": "new_bites_freq_factor",
-	"capture-mode-choice":         "capture_mode"
+	"capture-mode-choice":         "capture_mode",
+	"capture-diagonals-checkbox":  "capture_diagonals",
+	"capture-min-length-slider":   "capture_min_length",
+	"capture-max-length-slider":   "capture_max_length",
+	"capture-chains-checkbox":     "capture_chains",
+	"capture-home-cells-checkbox": "capture_home_cells",
+	"capture-past-homes-checkbox": "capture_through_home_cells",
+	"win-territory-slider":        "win_territory",
+	"win-score-slider":            "win_score",
+	"max-rounds-slider":           "max_rounds",
+	"tie-break-choice":            "tie_break",
+	"wall-mode-choice":            "walls",
+	"wall-density-slider":         "wall_density",
+	"map-choice":                  "map",
+	"timer-mode-choice":           "timer",
+	"turn-time-slider":            "turn_time",
+	"clock-time-slider":           "clock_time",
+	"clock-increment-slider":      "clock_increment",
+	"timeout-action-choice":       "timeout_action",
+	"fog-of-war-slider":           "fog_of_war",
+	"simultaneous-checkbox":       "simultaneous",
+	"piece-distribution-choice":   "piece_distribution",
+	"piece-queue-length-slider":   "piece_queue_length",
+	"allow-hold-checkbox":         "allow_hold",
+	"draft-size-slider":           "draft_size",
+	"mirror-pieces-checkbox":      "mirror_pieces",
+	"seed-input":                  "seed"
 };
 
 const idToSelectOptionList = {
-	"capture-mode-choice": gameCaptureModes
+	"capture-mode-choice":       gameCaptureModes,
+	"wall-mode-choice":          gameWallModes,
+	"timer-mode-choice":         gameTimerModes,
+	"timeout-action-choice":     gameTimeoutActions,
+	"piece-distribution-choice": gamePieceDistributions,
+	"tie-break-choice":          gameTieBreaks,
+	"bonus-placement-choice":    gameBonusPlacements
 }
 
 let idToSavedValue = {};
@@ -92,27 +171,43 @@ function setupSelect(selectId) {
 
 function setupGameOptionInputs() {
 	// game board size
-	setupSlider("board-size-rows", "board-size-slider");
-	setupSlider("board-size-cols", "board-size-slider");
-
-	const boardSizeSlider = document.getElementById("board-size-slider");
-	boardSizeSlider.min = gbMinSize;
-	boardSizeSlider.max = gbMaxSize;
+	for (const sliderId of ["board-rows-slider", "board-cols-slider"]) {
+		const boardSizeSlider = document.getElementById(sliderId);
+		boardSizeSlider.min = gbMinSize;
+		boardSizeSlider.max = gbMaxSize;
+	}
+	setupSlider("board-size-rows", "board-rows-slider");
+	setupSlider("board-size-cols", "board-cols-slider");
 
 	// randomize start positions
 	setupCheckbox("rand-start-pos-checkbox");
 
+	// teams
+	setupCheckbox("teams-checkbox");
+	setupCheckbox("team-shared-paths-checkbox");
+
 	// starting bites
 	setupSlider("starting-bites", "starting-bites-slider");
 
 	// bonus bite cells
-	setupCheckbox("bonus-bite-cells-checkbox");
+	setupSlider("bonus-bite-cells", "bonus-bite-cells-slider");
+	setupSlider("bonus-bite-award", "bonus-bite-award-slider");
 
 	// starting rerolls
 	setupSlider("starting-rerolls", "starting-rerolls-slider");
 
 	// bonus reroll cells
 	setupSlider("bonus-reroll-cells", "bonus-reroll-cells-slider");
+	setupSlider("bonus-reroll-award", "bonus-reroll-award-slider");
+
+	// bonus cell placement
+	setupSelect("bonus-placement-choice");
+	setupSlider("bonus-home-clearance", "bonus-home-clearance-slider");
+
+	// special cells
+	setupSlider("shield-cells", "shield-cells-slider");
+	setupSlider("multiplier-cells", "multiplier-cells-slider");
+	setupSlider("spore-cells", "spore-cells-slider");
 
 	// new bite frequency adjustment
 	setupSlider("new-bite-freq-factor", "new-bite-freq-factor-slider");
@@ -120,6 +215,65 @@ function setupGameOptionInputs() {
 	// game capture mode
 	setupSelect("capture-mode-choice");
 
+	// capture rules
+	setupCheckbox("capture-diagonals-checkbox");
+	for (const sliderId of ["capture-min-length-slider", "capture-max-length-slider"]) {
+		document.getElementById(sliderId).max = gbMaxCaptureLength;
+	}
+	setupSlider("capture-min-length", "capture-min-length-slider");
+	setupSlider("capture-max-length", "capture-max-length-slider");
+	setupCheckbox("capture-chains-checkbox");
+	setupCheckbox("capture-home-cells-checkbox");
+	setupCheckbox("capture-past-homes-checkbox");
+
+	// win conditions
+	setupSlider("win-territory", "win-territory-slider");
+	setupSlider("win-score", "win-score-slider");
+	document.getElementById("max-rounds-slider").max = gbMaxRounds;
+	setupSlider("max-rounds", "max-rounds-slider");
+	setupSelect("tie-break-choice");
+
+	// walls
+	setupSelect("wall-mode-choice");
+	document.getElementById("wall-density-slider").max = gbMaxWallDensity;
+	setupSlider("wall-density", "wall-density-slider");
+
+	// maps
+	setupMapSelect();
+
+	// timers
+	setupSelect("timer-mode-choice");
+	document.getElementById("turn-time-slider").min = gbMinTurnTime;
+	document.getElementById("turn-time-slider").max = gbMaxTurnTime;
+	setupSlider("turn-time", "turn-time-slider");
+	document.getElementById("clock-time-slider").max = gbMaxClockTime;
+	setupSlider("clock-time", "clock-time-slider");
+	document.getElementById("clock-increment-slider").max = gbMaxClockIncrement;
+	setupSlider("clock-increment", "clock-increment-slider");
+	setupSelect("timeout-action-choice");
+
+	// fog of war
+	document.getElementById("fog-of-war-slider").max = gbMaxFogOfWar;
+	setupSlider("fog-of-war", "fog-of-war-slider");
+
+	// simultaneous turns
+	setupCheckbox("simultaneous-checkbox");
+
+	// piece order and queue
+	setupSelect("piece-distribution-choice");
+	document.getElementById("piece-queue-length-slider").max = gbMaxPieceQueueLength;
+	setupSlider("piece-queue-length", "piece-queue-length-slider");
+
+	// hold
+	setupCheckbox("allow-hold-checkbox");
+
+	// piece draft
+	document.getElementById("draft-size-slider").max = gbMaxDraftSize;
+	setupSlider("draft-size", "draft-size-slider");
+
+	// mirrored pieces
+	setupCheckbox("mirror-pieces-checkbox");
+
 	// use custom piece set
 	setupCheckbox("use-custom-piece-set-checkbox");
 	const customPieceCheckbox = document.getElementById("use-custom-piece-set-checkbox");
@@ -129,11 +283,25 @@ function setupGameOptionInputs() {
 
 	// generate table for custom pieces and maybe display it
 	if ( idToSavedValue.pieces?.data?.length ) {
-		generateCustomPieceTable("custom-pieces-table", idToSavedValue.pieces.data);
+		// pieces saved before masks were widened don't have a mask_length
+		const maskLength = idToSavedValue.pieces.mask_length ?? legacyPieceMaskLength;
+		generateCustomPieceTable("custom-pieces-table", idToSavedValue.pieces.data.map((piece) => (
+			{"mask": pieceResizeMask(piece.mask, maskLength), "weight": piece.weight}
+		)));
 	} else {
 		generateCustomPieceTable("custom-pieces-table", gbDefaultPieces);
 	}
 	toggleShowHideCustomPieceOptions(customPieceCheckbox);
+
+	// use custom bites
+	setupCheckbox("use-custom-bites-checkbox");
+	document.getElementById("max-bite-shapes").innerText = gbMaxBiteShapes;
+	const customBitesCheckbox = document.getElementById("use-custom-bites-checkbox");
+	customBitesCheckbox.addEventListener("input", () => {
+		toggleShowHideCustomBiteOptions(event.target);
+	});
+	generateCustomBiteTable("custom-bites-table", idToSavedValue.bites?.data?.length ? idToSavedValue.bites.data : gbDefaultBites);
+	toggleShowHideCustomBiteOptions(customBitesCheckbox);
 }
 
 function generateCustomPieceTable(id, pieceList) {
@@ -157,6 +325,28 @@ function generateCustomPieceTable(id, pieceList) {
 	});
 }
 
+// generates the custom bites table. Bites reuse the piece rows, with the cost in the weight column.
+function generateCustomBiteTable(id, biteList) {
+	const table = document.getElementById(id);
+	table.replaceChildren();
+
+	const thead = document.createElement("thead");
+	thead.innerHTML = `
+		<th></th>
+		<th>Bite</th>
+		<th></th>
+		<th title="Hint: Leave cost empty to use the default cost">Cost</th>
+`;
+	table.appendChild(thead);
+
+	const tbody = document.createElement("tbody");
+	table.appendChild(tbody);
+
+	biteList.forEach(bite => {
+		addCustomPieceRowToTable(table, {"mask": bite.mask, "weight": bite.cost ?? ""});
+	});
+}
+
 // add a piece customization row to the table containing el
 function addCustomPieceRowToTable(el, piece={"mask":0,"weight":0} ) {
 	const table = el.closest("table");
@@ -239,14 +429,13 @@ function modifyPieceHandler(event) {
 
 	const r = Math.floor(index / pieceMaskMaxLength);
 	const c = index % pieceMaskMaxLength;
-	const isSet = cells[index].classList.contains("occupied");
 	const hiddenInput = this.parentElement.querySelector('input');
-	let pmask = hiddenInput.value;
+	let pmask = Number(hiddenInput.value);
 
-	if ( isSet ) {
-		pmask = pmask & ~pieceMaskAt(r,c);
+	if ( pieceHas(pmask, r, c) ) {
+		pmask -= pieceMaskAt(r,c);
 	} else {
-		pmask = pmask | pieceMaskAt(r,c);
+		pmask += pieceMaskAt(r,c);
 	}
 
 	hiddenInput.value = pmask;
@@ -272,6 +461,7 @@ function setGameOptionsToDefaults() {
 				el.dispatchEvent(new Event("input", {}));
 				break;
 			case "select-one":
+			case "text":
 				el.value = val;
 				break;
 			default:
@@ -280,6 +470,7 @@ function setGameOptionsToDefaults() {
 	}
 
 	generateCustomPieceTable("custom-pieces-table", gbDefaultPieces);
+	generateCustomBiteTable("custom-bites-table", gbDefaultBites);
 }
 
 function toggleShowHideCustomPieceOptions(elem) {
@@ -291,6 +482,15 @@ function toggleShowHideCustomPieceOptions(elem) {
 	}
 }
 
+function toggleShowHideCustomBiteOptions(elem) {
+	const customBitesTableElem = document.getElementById("custom-bites-container");
+	if ( elem.checked ) {
+		customBitesTableElem.style.display = "block";
+	} else {
+		customBitesTableElem.style.display = "none";
+	}
+}
+
 function getLobbyName() {
 	try {
 		return getCookie("lobby-name")
@@ -300,6 +500,47 @@ function getLobbyName() {
 	return undefined;
 }
 
+// Fill the map select with the maps the server has loaded. The row stays hidden
+// if there are none.
+async function setupMapSelect() {
+	const select = document.getElementById("map-choice");
+	const description = document.getElementById("map-description");
+
+	let maps;
+	try {
+		const response = await fetch("/game/maps", { headers: { Accept: "application/json" }});
+		if (!response.ok) {
+			throw new Error(`Response status: ${response.status}`);
+		}
+		maps = (await response.json()).maps;
+	} catch (error) {
+		console.error(error.message);
+		return;
+	}
+	if (!maps?.length) {
+		return;
+	}
+
+	const descriptions = {};
+	for (const m of maps) {
+		const newOption = document.createElement("option");
+		newOption.text = `${m.name} (${m.rows}x${m.cols}, ${m.players.join("/")} players)`;
+		newOption.value = m.name;
+		select.appendChild(newOption);
+		descriptions[m.name] = m.description;
+	}
+
+	const savedValue = idToSavedValue["map-choice"];
+	if (savedValue !== undefined && savedValue in descriptions) {
+		select.value = savedValue;
+	}
+	description.textContent = descriptions[select.value] ?? "";
+	select.addEventListener("input", () => {
+		description.textContent = descriptions[select.value] ?? "";
+	});
+	document.getElementById("map-row").hidden = false;
+}
+
 async function getLobbyMembers(lobby) {
 	const url = "/lobby/get";
 
@@ -360,13 +601,77 @@ async function updateLobbyMembers() {
 		td = tr.insertCell();
 		var p = document.createElement("p");
 		p.innerText = json.members[i].name;
+		if ( json.members[i].bot ) {
+			p.innerText += ` (${json.members[i].bot} bot)`;
+		}
 		td.appendChild(p);
+
+		if ( json.members[i].bot ) {
+			td = tr.insertCell();
+			const btn = document.createElement("button");
+			btn.type = "button";
+			btn.innerText = "Remove";
+			const botName = json.members[i].name;
+			btn.onclick = () => removeBot(botName);
+			td.appendChild(btn);
+		}
 	}
+	const playerCap = json.player_cap ?? maxPlayers;
+	document.getElementById("add_bot").disabled = json.members.length >= playerCap;
+	document.getElementById("player-cap-choice").value = playerCap;
 	lobby_div.replaceChildren(tbl);
 }
 
+function setupBotLevelSelect() {
+	const select = document.getElementById("bot-level-choice");
+	for (const level of botLevels) {
+		const option = document.createElement("option");
+		option.text = level;
+		option.value = level;
+		select.add(option);
+	}
+	select.value = botLevels[botLevels.length - 1];
+}
+
+function setupPlayerCapSelect() {
+	const select = document.getElementById("player-cap-choice");
+	for (let i = 2; i <= maxPlayers; i++) {
+		const option = document.createElement("option");
+		option.text = i;
+		option.value = i;
+		select.add(option);
+	}
+	select.value = maxPlayers;
+}
+
+async function setPlayerCap() {
+	const count = document.getElementById("player-cap-choice").value;
+	const response = await fetch(`/lobby/set-player-cap?count=${encodeURIComponent(count)}`);
+	if (!response.ok) {
+		console.error("setPlayerCap failed:", await response.text());
+	}
+	updateLobbyMembers();
+}
+
+async function addBot() {
+	const level = document.getElementById("bot-level-choice").value;
+	const response = await fetch(`/lobby/add-bot?level=${encodeURIComponent(level)}`);
+	if (!response.ok) {
+		console.error("addBot failed:", await response.text());
+	}
+	updateLobbyMembers();
+}
+
+async function removeBot(name) {
+	const response = await fetch(`/lobby/remove-bot?name=${encodeURIComponent(name)}`);
+	if (!response.ok) {
+		console.error("removeBot failed:", await response.text());
+	}
+	updateLobbyMembers();
+}
+
 function getCustomPieces() {
-	let pieces = {"data":[]};
+	let pieces = {"data":[], "mask_length": pieceMaskMaxLength};
 	const trs = document.getElementById("custom-pieces-table").querySelectorAll("tbody > tr");
 
 	trs.forEach((tr) => {
@@ -381,6 +686,26 @@ function getCustomPieces() {
 	return pieces;
 }
 
+function getCustomBites() {
+	let bites = {"data":[], "mask_length": pieceMaskMaxLength};
+	const trs = document.getElementById("custom-bites-table").querySelectorAll("tbody > tr");
+
+	trs.forEach((tr) => {
+		try {
+			const bmask = tr.querySelector('.piece-mask-input').value;
+			const bcost = tr.querySelector('.piece-weight-input').value;
+			const bite = {"mask": parseInt(bmask)};
+			if ( bcost !== "" ) {
+				bite.cost = parseInt(bcost);
+			}
+			bites.data.push(bite);
+		} catch (error) {
+			console.error("Error processing", tr, error.message);
+		}
+	});
+	return bites;
+}
+
 function startGame() {
 
 	// build args
@@ -408,6 +733,12 @@ function startGame() {
 					args[arg] = encodeURIComponent(lsObj[id]);
 				}
 				break;
+			case "text":
+				// not saved to localStorage so each game gets a new seed by default
+				if (el.value) {
+					args[arg] = encodeURIComponent(el.value);
+				}
+				break;
 			default:
 				console.log(`startGame(): skipping elem id=${id}, type=${el.type}`);
 		}
@@ -421,6 +752,13 @@ function startGame() {
 		args["pieces"] = encodeURIComponent(JSON.stringify(lsObj["pieces"]));
 	}
 
+	const customBitesCheckbox = document.getElementById("use-custom-bites-checkbox");
+	lsObj["use-custom-bites-checkbox"] = customBitesCheckbox.checked;
+	if (customBitesCheckbox.checked) {
+		lsObj["bites"] = getCustomBites();
+		args["bites"] = encodeURIComponent(JSON.stringify(lsObj["bites"]));
+	}
+
 	// save to localStorage
 	try {
 		localStorage.setItem("lastGameArgs", JSON.stringify(lsObj));